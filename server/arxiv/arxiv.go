@@ -0,0 +1,57 @@
+package arxiv
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// pointNamespace scopes the UUIDv5 point IDs derived from arXiv IDs
+var pointNamespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("http://arxiv.org/abs/"))
+
+var versionSuffix = regexp.MustCompile(`v(\d+)$`)
+
+// NormalizeID strips the abs URL prefix and version suffix from an arXiv ID,
+// so "http://arxiv.org/abs/2101.01234v2" becomes "2101.01234".
+func NormalizeID(id string) string {
+	id = strings.TrimSpace(id)
+	for _, prefix := range []string{"http://arxiv.org/abs/", "https://arxiv.org/abs/", "arXiv:", "arxiv:"} {
+		id = strings.TrimPrefix(id, prefix)
+	}
+	return versionSuffix.ReplaceAllString(id, "")
+}
+
+// Number returns the last path segment of a normalized arXiv ID, so the
+// old-style "hep-th/9901001v1" becomes "9901001". New-style IDs are their
+// own number. Old-style numbers repeat across archives.
+func Number(id string) string {
+	id = NormalizeID(id)
+	return id[strings.LastIndex(id, "/")+1:]
+}
+
+// Version returns the numeric version suffix of an arXiv ID, or 0 if the
+// ID carries none.
+func Version(id string) int {
+	match := versionSuffix.FindStringSubmatch(strings.TrimSpace(id))
+	if match == nil {
+		return 0
+	}
+	version, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0
+	}
+	return version
+}
+
+// PointID derives the Qdrant point ID for a paper. Every version of the same
+// paper maps to the same UUID, so re-ingesting it replaces the stored point.
+func PointID(id string) string {
+	return uuid.NewSHA1(pointNamespace, []byte(NormalizeID(id))).String()
+}
+
+// ChunkPointID derives the Qdrant point ID for one full-text chunk of a paper
+func ChunkPointID(id string, index int) string {
+	return uuid.NewSHA1(pointNamespace, []byte(NormalizeID(id)+"#"+strconv.Itoa(index))).String()
+}
@@ -0,0 +1,84 @@
+package arxiv
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestIDs(t *testing.T) {
+	tests := []struct {
+		id         string
+		normalized string
+		number     string
+		version    int
+	}{
+		{"http://arxiv.org/abs/2101.01234v2", "2101.01234", "2101.01234", 2},
+		{"https://arxiv.org/abs/2101.01234v12", "2101.01234", "2101.01234", 12},
+		{"arXiv:2101.01234v1", "2101.01234", "2101.01234", 1},
+		{"arxiv:2101.01234", "2101.01234", "2101.01234", 0},
+		{"  2101.01234v3\n", "2101.01234", "2101.01234", 3},
+		{"2101.01234", "2101.01234", "2101.01234", 0},
+		{"http://arxiv.org/abs/2101.01234", "2101.01234", "2101.01234", 0},
+		{"hep-th/9901001v1", "hep-th/9901001", "9901001", 1},
+		{"http://arxiv.org/abs/hep-th/9901001v2", "hep-th/9901001", "9901001", 2},
+		{"math/9901001", "math/9901001", "9901001", 0},
+	}
+	for _, test := range tests {
+		if got := NormalizeID(test.id); got != test.normalized {
+			t.Errorf("NormalizeID(%q) = %q, want %q", test.id, got, test.normalized)
+		}
+		if got := Number(test.id); got != test.number {
+			t.Errorf("Number(%q) = %q, want %q", test.id, got, test.number)
+		}
+		if got := Version(test.id); got != test.version {
+			t.Errorf("Version(%q) = %d, want %d", test.id, got, test.version)
+		}
+	}
+}
+
+func TestPointID(t *testing.T) {
+	// Every form of every version of a paper shares its point
+	same := [][]string{
+		{"2101.01234", "2101.01234v1", "2101.01234v2", "arXiv:2101.01234v3", "http://arxiv.org/abs/2101.01234v2"},
+		{"hep-th/9901001", "hep-th/9901001v1", "https://arxiv.org/abs/hep-th/9901001v2"},
+	}
+	for _, ids := range same {
+		want := PointID(ids[0])
+		for _, id := range ids[1:] {
+			if got := PointID(id); got != want {
+				t.Errorf("PointID(%q) = %s, want %s like %q", id, got, want, ids[0])
+			}
+		}
+	}
+
+	// Old-style numbers repeat across archives but the papers don't
+	if PointID("hep-th/9901001") == PointID("math/9901001") {
+		t.Error("hep-th/9901001 and math/9901001 share a point ID")
+	}
+	if PointID("2101.01234") == PointID("2101.01235") {
+		t.Error("2101.01234 and 2101.01235 share a point ID")
+	}
+}
+
+func TestChunkPointID(t *testing.T) {
+	ids := []string{"2101.01234", "2101.01235", "hep-th/9901001", "math/9901001"}
+
+	seen := make(map[string]string)
+	add := func(point, name string) {
+		if other, ok := seen[point]; ok {
+			t.Errorf("%s and %s share point ID %s", name, other, point)
+		}
+		seen[point] = name
+	}
+	for _, id := range ids {
+		add(PointID(id), id)
+		for index := 0; index < 20; index++ {
+			add(ChunkPointID(id, index), fmt.Sprintf("%s chunk %d", id, index))
+		}
+	}
+
+	// Chunks of every version of a paper share their points too
+	if ChunkPointID("2101.01234v1", 3) != ChunkPointID("arXiv:2101.01234v2", 3) {
+		t.Error("chunk 3 of two versions of 2101.01234 has two point IDs")
+	}
+}
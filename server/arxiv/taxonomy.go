@@ -0,0 +1,313 @@
+package arxiv
+
+import "strings"
+
+// Category places an arXiv category code in the taxonomy. Codes are either
+// "archive.subject-class" (cs.LG) or a bare archive (hep-th); archives are
+// grouped into the top-level fields shown on arxiv.org.
+type Category struct {
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	Archive     string `json:"archive"`
+	ArchiveName string `json:"archiveName"`
+	Group       string `json:"group"`
+	GroupName   string `json:"groupName"`
+}
+
+var groupNames = map[string]string{
+	"cs":      "Computer Science",
+	"econ":    "Economics",
+	"eess":    "Electrical Engineering and Systems Science",
+	"math":    "Mathematics",
+	"physics": "Physics",
+	"q-bio":   "Quantitative Biology",
+	"q-fin":   "Quantitative Finance",
+	"stat":    "Statistics",
+}
+
+// archives maps each archive to its name and group
+var archives = map[string]struct{ name, group string }{
+	"cs":       {"Computer Science", "cs"},
+	"econ":     {"Economics", "econ"},
+	"eess":     {"Electrical Engineering and Systems Science", "eess"},
+	"math":     {"Mathematics", "math"},
+	"astro-ph": {"Astrophysics", "physics"},
+	"cond-mat": {"Condensed Matter", "physics"},
+	"gr-qc":    {"General Relativity and Quantum Cosmology", "physics"},
+	"hep-ex":   {"High Energy Physics - Experiment", "physics"},
+	"hep-lat":  {"High Energy Physics - Lattice", "physics"},
+	"hep-ph":   {"High Energy Physics - Phenomenology", "physics"},
+	"hep-th":   {"High Energy Physics - Theory", "physics"},
+	"math-ph":  {"Mathematical Physics", "physics"},
+	"nlin":     {"Nonlinear Sciences", "physics"},
+	"nucl-ex":  {"Nuclear Experiment", "physics"},
+	"nucl-th":  {"Nuclear Theory", "physics"},
+	"physics":  {"Physics", "physics"},
+	"quant-ph": {"Quantum Physics", "physics"},
+	"q-bio":    {"Quantitative Biology", "q-bio"},
+	"q-fin":    {"Quantitative Finance", "q-fin"},
+	"stat":     {"Statistics", "stat"},
+}
+
+var categoryNames = map[string]string{
+	"cs.AI": "Artificial Intelligence",
+	"cs.AR": "Hardware Architecture",
+	"cs.CC": "Computational Complexity",
+	"cs.CE": "Computational Engineering, Finance, and Science",
+	"cs.CG": "Computational Geometry",
+	"cs.CL": "Computation and Language",
+	"cs.CR": "Cryptography and Security",
+	"cs.CV": "Computer Vision and Pattern Recognition",
+	"cs.CY": "Computers and Society",
+	"cs.DB": "Databases",
+	"cs.DC": "Distributed, Parallel, and Cluster Computing",
+	"cs.DL": "Digital Libraries",
+	"cs.DM": "Discrete Mathematics",
+	"cs.DS": "Data Structures and Algorithms",
+	"cs.ET": "Emerging Technologies",
+	"cs.FL": "Formal Languages and Automata Theory",
+	"cs.GL": "General Literature",
+	"cs.GR": "Graphics",
+	"cs.GT": "Computer Science and Game Theory",
+	"cs.HC": "Human-Computer Interaction",
+	"cs.IR": "Information Retrieval",
+	"cs.IT": "Information Theory",
+	"cs.LG": "Machine Learning",
+	"cs.LO": "Logic in Computer Science",
+	"cs.MA": "Multiagent Systems",
+	"cs.MM": "Multimedia",
+	"cs.MS": "Mathematical Software",
+	"cs.NE": "Neural and Evolutionary Computing",
+	"cs.NI": "Networking and Internet Architecture",
+	"cs.OH": "Other Computer Science",
+	"cs.OS": "Operating Systems",
+	"cs.PF": "Performance",
+	"cs.PL": "Programming Languages",
+	"cs.RO": "Robotics",
+	"cs.SC": "Symbolic Computation",
+	"cs.SD": "Sound",
+	"cs.SE": "Software Engineering",
+	"cs.SI": "Social and Information Networks",
+
+	"econ.EM": "Econometrics",
+	"econ.GN": "General Economics",
+	"econ.TH": "Theoretical Economics",
+
+	"eess.AS": "Audio and Speech Processing",
+	"eess.IV": "Image and Video Processing",
+	"eess.SP": "Signal Processing",
+	"eess.SY": "Systems and Control",
+
+	"math.AC": "Commutative Algebra",
+	"math.AG": "Algebraic Geometry",
+	"math.AP": "Analysis of PDEs",
+	"math.AT": "Algebraic Topology",
+	"math.CA": "Classical Analysis and ODEs",
+	"math.CO": "Combinatorics",
+	"math.CT": "Category Theory",
+	"math.CV": "Complex Variables",
+	"math.DG": "Differential Geometry",
+	"math.DS": "Dynamical Systems",
+	"math.FA": "Functional Analysis",
+	"math.GM": "General Mathematics",
+	"math.GN": "General Topology",
+	"math.GR": "Group Theory",
+	"math.GT": "Geometric Topology",
+	"math.HO": "History and Overview",
+	"math.KT": "K-Theory and Homology",
+	"math.LO": "Logic",
+	"math.MG": "Metric Geometry",
+	"math.NA": "Numerical Analysis",
+	"math.NT": "Number Theory",
+	"math.OA": "Operator Algebras",
+	"math.OC": "Optimization and Control",
+	"math.PR": "Probability",
+	"math.QA": "Quantum Algebra",
+	"math.RA": "Rings and Algebras",
+	"math.RT": "Representation Theory",
+	"math.SG": "Symplectic Geometry",
+	"math.SP": "Spectral Theory",
+
+	"astro-ph.CO": "Cosmology and Nongalactic Astrophysics",
+	"astro-ph.EP": "Earth and Planetary Astrophysics",
+	"astro-ph.GA": "Astrophysics of Galaxies",
+	"astro-ph.HE": "High Energy Astrophysical Phenomena",
+	"astro-ph.IM": "Instrumentation and Methods for Astrophysics",
+	"astro-ph.SR": "Solar and Stellar Astrophysics",
+
+	"cond-mat.dis-nn":    "Disordered Systems and Neural Networks",
+	"cond-mat.mes-hall":  "Mesoscale and Nanoscale Physics",
+	"cond-mat.mtrl-sci":  "Materials Science",
+	"cond-mat.other":     "Other Condensed Matter",
+	"cond-mat.quant-gas": "Quantum Gases",
+	"cond-mat.soft":      "Soft Condensed Matter",
+	"cond-mat.stat-mech": "Statistical Mechanics",
+	"cond-mat.str-el":    "Strongly Correlated Electrons",
+	"cond-mat.supr-con":  "Superconductivity",
+
+	"nlin.AO": "Adaptation and Self-Organizing Systems",
+	"nlin.CD": "Chaotic Dynamics",
+	"nlin.CG": "Cellular Automata and Lattice Gases",
+	"nlin.PS": "Pattern Formation and Solitons",
+	"nlin.SI": "Exactly Solvable and Integrable Systems",
+
+	"physics.acc-ph":   "Accelerator Physics",
+	"physics.ao-ph":    "Atmospheric and Oceanic Physics",
+	"physics.app-ph":   "Applied Physics",
+	"physics.atm-clus": "Atomic and Molecular Clusters",
+	"physics.atom-ph":  "Atomic Physics",
+	"physics.bio-ph":   "Biological Physics",
+	"physics.chem-ph":  "Chemical Physics",
+	"physics.class-ph": "Classical Physics",
+	"physics.comp-ph":  "Computational Physics",
+	"physics.data-an":  "Data Analysis, Statistics and Probability",
+	"physics.ed-ph":    "Physics Education",
+	"physics.flu-dyn":  "Fluid Dynamics",
+	"physics.gen-ph":   "General Physics",
+	"physics.geo-ph":   "Geophysics",
+	"physics.hist-ph":  "History and Philosophy of Physics",
+	"physics.ins-det":  "Instrumentation and Detectors",
+	"physics.med-ph":   "Medical Physics",
+	"physics.optics":   "Optics",
+	"physics.plasm-ph": "Plasma Physics",
+	"physics.pop-ph":   "Popular Physics",
+	"physics.soc-ph":   "Physics and Society",
+	"physics.space-ph": "Space Physics",
+
+	"q-bio.BM": "Biomolecules",
+	"q-bio.CB": "Cell Behavior",
+	"q-bio.GN": "Genomics",
+	"q-bio.MN": "Molecular Networks",
+	"q-bio.NC": "Neurons and Cognition",
+	"q-bio.OT": "Other Quantitative Biology",
+	"q-bio.PE": "Populations and Evolution",
+	"q-bio.QM": "Quantitative Methods",
+	"q-bio.SC": "Subcellular Processes",
+	"q-bio.TO": "Tissues and Organs",
+
+	"q-fin.CP": "Computational Finance",
+	"q-fin.GN": "General Finance",
+	"q-fin.MF": "Mathematical Finance",
+	"q-fin.PM": "Portfolio Management",
+	"q-fin.PR": "Pricing of Securities",
+	"q-fin.RM": "Risk Management",
+	"q-fin.ST": "Statistical Finance",
+	"q-fin.TR": "Trading and Market Microstructure",
+
+	"stat.AP": "Applications",
+	"stat.CO": "Computation",
+	"stat.ME": "Methodology",
+	"stat.ML": "Machine Learning",
+	"stat.OT": "Other Statistics",
+	"stat.TH": "Statistics Theory",
+}
+
+// categoryAliases maps cross-listed aliases and pre-2000 archive codes to the
+// canonical category arXiv files them under
+var categoryAliases = map[string]string{
+	"cs.NA":    "math.NA",
+	"cs.SY":    "eess.SY",
+	"math.IT":  "cs.IT",
+	"math.MP":  "math-ph",
+	"math.ST":  "stat.TH",
+	"q-fin.EC": "econ.GN",
+	"acc-phys": "physics.acc-ph",
+	"adap-org": "nlin.AO",
+	"alg-geom": "math.AG",
+	"ao-sci":   "physics.ao-ph",
+	"atom-ph":  "physics.atom-ph",
+	"bayes-an": "physics.data-an",
+	"chao-dyn": "nlin.CD",
+	"chem-ph":  "physics.chem-ph",
+	"cmp-lg":   "cs.CL",
+	"comp-gas": "nlin.CG",
+	"dg-ga":    "math.DG",
+	"funct-an": "math.FA",
+	"mtrl-th":  "cond-mat.mtrl-sci",
+	"patt-sol": "nlin.PS",
+	"plasm-ph": "physics.plasm-ph",
+	"q-alg":    "math.QA",
+	"solv-int": "nlin.SI",
+	"supr-con": "cond-mat.supr-con",
+}
+
+// canonicalCodes finds codes regardless of case, since feeds and users
+// aren't consistent about "cs.lg" versus "cs.LG"
+var canonicalCodes = func() map[string]string {
+	codes := make(map[string]string, len(categoryNames)+len(archives)+len(categoryAliases))
+	for code := range archives {
+		codes[strings.ToLower(code)] = code
+	}
+	for code := range categoryNames {
+		codes[strings.ToLower(code)] = code
+	}
+	for alias, code := range categoryAliases {
+		codes[strings.ToLower(alias)] = code
+	}
+	return codes
+}()
+
+// NormalizeCategory returns the canonical code of a category, resolving
+// aliases and case. Unknown codes are returned trimmed but otherwise as is.
+func NormalizeCategory(code string) string {
+	code = strings.TrimSpace(code)
+	if canonical, ok := canonicalCodes[strings.ToLower(code)]; ok {
+		return canonical
+	}
+	return code
+}
+
+// NormalizeCategories normalizes every code, dropping empty codes and
+// duplicates left behind by aliases while keeping the original order
+func NormalizeCategories(codes []string) []string {
+	seen := make(map[string]bool, len(codes))
+	normalized := make([]string, 0, len(codes))
+	for _, code := range codes {
+		code = NormalizeCategory(code)
+		if code == "" || seen[code] {
+			continue
+		}
+		seen[code] = true
+		normalized = append(normalized, code)
+	}
+	return normalized
+}
+
+// LookupCategory places a category code in the taxonomy. Unknown codes are
+// still split into archive and subject class, with the code standing in for
+// any name it lacks; the boolean reports whether the code was known.
+func LookupCategory(code string) (Category, bool) {
+	code = NormalizeCategory(code)
+	archive, _, _ := strings.Cut(code, ".")
+
+	category := Category{Code: code, Name: code, Archive: archive, ArchiveName: archive, Group: archive}
+
+	info, knownArchive := archives[archive]
+	if knownArchive {
+		category.ArchiveName = info.name
+		category.Group = info.group
+	}
+	category.GroupName = category.Group
+	if name, ok := groupNames[category.Group]; ok {
+		category.GroupName = name
+	}
+
+	name, known := categoryNames[code]
+	switch {
+	case known:
+		category.Name = name
+	case knownArchive && archive == code:
+		// Archives without subject classes, such as hep-th, are categories too
+		category.Name = info.name
+		known = true
+	}
+
+	return category, known
+}
+
+// CategoryName returns the human-readable name of a category, or the code
+// itself when it isn't in the taxonomy
+func CategoryName(code string) string {
+	category, _ := LookupCategory(code)
+	return category.Name
+}
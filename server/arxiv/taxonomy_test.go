@@ -0,0 +1,96 @@
+package arxiv
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLookupCategory(t *testing.T) {
+	tests := []struct {
+		code  string
+		want  Category
+		known bool
+	}{
+		{
+			"cs.LG",
+			Category{Code: "cs.LG", Name: "Machine Learning", Archive: "cs", ArchiveName: "Computer Science", Group: "cs", GroupName: "Computer Science"},
+			true,
+		},
+		{
+			" cs.lg ",
+			Category{Code: "cs.LG", Name: "Machine Learning", Archive: "cs", ArchiveName: "Computer Science", Group: "cs", GroupName: "Computer Science"},
+			true,
+		},
+		{
+			// An archive without subject classes is a category of its group
+			"hep-th",
+			Category{Code: "hep-th", Name: "High Energy Physics - Theory", Archive: "hep-th", ArchiveName: "High Energy Physics - Theory", Group: "physics", GroupName: "Physics"},
+			true,
+		},
+		{
+			"astro-ph.CO",
+			Category{Code: "astro-ph.CO", Name: "Cosmology and Nongalactic Astrophysics", Archive: "astro-ph", ArchiveName: "Astrophysics", Group: "physics", GroupName: "Physics"},
+			true,
+		},
+		{
+			// Cross-listed aliases resolve to the category arXiv files them under
+			"math.ST",
+			Category{Code: "stat.TH", Name: "Statistics Theory", Archive: "stat", ArchiveName: "Statistics", Group: "stat", GroupName: "Statistics"},
+			true,
+		},
+		{
+			"cs.SY",
+			Category{Code: "eess.SY", Name: "Systems and Control", Archive: "eess", ArchiveName: "Electrical Engineering and Systems Science", Group: "eess", GroupName: "Electrical Engineering and Systems Science"},
+			true,
+		},
+		{
+			"math.MP",
+			Category{Code: "math-ph", Name: "Mathematical Physics", Archive: "math-ph", ArchiveName: "Mathematical Physics", Group: "physics", GroupName: "Physics"},
+			true,
+		},
+		{
+			// Pre-2000 archives became subject classes
+			"solv-int",
+			Category{Code: "nlin.SI", Name: "Exactly Solvable and Integrable Systems", Archive: "nlin", ArchiveName: "Nonlinear Sciences", Group: "physics", GroupName: "Physics"},
+			true,
+		},
+		{
+			// An unknown subject class of a known archive keeps the archive
+			"cs.XX",
+			Category{Code: "cs.XX", Name: "cs.XX", Archive: "cs", ArchiveName: "Computer Science", Group: "cs", GroupName: "Computer Science"},
+			false,
+		},
+		{
+			"foo.BAR",
+			Category{Code: "foo.BAR", Name: "foo.BAR", Archive: "foo", ArchiveName: "foo", Group: "foo", GroupName: "foo"},
+			false,
+		},
+	}
+	for _, test := range tests {
+		got, known := LookupCategory(test.code)
+		if got != test.want || known != test.known {
+			t.Errorf("LookupCategory(%q) = %+v, %v\nwant %+v, %v", test.code, got, known, test.want, test.known)
+		}
+	}
+}
+
+func TestCategoryName(t *testing.T) {
+	for code, want := range map[string]string{
+		"cs.CL":    "Computation and Language",
+		"math.IT":  "Information Theory",
+		"quant-ph": "Quantum Physics",
+		"unknown":  "unknown",
+	} {
+		if got := CategoryName(code); got != want {
+			t.Errorf("CategoryName(%q) = %q, want %q", code, got, want)
+		}
+	}
+}
+
+func TestNormalizeCategories(t *testing.T) {
+	got := NormalizeCategories([]string{"cs.IT", "math.IT", " ", "CS.lg", "cs.LG", "foo.BAR"})
+	want := []string{"cs.IT", "cs.LG", "foo.BAR"}
+	if !slices.Equal(got, want) {
+		t.Errorf("NormalizeCategories = %v, want %v", got, want)
+	}
+}
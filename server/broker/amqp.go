@@ -0,0 +1,147 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// AMQP is a Broker backed by a RabbitMQ connection. Queues are declared
+// durable and messages are published persistent on the default exchange.
+type AMQP struct {
+	conn    *amqp.Connection
+	channel *amqp.Channel
+
+	consumers atomic.Int64
+}
+
+func DialAMQP(url string) (*AMQP, error) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+
+	channel, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open a channel: %w", err)
+	}
+
+	return &AMQP{conn: conn, channel: channel}, nil
+}
+
+func (b *AMQP) Declare(name string, options QueueOptions) error {
+	var args amqp.Table
+	if options.DeadLetterTo != "" {
+		args = amqp.Table{
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": options.DeadLetterTo,
+		}
+	}
+
+	if _, err := b.channel.QueueDeclare(name, true, false, false, false, args); err != nil {
+		return fmt.Errorf("failed to declare queue %s: %w", name, err)
+	}
+	return nil
+}
+
+func (b *AMQP) Publish(ctx context.Context, queue string, message Message) error {
+	publishing := amqp.Publishing{
+		ContentType:  message.ContentType,
+		DeliveryMode: amqp.Persistent,
+		Headers:      amqp.Table(message.Headers),
+		Body:         message.Body,
+	}
+	if message.Expiration > 0 {
+		publishing.Expiration = strconv.FormatInt(message.Expiration.Milliseconds(), 10)
+	}
+
+	return b.channel.PublishWithContext(ctx, "", queue, false, false, publishing)
+}
+
+func (b *AMQP) Consume(ctx context.Context, queue string, prefetch int) (<-chan Delivery, error) {
+	if err := b.channel.Qos(prefetch, 0, false); err != nil {
+		return nil, fmt.Errorf("failed to set QoS: %w", err)
+	}
+
+	tag := fmt.Sprintf("ragscholar-%s-%d", queue, b.consumers.Add(1))
+	messages, err := b.channel.Consume(queue, tag, false, false, false, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume from %s: %w", queue, err)
+	}
+
+	deliveries := make(chan Delivery)
+	go func() {
+		defer close(deliveries)
+		for {
+			select {
+			case message, ok := <-messages:
+				if !ok {
+					return
+				}
+				select {
+				case deliveries <- amqpDelivery(message):
+				case <-ctx.Done():
+					message.Nack(false, true)
+					b.channel.Cancel(tag, false)
+					return
+				}
+			case <-ctx.Done():
+				// Messages RabbitMQ already sent us are requeued when the
+				// channel closes
+				b.channel.Cancel(tag, false)
+				return
+			}
+		}
+	}()
+
+	return deliveries, nil
+}
+
+func (b *AMQP) Get(queue string) (Delivery, bool, error) {
+	message, ok, err := b.channel.Get(queue, false)
+	if err != nil || !ok {
+		return Delivery{}, false, err
+	}
+	return amqpDelivery(message), true, nil
+}
+
+func (b *AMQP) Len(queue string) (int, error) {
+	inspected, err := b.channel.QueueDeclarePassive(queue, true, false, false, false, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to inspect %s: %w", queue, err)
+	}
+	return inspected.Messages, nil
+}
+
+func (b *AMQP) Close() error {
+	b.channel.Close()
+	return b.conn.Close()
+}
+
+func amqpDelivery(message amqp.Delivery) Delivery {
+	return Delivery{
+		Message: Message{
+			ContentType: message.ContentType,
+			Headers:     map[string]any(message.Headers),
+			Body:        message.Body,
+		},
+		Redelivered: message.Redelivered,
+		acker:       amqpAcker{message},
+	}
+}
+
+type amqpAcker struct {
+	message amqp.Delivery
+}
+
+func (a amqpAcker) ack() error {
+	return a.message.Ack(false)
+}
+
+func (a amqpAcker) nack(requeue bool) error {
+	return a.message.Nack(false, requeue)
+}
@@ -0,0 +1,91 @@
+// Package broker puts the message queue between the service and the consumer
+// behind an interface, so the pipeline can run against RabbitMQ or, in tests
+// and dev mode, against an in-process broker.
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Backends
+const (
+	BackendAMQP   = "amqp"
+	BackendMemory = "memory"
+)
+
+var ErrUnknownQueue = errors.New("queue not declared")
+
+// Broker publishes messages to named queues and delivers them to consumers,
+// which settle each delivery with Ack or Nack.
+type Broker interface {
+	// Declare creates the queue if it doesn't exist yet
+	Declare(name string, options QueueOptions) error
+	Publish(ctx context.Context, queue string, message Message) error
+	// Consume delivers messages from the queue until ctx is done or the
+	// broker is closed, with at most prefetch of them unsettled at a time
+	// (0 means no limit). The channel is closed when delivery stops.
+	Consume(ctx context.Context, queue string, prefetch int) (<-chan Delivery, error)
+	// Get takes the next message from the queue, reporting false when it is
+	// empty
+	Get(queue string) (Delivery, bool, error)
+	// Len returns the number of messages waiting in the queue
+	Len(queue string) (int, error)
+	Close() error
+}
+
+type QueueOptions struct {
+	// DeadLetterTo names the queue that receives messages which expire or
+	// are rejected without requeueing
+	DeadLetterTo string `json:"deadLetterTo,omitempty"`
+}
+
+type Message struct {
+	ContentType string         `json:"contentType,omitempty"`
+	Headers     map[string]any `json:"headers,omitempty"`
+	Body        []byte         `json:"body"`
+	// Expiration drops the message, or dead-letters it, once it has waited
+	// in the queue this long; zero never expires
+	Expiration time.Duration `json:"expiration,omitempty"`
+}
+
+// Delivery is a message handed to a consumer. It stays with the broker until
+// it is settled: Ack removes it, and Nack requeues or dead-letters it.
+type Delivery struct {
+	Message
+	Redelivered bool
+
+	acker acknowledger
+}
+
+type acknowledger interface {
+	ack() error
+	nack(requeue bool) error
+}
+
+func (d Delivery) Ack() error {
+	return d.acker.ack()
+}
+
+func (d Delivery) Nack(requeue bool) error {
+	return d.acker.nack(requeue)
+}
+
+// Open returns the broker for backend: a RabbitMQ connection to url, or an
+// in-process broker that keeps its queues in the journal at path when path
+// is set.
+func Open(backend string, url string, path string) (Broker, error) {
+	switch backend {
+	case "", BackendAMQP:
+		return DialAMQP(url)
+	case BackendMemory:
+		if path == "" {
+			return NewMemory(), nil
+		}
+		return OpenFile(path)
+	default:
+		return nil, fmt.Errorf("unknown broker backend %q", backend)
+	}
+}
@@ -0,0 +1,230 @@
+package broker
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"time"
+)
+
+// journal is the append-only log behind a file-backed Memory broker. Each
+// line records a queue declaration, a published message or a settled one;
+// replaying it rebuilds the queues, and it is compacted down to the live
+// messages every time it is opened. Writes aren't synced, so messages survive
+// restarts and crashes of the process but not of the machine.
+type journal struct {
+	file *os.File
+}
+
+type journalRecord struct {
+	Op       string        `json:"op"` // "declare", "publish" or "remove"
+	Queue    string        `json:"queue,omitempty"`
+	ID       uint64        `json:"id,omitempty"`
+	Options  *QueueOptions `json:"options,omitempty"`
+	Message  *Message      `json:"message,omitempty"`
+	Deadline int64         `json:"deadline,omitempty"` // Unix milliseconds
+	// Removes is the message a dead-lettered one replaces
+	Removes uint64 `json:"removes,omitempty"`
+}
+
+// OpenFile returns an in-process broker whose queues are kept in the journal
+// file at path, restoring the messages left there by an earlier run.
+// Deliveries that weren't settled before the broker closed are delivered
+// again.
+func OpenFile(path string) (*Memory, error) {
+	declared, live, err := readJournal(path)
+	if err != nil {
+		return nil, err
+	}
+
+	b := NewMemory()
+	var records []journalRecord
+	for _, name := range sortedKeys(declared) {
+		options := declared[name]
+		b.queues[name] = &memoryQueue{options: options, unacked: make(map[uint64]*memoryMessage)}
+		records = append(records, journalRecord{Op: "declare", Queue: name, Options: &options})
+	}
+
+	for _, id := range sortedKeys(live) {
+		record := live[id]
+		q, ok := b.queues[record.Queue]
+		if !ok {
+			q = &memoryQueue{unacked: make(map[uint64]*memoryMessage)}
+			b.queues[record.Queue] = q
+		}
+
+		m := &memoryMessage{id: id, message: *record.Message}
+		if record.Deadline != 0 {
+			m.deadline = time.UnixMilli(record.Deadline)
+		}
+		q.ready = append(q.ready, m)
+		b.nextID = max(b.nextID, id)
+
+		records = append(records, journalRecord{Op: "publish", Queue: record.Queue, ID: id, Message: record.Message, Deadline: record.Deadline})
+	}
+
+	b.journal, err = compactJournal(path, records)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	for name, q := range b.queues {
+		for _, m := range q.ready {
+			b.schedule(name, m)
+		}
+	}
+	b.mu.Unlock()
+
+	return b, nil
+}
+
+// readJournal replays the journal at path, returning the declared queues and
+// the messages still in them by ID. A missing file is an empty journal.
+func readJournal(path string) (map[string]QueueOptions, map[uint64]journalRecord, error) {
+	declared := make(map[string]QueueOptions)
+	live := make(map[uint64]journalRecord)
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return declared, live, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open queue journal: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if len(data) == 0 && errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, nil, fmt.Errorf("failed to read queue journal: %w", err)
+		}
+
+		var record journalRecord
+		if jsonErr := json.Unmarshal(data, &record); jsonErr != nil {
+			if err != nil {
+				// A final line without a newline is a write cut short by a crash
+				break
+			}
+			return nil, nil, fmt.Errorf("invalid queue journal %s at line %d: %w", path, line, jsonErr)
+		}
+
+		switch record.Op {
+		case "declare":
+			if record.Options != nil {
+				declared[record.Queue] = *record.Options
+			}
+		case "publish":
+			if record.Message != nil {
+				live[record.ID] = record
+			}
+			delete(live, record.Removes)
+		case "remove":
+			delete(live, record.ID)
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	return declared, live, nil
+}
+
+// compactJournal replaces the journal at path with records and opens it for
+// appending
+func compactJournal(path string, records []journalRecord) (*journal, error) {
+	temp := path + ".tmp"
+	file, err := os.Create(temp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write queue journal: %w", err)
+	}
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, record := range records {
+		if err = encoder.Encode(record); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp, path)
+	}
+	if err != nil {
+		os.Remove(temp)
+		return nil, fmt.Errorf("failed to write queue journal: %w", err)
+	}
+
+	file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open queue journal: %w", err)
+	}
+	return &journal{file: file}, nil
+}
+
+// The methods below do nothing on a nil journal, so a broker without a file
+// calls them unconditionally
+
+func (j *journal) declare(queue string, options QueueOptions) error {
+	return j.append(journalRecord{Op: "declare", Queue: queue, Options: &options})
+}
+
+func (j *journal) publish(queue string, m *memoryMessage, removes uint64) error {
+	record := journalRecord{Op: "publish", Queue: queue, ID: m.id, Message: &m.message, Removes: removes}
+	if !m.deadline.IsZero() {
+		record.Deadline = m.deadline.UnixMilli()
+	}
+	return j.append(record)
+}
+
+func (j *journal) remove(id uint64) error {
+	return j.append(journalRecord{Op: "remove", ID: id})
+}
+
+func (j *journal) append(record journalRecord) error {
+	if j == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode queue journal record: %w", err)
+	}
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write queue journal: %w", err)
+	}
+	return nil
+}
+
+func (j *journal) close() error {
+	if j == nil {
+		return nil
+	}
+	return j.file.Close()
+}
+
+func sortedKeys[K string | uint64, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,123 @@
+package broker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openFile(t *testing.T, path string) *Memory {
+	t.Helper()
+	b, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestJournalReplaysUnackedMessages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.journal")
+	b := openFile(t, path)
+	declare(t, b, "papers.dlq", QueueOptions{})
+	declare(t, b, "papers", QueueOptions{DeadLetterTo: "papers.dlq"})
+	for _, body := range []string{"acked", "unacked", "ready"} {
+		publish(t, b, "papers", Message{ContentType: "application/json", Headers: map[string]any{"x-retry-count": 1}, Body: []byte(body)})
+	}
+	publish(t, b, "papers", Message{Body: []byte("dead")})
+
+	if err := get(t, b, "papers").Ack(); err != nil {
+		t.Fatal(err)
+	}
+	get(t, b, "papers") // left unsettled
+	if err := get(t, b, "papers").Nack(true); err != nil {
+		t.Fatal(err)
+	}
+	// ready is at the front again, dead behind it
+	get(t, b, "papers")
+	if err := get(t, b, "papers").Nack(false); err != nil {
+		t.Fatal(err)
+	}
+	b.Close()
+
+	b = openFile(t, path)
+	defer b.Close()
+
+	// Unsettled deliveries are back in the queue in publishing order
+	var bodies []string
+	for length(t, b, "papers") > 0 {
+		delivery := get(t, b, "papers")
+		bodies = append(bodies, string(delivery.Body))
+		if delivery.ContentType != "application/json" || delivery.Headers["x-retry-count"] != 1.0 {
+			t.Errorf("%q came back as %s with headers %v", delivery.Body, delivery.ContentType, delivery.Headers)
+		}
+	}
+	if len(bodies) != 2 || bodies[0] != "unacked" || bodies[1] != "ready" {
+		t.Errorf("reopened queue holds %q, want unacked and ready", bodies)
+	}
+
+	// The dead-lettered message replaced the original rather than joining it
+	if got := length(t, b, "papers.dlq"); got != 1 {
+		t.Errorf("reopened dead-letter queue holds %d messages, want 1", got)
+	}
+
+	// Declarations survive too
+	publish(t, b, "papers", Message{Body: []byte("rejected")})
+	if err := get(t, b, "papers").Nack(false); err != nil {
+		t.Fatal(err)
+	}
+	if got := length(t, b, "papers.dlq"); got != 2 {
+		t.Errorf("rejecting after reopening left %d messages in the dead-letter queue, want 2", got)
+	}
+}
+
+func TestJournalExpiresMessagesAfterReopening(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.journal")
+	b := openFile(t, path)
+	declare(t, b, "papers", QueueOptions{})
+	declare(t, b, "papers.retry", QueueOptions{DeadLetterTo: "papers"})
+	publish(t, b, "papers.retry", Message{Body: []byte("retry"), Expiration: 50 * time.Millisecond})
+	b.Close()
+
+	// The deadline is kept, not restarted
+	time.Sleep(60 * time.Millisecond)
+	b = openFile(t, path)
+	defer b.Close()
+	waitForLen(t, b, "papers", 1)
+	if got := length(t, b, "papers.retry"); got != 0 {
+		t.Errorf("retry queue holds %d messages after the deadline", got)
+	}
+}
+
+func TestJournalToleratesTruncatedWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.journal")
+	b := openFile(t, path)
+	declare(t, b, "papers", QueueOptions{})
+	publish(t, b, "papers", Message{Body: []byte("kept")})
+	b.Close()
+
+	// A crash in the middle of appending a record
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteString(`{"op":"publish","queue":"papers","id":9,"mess`); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	b = openFile(t, path)
+	if got := length(t, b, "papers"); got != 1 {
+		t.Errorf("queue holds %d messages after a truncated write, want 1", got)
+	}
+	b.Close()
+
+	// Corruption before the last line is an error
+	if err := os.WriteFile(path, []byte("not json\n{\"op\":\"declare\",\"queue\":\"papers\",\"options\":{}}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := OpenFile(path); err == nil {
+		b.Close()
+		t.Error("OpenFile accepted a corrupt journal")
+	}
+}
@@ -0,0 +1,380 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	errSettled = errors.New("delivery already settled")
+	errClosed  = errors.New("broker closed")
+)
+
+// Memory is an in-process Broker. Messages live in memory and are lost when
+// the process exits, unless the broker was opened with OpenFile.
+type Memory struct {
+	mu     sync.Mutex
+	queues map[string]*memoryQueue
+	nextID uint64
+	closed bool
+
+	// changed is closed and replaced whenever a message becomes ready or a
+	// delivery is settled, waking consumers
+	changed chan struct{}
+
+	journal *journal
+}
+
+type memoryQueue struct {
+	options QueueOptions
+	ready   []*memoryMessage
+	unacked map[uint64]*memoryMessage
+}
+
+type memoryMessage struct {
+	id          uint64
+	message     Message
+	deadline    time.Time
+	redelivered bool
+	timer       *time.Timer
+
+	// consumer limits the deliveries in flight; nil for Get
+	consumer *memoryConsumer
+}
+
+type memoryConsumer struct {
+	inFlight int
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		queues:  make(map[string]*memoryQueue),
+		changed: make(chan struct{}),
+	}
+}
+
+func (b *Memory) Declare(name string, options QueueOptions) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return errClosed
+	}
+
+	q, ok := b.queues[name]
+	if !ok {
+		q = &memoryQueue{unacked: make(map[uint64]*memoryMessage)}
+		b.queues[name] = q
+	} else if q.options == options {
+		return nil
+	}
+	q.options = options
+
+	return b.journal.declare(name, options)
+}
+
+func (b *Memory) Publish(ctx context.Context, queue string, message Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return errClosed
+	}
+	q, ok := b.queues[queue]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownQueue, queue)
+	}
+
+	m := b.newMessage(message)
+	if err := b.journal.publish(queue, m, 0); err != nil {
+		return err
+	}
+	b.enqueue(queue, q, m)
+	return nil
+}
+
+func (b *Memory) Consume(ctx context.Context, queue string, prefetch int) (<-chan Delivery, error) {
+	b.mu.Lock()
+	_, ok := b.queues[queue]
+	b.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownQueue, queue)
+	}
+
+	consumer := &memoryConsumer{}
+	deliveries := make(chan Delivery)
+	go func() {
+		defer close(deliveries)
+		for {
+			b.mu.Lock()
+			if b.closed {
+				b.mu.Unlock()
+				return
+			}
+			var m *memoryMessage
+			if prefetch <= 0 || consumer.inFlight < prefetch {
+				m = b.take(b.queues[queue], consumer)
+			}
+			changed := b.changed
+			b.mu.Unlock()
+
+			if m == nil {
+				select {
+				case <-changed:
+					continue
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			delivery := b.delivery(queue, m)
+			select {
+			case deliveries <- delivery:
+			case <-ctx.Done():
+				delivery.Nack(true)
+				return
+			}
+		}
+	}()
+
+	return deliveries, nil
+}
+
+func (b *Memory) Get(queue string) (Delivery, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q, ok := b.queues[queue]
+	if !ok {
+		return Delivery{}, false, fmt.Errorf("%w: %s", ErrUnknownQueue, queue)
+	}
+
+	m := b.take(q, nil)
+	if m == nil {
+		return Delivery{}, false, nil
+	}
+	return b.delivery(queue, m), true, nil
+}
+
+func (b *Memory) Len(queue string) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q, ok := b.queues[queue]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownQueue, queue)
+	}
+	return len(q.ready), nil
+}
+
+// Close stops every consumer. Unsettled deliveries can no longer be settled;
+// a file-backed broker delivers them again when it is reopened.
+func (b *Memory) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	for _, q := range b.queues {
+		for _, m := range q.ready {
+			if m.timer != nil {
+				m.timer.Stop()
+			}
+		}
+	}
+	b.notify()
+
+	return b.journal.close()
+}
+
+func (b *Memory) newMessage(message Message) *memoryMessage {
+	b.nextID++
+	m := &memoryMessage{id: b.nextID, message: message}
+	m.message.Headers = copyHeaders(message.Headers)
+	if message.Expiration > 0 {
+		m.deadline = time.Now().Add(message.Expiration)
+	}
+	return m
+}
+
+// enqueue makes m ready at the back of the queue, starting its expiration
+// timer
+func (b *Memory) enqueue(queue string, q *memoryQueue, m *memoryMessage) {
+	q.ready = append(q.ready, m)
+	b.schedule(queue, m)
+	b.notify()
+}
+
+// requeue puts a rejected delivery back at the front of the queue
+func (b *Memory) requeue(queue string, q *memoryQueue, m *memoryMessage) {
+	m.redelivered = true
+	q.ready = append([]*memoryMessage{m}, q.ready...)
+	b.schedule(queue, m)
+	b.notify()
+}
+
+func (b *Memory) schedule(queue string, m *memoryMessage) {
+	if m.deadline.IsZero() {
+		return
+	}
+	m.timer = time.AfterFunc(time.Until(m.deadline), func() {
+		b.expire(queue, m)
+	})
+}
+
+func (b *Memory) expire(queue string, m *memoryMessage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q, ok := b.queues[queue]
+	if b.closed || !ok {
+		return
+	}
+	i := slices.Index(q.ready, m)
+	if i < 0 {
+		// Delivered before it expired
+		return
+	}
+	q.ready = slices.Delete(q.ready, i, i+1)
+
+	if err := b.deadLetter(q, m); err != nil {
+		// The journal still holds the message, so it expires again on reopen
+		q.ready = append(q.ready, m)
+	}
+}
+
+// deadLetter moves m, which is no longer in any queue, to the queue's
+// dead-letter queue, or drops it when the queue has none
+func (b *Memory) deadLetter(q *memoryQueue, m *memoryMessage) error {
+	target, ok := b.queues[q.options.DeadLetterTo]
+	if !ok {
+		return b.journal.remove(m.id)
+	}
+
+	moved := b.newMessage(m.message)
+	moved.message.Expiration = 0
+	moved.deadline = time.Time{}
+	if err := b.journal.publish(q.options.DeadLetterTo, moved, m.id); err != nil {
+		return err
+	}
+	b.enqueue(q.options.DeadLetterTo, target, moved)
+	return nil
+}
+
+// take moves the next ready message to the unacked set
+func (b *Memory) take(q *memoryQueue, consumer *memoryConsumer) *memoryMessage {
+	if len(q.ready) == 0 {
+		return nil
+	}
+
+	m := q.ready[0]
+	q.ready = q.ready[1:]
+	if m.timer != nil {
+		m.timer.Stop()
+		m.timer = nil
+	}
+
+	m.consumer = consumer
+	if consumer != nil {
+		consumer.inFlight++
+	}
+	q.unacked[m.id] = m
+	return m
+}
+
+func (b *Memory) delivery(queue string, m *memoryMessage) Delivery {
+	message := m.message
+	message.Headers = copyHeaders(m.message.Headers)
+	return Delivery{
+		Message:     message,
+		Redelivered: m.redelivered,
+		acker:       memoryAcker{broker: b, queue: queue, id: m.id},
+	}
+}
+
+// settle removes a delivery from the unacked set
+func (b *Memory) settle(queue string, id uint64) (*memoryQueue, *memoryMessage, error) {
+	if b.closed {
+		return nil, nil, errClosed
+	}
+	q, ok := b.queues[queue]
+	if !ok {
+		return nil, nil, errSettled
+	}
+	m, ok := q.unacked[id]
+	if !ok {
+		return nil, nil, errSettled
+	}
+
+	delete(q.unacked, id)
+	if m.consumer != nil {
+		m.consumer.inFlight--
+		m.consumer = nil
+	}
+	b.notify()
+	return q, m, nil
+}
+
+func (b *Memory) notify() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+type memoryAcker struct {
+	broker *Memory
+	queue  string
+	id     uint64
+}
+
+func (a memoryAcker) ack() error {
+	b := a.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	_, m, err := b.settle(a.queue, a.id)
+	if err != nil {
+		return err
+	}
+	return b.journal.remove(m.id)
+}
+
+func (a memoryAcker) nack(requeue bool) error {
+	b := a.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q, m, err := b.settle(a.queue, a.id)
+	if err != nil {
+		return err
+	}
+	if requeue {
+		b.requeue(a.queue, q, m)
+		return nil
+	}
+	if err := b.deadLetter(q, m); err != nil {
+		b.requeue(a.queue, q, m)
+		return err
+	}
+	return nil
+}
+
+func copyHeaders(headers map[string]any) map[string]any {
+	if headers == nil {
+		return nil
+	}
+	copied := make(map[string]any, len(headers))
+	for k, v := range headers {
+		copied[k] = v
+	}
+	return copied
+}
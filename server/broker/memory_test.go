@@ -0,0 +1,232 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func declare(t *testing.T, b Broker, name string, options QueueOptions) {
+	t.Helper()
+	if err := b.Declare(name, options); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func publish(t *testing.T, b Broker, queue string, message Message) {
+	t.Helper()
+	if err := b.Publish(context.Background(), queue, message); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func get(t *testing.T, b Broker, queue string) Delivery {
+	t.Helper()
+	delivery, ok, err := b.Get(queue)
+	if err != nil || !ok {
+		t.Fatalf("Get(%s) = %v, %v, want a message", queue, ok, err)
+	}
+	return delivery
+}
+
+func length(t *testing.T, b Broker, queue string) int {
+	t.Helper()
+	n, err := b.Len(queue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// waitForLen waits until queue holds want messages
+func waitForLen(t *testing.T, b Broker, queue string, want int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for length(t, b, queue) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s holds %d messages, want %d", queue, length(t, b, queue), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestNackRedelivers(t *testing.T) {
+	b := NewMemory()
+	defer b.Close()
+	declare(t, b, "papers", QueueOptions{})
+	publish(t, b, "papers", Message{Body: []byte("first")})
+	publish(t, b, "papers", Message{Body: []byte("second")})
+
+	first := get(t, b, "papers")
+	if first.Redelivered {
+		t.Error("first delivery is marked redelivered")
+	}
+	if err := first.Nack(true); err != nil {
+		t.Fatal(err)
+	}
+
+	// A requeued message goes back to the front
+	again := get(t, b, "papers")
+	if string(again.Body) != "first" || !again.Redelivered {
+		t.Errorf("after Nack got %q, redelivered %v, want first redelivered", again.Body, again.Redelivered)
+	}
+	if err := again.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	if err := again.Ack(); !errors.Is(err, errSettled) {
+		t.Errorf("settling twice: %v, want errSettled", err)
+	}
+	if err := first.Nack(true); !errors.Is(err, errSettled) {
+		t.Errorf("settling an earlier delivery of the same message: %v, want errSettled", err)
+	}
+	if got := length(t, b, "papers"); got != 1 {
+		t.Errorf("queue holds %d messages, want the second one", got)
+	}
+}
+
+func TestRejectDeadLetters(t *testing.T) {
+	b := NewMemory()
+	defer b.Close()
+	declare(t, b, "papers.dlq", QueueOptions{})
+	declare(t, b, "papers", QueueOptions{DeadLetterTo: "papers.dlq"})
+	declare(t, b, "scratch", QueueOptions{})
+
+	publish(t, b, "papers", Message{Body: []byte("bad"), Headers: map[string]any{"x-retry-count": 2}})
+	if err := get(t, b, "papers").Nack(false); err != nil {
+		t.Fatal(err)
+	}
+	dead := get(t, b, "papers.dlq")
+	if string(dead.Body) != "bad" || dead.Headers["x-retry-count"] != 2 {
+		t.Errorf("dead-lettered message = %q with headers %v", dead.Body, dead.Headers)
+	}
+
+	// Without a dead-letter queue the message is dropped
+	publish(t, b, "scratch", Message{Body: []byte("bad")})
+	if err := get(t, b, "scratch").Nack(false); err != nil {
+		t.Fatal(err)
+	}
+	if got := length(t, b, "scratch"); got != 0 {
+		t.Errorf("rejected message stayed in a queue without a dead-letter queue")
+	}
+}
+
+func TestExpiredMessageIsDeadLettered(t *testing.T) {
+	b := NewMemory()
+	defer b.Close()
+	declare(t, b, "papers", QueueOptions{})
+	declare(t, b, "papers.retry", QueueOptions{DeadLetterTo: "papers"})
+
+	publish(t, b, "papers.retry", Message{Body: []byte("retry"), Expiration: 10 * time.Millisecond})
+	if got := length(t, b, "papers.retry"); got != 1 {
+		t.Fatalf("retry queue holds %d messages before the expiration", got)
+	}
+
+	waitForLen(t, b, "papers", 1)
+	if got := length(t, b, "papers.retry"); got != 0 {
+		t.Errorf("expired message is still in the retry queue")
+	}
+	// The dead-lettered copy doesn't expire again
+	moved := get(t, b, "papers")
+	if string(moved.Body) != "retry" || moved.Expiration != 0 {
+		t.Errorf("dead-lettered message = %q expiring after %v", moved.Body, moved.Expiration)
+	}
+
+	// A message delivered before it expires isn't dead-lettered
+	publish(t, b, "papers.retry", Message{Body: []byte("taken"), Expiration: 10 * time.Millisecond})
+	taken := get(t, b, "papers.retry")
+	time.Sleep(30 * time.Millisecond)
+	if got := length(t, b, "papers"); got != 0 {
+		t.Errorf("a delivered message expired into %d messages", got)
+	}
+	if err := taken.Ack(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConsumeHonorsPrefetch(t *testing.T) {
+	b := NewMemory()
+	defer b.Close()
+	declare(t, b, "papers", QueueOptions{})
+	for _, body := range []string{"1", "2", "3"} {
+		publish(t, b, "papers", Message{Body: []byte(body)})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	deliveries, err := b.Consume(ctx, "papers", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receive := func() Delivery {
+		t.Helper()
+		select {
+		case delivery := <-deliveries:
+			return delivery
+		case <-time.After(5 * time.Second):
+			t.Fatal("no delivery")
+			return Delivery{}
+		}
+	}
+
+	first, second := receive(), receive()
+	select {
+	case delivery := <-deliveries:
+		t.Fatalf("got %q with 2 deliveries unsettled and prefetch 2", delivery.Body)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Settling one makes room for the next
+	if err := first.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	if third := receive(); string(third.Body) != "3" {
+		t.Errorf("after Ack got %q, want 3", third.Body)
+	}
+	if err := second.Ack(); err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	for range deliveries {
+	}
+}
+
+func TestUnknownQueue(t *testing.T) {
+	b := NewMemory()
+	defer b.Close()
+
+	if err := b.Publish(context.Background(), "papers", Message{}); !errors.Is(err, ErrUnknownQueue) {
+		t.Errorf("Publish: %v, want ErrUnknownQueue", err)
+	}
+	if _, _, err := b.Get("papers"); !errors.Is(err, ErrUnknownQueue) {
+		t.Errorf("Get: %v, want ErrUnknownQueue", err)
+	}
+	if _, err := b.Len("papers"); !errors.Is(err, ErrUnknownQueue) {
+		t.Errorf("Len: %v, want ErrUnknownQueue", err)
+	}
+	if _, err := b.Consume(context.Background(), "papers", 0); !errors.Is(err, ErrUnknownQueue) {
+		t.Errorf("Consume: %v, want ErrUnknownQueue", err)
+	}
+}
+
+func TestCloseStopsConsumers(t *testing.T) {
+	b := NewMemory()
+	declare(t, b, "papers", QueueOptions{})
+	deliveries, err := b.Consume(context.Background(), "papers", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b.Close()
+	select {
+	case _, ok := <-deliveries:
+		if ok {
+			t.Error("got a delivery from a closed broker")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("closing the broker didn't close the delivery channel")
+	}
+	if err := b.Publish(context.Background(), "papers", Message{}); !errors.Is(err, errClosed) {
+		t.Errorf("Publish after Close: %v, want errClosed", err)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"RAGScholar/broker"
+	"RAGScholar/config"
+	"RAGScholar/consumer/pipeline"
+	"RAGScholar/gemini/geminitest"
+	"RAGScholar/service/server"
+	"RAGScholar/vectorstore"
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+func runDev(args []string) {
+	flags := flag.NewFlagSet("dev", flag.ExitOnError)
+	configPath := flags.String("config", "", "path to a YAML config file (defaults to $RAGSCHOLAR_CONFIG)")
+	queueFile := flags.String("queue-file", "", "journal file that keeps queued messages across restarts (default: memory only)")
+	vectorStoreBackend := flags.String("vector-store", vectorstore.BackendMemory, "vector store: memory, or qdrant to use the configured Qdrant")
+	fakeGemini := flags.Bool("fake-gemini", false, "answer Gemini calls with an in-process fake API, so no key or network is needed")
+	flags.Parse(args)
+
+	if *vectorStoreBackend != vectorstore.BackendMemory && *vectorStoreBackend != vectorstore.BackendQdrant {
+		log.Fatalf("-vector-store must be memory or qdrant, got %q", *vectorStoreBackend)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	cfg.Broker = config.Broker{Backend: broker.BackendMemory, Path: *queueFile}
+	cfg.VectorStore.Backend = *vectorStoreBackend
+
+	if *fakeGemini {
+		fake := geminitest.NewServer(cfg.Embedding.Dimension)
+		defer fake.Close()
+		cfg.Gemini.Endpoint = fake.URL
+		cfg.Gemini.APIKey = "fake"
+		// Fake vectors must not end up in the cache the real model uses
+		cfg.Embedding.CachePath = ""
+		log.Printf("Using the fake Gemini API at %s", fake.URL)
+	}
+
+	log.Printf("Loaded configuration:\n%s", cfg)
+
+	messageBroker, err := broker.Open(cfg.Broker.Backend, "", cfg.Broker.Path)
+	if err != nil {
+		log.Fatalf("Failed to open the queue: %v", err)
+	}
+	defer messageBroker.Close()
+
+	// With Qdrant, the service and the consumer each connect on their own
+	var vectorStore vectorstore.VectorStore
+	if cfg.VectorStore.Backend == vectorstore.BackendMemory {
+		vectorStore = vectorstore.NewMemory()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Stopping either half stops the other
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+	for name, run := range map[string]func(context.Context, config.Config, broker.Broker, vectorstore.VectorStore) error{
+		"service":  server.Run,
+		"consumer": pipeline.Run,
+	} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer cancel()
+			if err := run(runCtx, cfg, messageBroker, vectorStore); err != nil {
+				log.Printf("The %s stopped: %v", name, err)
+				failed.Store(true)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if failed.Load() {
+		messageBroker.Close()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,41 @@
+// Command ragscholar runs maintenance tasks against a RAGScholar deployment,
+// and runs the whole pipeline in one process for development.
+//
+//	ragscholar migrate [flags]   rebuild collections into the current schema
+//	ragscholar dev [flags]       run the service and the consumer in one process
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `Usage: ragscholar <command> [flags]
+
+Commands:
+  migrate   rebuild Qdrant collections into the current schema and switch their aliases
+  dev       run the service and the consumer in one process, with an in-process queue
+
+Run "ragscholar <command> -h" for the flags of a command.`)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "migrate":
+		runMigrate(os.Args[2:])
+	case "dev":
+		runDev(os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"RAGScholar/config"
+	"RAGScholar/consumer/worker"
+	"RAGScholar/embedding"
+	"RAGScholar/gemini"
+	"RAGScholar/migrate"
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+func runMigrate(args []string) {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	configPath := flags.String("config", "", "path to a YAML config file (defaults to $RAGSCHOLAR_CONFIG)")
+	collection := flags.String("collection", "all", "which collection to migrate: papers, chunks or all")
+	batchSize := flags.Int("batch-size", migrate.DefaultBatchSize, "points copied per batch (at most 100 when re-embedding with Gemini)")
+	reembed := flags.Bool("reembed", false, "recompute every vector even if the embedding model is unchanged")
+	force := flags.Bool("force", false, "migrate even if the collection is already up to date")
+	dropOld := flags.Bool("drop-old", false, "delete the previous collection after switching the alias")
+	flags.Parse(args)
+
+	if *collection != "papers" && *collection != "chunks" && *collection != "all" {
+		log.Fatalf("-collection must be papers, chunks or all, got %q", *collection)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var geminiClient *gemini.Client
+	if cfg.Embedding.Provider == embedding.ProviderGemini {
+		if cfg.Gemini.APIKey == "" {
+			log.Fatal("GEMINI_API_KEY environment variable not set")
+		}
+
+		geminiClient, err = gemini.NewClient(ctx, cfg.GeminiOptions())
+		if err != nil {
+			log.Fatalf("Failed to create Gemini client: %v", err)
+		}
+		defer geminiClient.Close()
+	}
+
+	embedder, err := embedding.New(cfg.EmbeddingConfig(), geminiClient)
+	if err != nil {
+		log.Fatalf("Failed to initialize embedder: %v", err)
+	}
+
+	client, err := qdrant.NewClient(&qdrant.Config{
+		Host:   cfg.Qdrant.Host,
+		Port:   cfg.Qdrant.Port,
+		APIKey: cfg.Qdrant.APIKey,
+		UseTLS: cfg.Qdrant.UseTLS,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create Qdrant client: %v", err)
+	}
+	defer client.Close()
+
+	var specs []migrate.Spec
+	if *collection == "papers" || *collection == "all" {
+		specs = append(specs, worker.PaperSpec(cfg.Qdrant.Collection, embedder, cfg.Qdrant.Distance))
+	}
+	if *collection == "chunks" || *collection == "all" {
+		specs = append(specs, worker.ChunkSpec(cfg.Qdrant.ChunkCollection, embedder, cfg.Qdrant.Distance))
+	}
+
+	opts := migrate.Options{
+		MetadataCollection: cfg.Qdrant.MetadataCollection,
+		BatchSize:          *batchSize,
+		Force:              *force,
+		Reembed:            *reembed,
+		DropOld:            *dropOld,
+	}
+
+	for _, spec := range specs {
+		if err := migrate.Run(ctx, client, embedder, spec, opts); err != nil {
+			log.Printf("Failed to migrate %s: %v", spec.Alias, err)
+			os.Exit(1)
+		}
+	}
+
+	log.Println("Migration complete")
+}
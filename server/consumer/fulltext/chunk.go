@@ -0,0 +1,103 @@
+package fulltext
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	DefaultChunkWords   = 300
+	DefaultOverlapWords = 50
+)
+
+type Chunk struct {
+	Index   int
+	Section string
+	Text    string
+}
+
+var (
+	numberedHeading = regexp.MustCompile(`^(\d+(\.\d+)*\.?|[IVX]+\.)\s+[A-Z][^.!?]*$`)
+	namedHeadings   = map[string]bool{
+		"abstract": true, "introduction": true, "related work": true, "background": true,
+		"method": true, "methods": true, "methodology": true, "experiments": true,
+		"results": true, "discussion": true, "conclusion": true, "conclusions": true,
+		"acknowledgments": true, "acknowledgements": true, "appendix": true,
+	}
+	referenceHeadings = map[string]bool{"references": true, "bibliography": true}
+)
+
+// Split breaks extracted paper text into chunks of roughly chunkWords words.
+// Consecutive chunks within a section share overlapWords words of context, a
+// new section always starts a new chunk, and the reference list is dropped.
+func Split(text string, chunkWords, overlapWords int) []Chunk {
+	if chunkWords <= 0 {
+		chunkWords = DefaultChunkWords
+	}
+	if overlapWords < 0 || overlapWords >= chunkWords {
+		overlapWords = 0
+	}
+
+	var chunks []Chunk
+	var words []string
+	section := ""
+	emitted := 0 // words of the current buffer already covered by an emitted chunk
+
+	flush := func() {
+		if len(words) > emitted {
+			chunks = append(chunks, Chunk{
+				Index:   len(chunks),
+				Section: section,
+				Text:    strings.Join(words, " "),
+			})
+		}
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if heading, ok := sectionHeading(line); ok {
+			flush()
+			words, emitted = nil, 0
+			if referenceHeadings[strings.ToLower(heading)] {
+				break
+			}
+			section = heading
+			continue
+		}
+
+		for _, word := range strings.Fields(line) {
+			words = append(words, word)
+			if len(words) == chunkWords {
+				flush()
+				words = append([]string(nil), words[chunkWords-overlapWords:]...)
+				emitted = len(words)
+			}
+		}
+	}
+	flush()
+
+	return chunks
+}
+
+// sectionHeading reports whether line looks like a section heading and
+// returns it without its numbering.
+func sectionHeading(line string) (string, bool) {
+	if len(line) > 80 || len(strings.Fields(line)) > 10 {
+		return "", false
+	}
+
+	if namedHeadings[strings.ToLower(line)] || referenceHeadings[strings.ToLower(line)] {
+		return line, true
+	}
+
+	if numberedHeading.MatchString(line) {
+		parts := strings.SplitN(line, " ", 2)
+		return strings.TrimSpace(parts[1]), true
+	}
+
+	return "", false
+}
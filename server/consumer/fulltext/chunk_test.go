@@ -0,0 +1,80 @@
+package fulltext
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func words(from, to int) string {
+	parts := make([]string, 0, to-from)
+	for i := from; i < to; i++ {
+		parts = append(parts, fmt.Sprintf("w%d", i))
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestSplitOverlapsChunksWithinSection(t *testing.T) {
+	chunks := Split(words(0, 25), 10, 3)
+
+	want := []string{words(0, 10), words(7, 17), words(14, 24), words(21, 25)}
+	if len(chunks) != len(want) {
+		t.Fatalf("got %d chunks, want %d: %+v", len(chunks), len(want), chunks)
+	}
+	for i, chunk := range chunks {
+		if chunk.Index != i {
+			t.Errorf("chunk %d has index %d", i, chunk.Index)
+		}
+		if chunk.Text != want[i] {
+			t.Errorf("chunk %d = %q, want %q", i, chunk.Text, want[i])
+		}
+	}
+}
+
+func TestSplitDoesNotEmitOverlapOnly(t *testing.T) {
+	// The last chunk ends exactly at the text's end, so the overlap carried
+	// over from it must not become a chunk of its own
+	chunks := Split(words(0, 17), 10, 3)
+	if len(chunks) != 2 {
+		t.Fatalf("got %d chunks, want 2: %+v", len(chunks), chunks)
+	}
+}
+
+func TestSplitStartsChunksAtSectionHeadings(t *testing.T) {
+	text := strings.Join([]string{
+		"Abstract",
+		"We study retrieval.",
+		"1 Introduction",
+		"Papers are many.",
+		"2.1 Dense Retrieval Models",
+		"Vectors help.",
+		"This line is a sentence and not a heading.",
+		"References",
+		"[1] Someone. A paper.",
+	}, "\n")
+
+	chunks := Split(text, 100, 10)
+
+	want := []Chunk{
+		{Index: 0, Section: "Abstract", Text: "We study retrieval."},
+		{Index: 1, Section: "Introduction", Text: "Papers are many."},
+		{Index: 2, Section: "Dense Retrieval Models", Text: "Vectors help. This line is a sentence and not a heading."},
+	}
+	if len(chunks) != len(want) {
+		t.Fatalf("got %d chunks, want %d: %+v", len(chunks), len(want), chunks)
+	}
+	for i := range want {
+		if chunks[i] != want[i] {
+			t.Errorf("chunk %d = %+v, want %+v", i, chunks[i], want[i])
+		}
+	}
+}
+
+func TestSplitWithoutOverlap(t *testing.T) {
+	for _, overlap := range []int{0, -1, 10} {
+		chunks := Split(words(0, 20), 10, overlap)
+		if len(chunks) != 2 || chunks[1].Text != words(10, 20) {
+			t.Errorf("overlap %d: got %+v, want two disjoint chunks", overlap, chunks)
+		}
+	}
+}
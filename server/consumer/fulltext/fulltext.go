@@ -0,0 +1,123 @@
+package fulltext
+
+import (
+	"RAGScholar/consumer/structure"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ledongthuc/pdf"
+	"golang.org/x/time/rate"
+)
+
+const maxPDFBytes = 50 << 20 // arXiv PDFs are rarely above a few MB
+
+// Fetcher downloads paper PDFs. arXiv asks for no more than one request every
+// three seconds, so downloads share a limiter.
+type Fetcher struct {
+	client  *http.Client
+	limiter *rate.Limiter
+}
+
+func NewFetcher(client *http.Client, limiter *rate.Limiter) *Fetcher {
+	if client == nil {
+		client = &http.Client{Timeout: 60 * time.Second}
+	}
+	if limiter == nil {
+		limiter = rate.NewLimiter(rate.Every(3*time.Second), 1)
+	}
+	return &Fetcher{client: client, limiter: limiter}
+}
+
+// PDFLink returns the href of the entry's PDF link, or "" if it has none
+func PDFLink(links []structure.Link) string {
+	for _, link := range links {
+		if link.Type == "application/pdf" || link.Rel == "application/pdf" {
+			return link.Href
+		}
+	}
+	return ""
+}
+
+func (f *Fetcher) Download(ctx context.Context, url string) ([]byte, error) {
+	if err := f.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: unexpected status %s", url, res.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxPDFBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", url, err)
+	}
+	if len(data) > maxPDFBytes {
+		return nil, fmt.Errorf("PDF at %s exceeds %d bytes", url, maxPDFBytes)
+	}
+
+	return data, nil
+}
+
+// ExtractText returns the text of a PDF with one line per row of text, which
+// keeps section headings on lines of their own for the chunker.
+func ExtractText(data []byte) (text string, err error) {
+	// The PDF parser panics on some malformed files instead of returning errors
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to parse PDF: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("failed to open PDF: %w", err)
+	}
+
+	var sb strings.Builder
+	for i := 1; i <= reader.NumPage(); i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+
+		rows, err := page.GetTextByRow()
+		if err != nil {
+			return "", fmt.Errorf("failed to read page %d: %w", i, err)
+		}
+
+		for _, row := range rows {
+			// Words are drawn separately and carry no spacing of their own
+			line := make([]string, 0, len(row.Content))
+			for _, word := range row.Content {
+				if s := strings.TrimSpace(word.S); s != "" {
+					line = append(line, s)
+				}
+			}
+			sb.WriteString(strings.Join(line, " "))
+			sb.WriteString("\n")
+		}
+	}
+
+	text = strings.TrimSpace(sb.String())
+	if text == "" {
+		return "", fmt.Errorf("PDF contains no extractable text")
+	}
+
+	return text, nil
+}
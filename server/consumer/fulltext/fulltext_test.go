@@ -0,0 +1,117 @@
+package fulltext
+
+import (
+	"RAGScholar/consumer/structure"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestExtractTextSeparatesWordsAndRows(t *testing.T) {
+	data, err := os.ReadFile("testdata/paper.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text, err := ExtractText(data)
+	if err != nil {
+		t.Fatalf("ExtractText: %v", err)
+	}
+
+	want := "1 Introduction\nDense retrieval finds related papers.\nSparse retrieval matches keywords."
+	if text != want {
+		t.Errorf("ExtractText = %q, want %q", text, want)
+	}
+
+	chunks := Split(text, DefaultChunkWords, DefaultOverlapWords)
+	if len(chunks) != 1 || chunks[0].Section != "Introduction" {
+		t.Errorf("Split of extracted text = %+v, want one Introduction chunk", chunks)
+	}
+}
+
+func TestExtractTextRejectsInvalidPDF(t *testing.T) {
+	if _, err := ExtractText([]byte("not a pdf")); err == nil {
+		t.Error("ExtractText succeeded on invalid data")
+	}
+}
+
+func newTestFetcher() *Fetcher {
+	return NewFetcher(nil, rate.NewLimiter(rate.Inf, 1))
+}
+
+func TestDownload(t *testing.T) {
+	pdf := []byte("%PDF-1.4 fake")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/pdf/2101.00001v2" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write(pdf)
+	}))
+	defer server.Close()
+
+	fetcher := newTestFetcher()
+
+	data, err := fetcher.Download(context.Background(), server.URL+"/pdf/2101.00001v2")
+	if err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	if !bytes.Equal(data, pdf) {
+		t.Errorf("Download = %q, want %q", data, pdf)
+	}
+
+	_, err = fetcher.Download(context.Background(), server.URL+"/pdf/missing")
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Download of missing PDF: err = %v, want a 404 error", err)
+	}
+}
+
+func TestDownloadRejectsOversizedPDF(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, maxPDFBytes+1))
+	}))
+	defer server.Close()
+
+	if _, err := newTestFetcher().Download(context.Background(), server.URL); err == nil {
+		t.Error("Download accepted a PDF above the size limit")
+	}
+}
+
+func TestDownloadWaitsForLimiter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("pdf"))
+	}))
+	defer server.Close()
+
+	// The burst is used up, so the next download waits past the deadline
+	limiter := rate.NewLimiter(rate.Every(time.Hour), 1)
+	limiter.Allow()
+	fetcher := NewFetcher(nil, limiter)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := fetcher.Download(ctx, server.URL); err == nil {
+		t.Error("Download ignored the limiter")
+	}
+}
+
+func TestPDFLink(t *testing.T) {
+	links := []structure.Link{
+		{Href: "https://arxiv.org/abs/2101.00001v2", Rel: "alternate", Type: "text/html"},
+		{Href: "https://arxiv.org/pdf/2101.00001v2", Rel: "related", Type: "application/pdf"},
+	}
+	if got := PDFLink(links); got != "https://arxiv.org/pdf/2101.00001v2" {
+		t.Errorf("PDFLink = %q", got)
+	}
+	if got := PDFLink(links[:1]); got != "" {
+		t.Errorf("PDFLink without a PDF = %q, want empty", got)
+	}
+}
@@ -0,0 +1,338 @@
+// Package pipeline runs the consumer: it stores the papers of every queued
+// message, then indexes their full text. The consumer binary and
+// `ragscholar dev` both start it with Run.
+package pipeline
+
+import (
+	"RAGScholar/broker"
+	"RAGScholar/config"
+	"RAGScholar/consumer/fulltext"
+	"RAGScholar/consumer/queue"
+	"RAGScholar/consumer/structure"
+	"RAGScholar/consumer/worker"
+	"RAGScholar/embedding"
+	"RAGScholar/gemini"
+	"RAGScholar/migrate"
+	"RAGScholar/vectorstore"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+func initQdrant(cfg config.Qdrant, embedder embedding.Embedder) (*qdrant.Client, error) {
+	client, err := qdrant.NewClient(&qdrant.Config{
+		Host:   cfg.Host,
+		Port:   cfg.Port,
+		APIKey: cfg.APIKey,
+		UseTLS: cfg.UseTLS,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Qdrant client: %w", err)
+	}
+
+	ctx := context.Background()
+
+	// Collections live behind aliases so `ragscholar migrate` can rebuild
+	// them without downtime
+	specs := []migrate.Spec{
+		worker.PaperSpec(cfg.Collection, embedder, cfg.Distance),
+		worker.ChunkSpec(cfg.ChunkCollection, embedder, cfg.Distance),
+	}
+	for _, spec := range specs {
+		if err := migrate.EnsureCollection(ctx, client, cfg.MetadataCollection, spec); err != nil {
+			return nil, err
+		}
+	}
+
+	return client, nil
+}
+
+// task is one queue message together with the entries it carries; the
+// message is acked once the entries are stored
+type task struct {
+	message broker.Delivery
+	entries []structure.SimplifiedEntry
+}
+
+// Run consumes the work queue until ctx is done, storing the papers of each
+// message. A nil broker or vector store is built from cfg; `ragscholar dev`
+// passes in-process ones it shares with the service.
+func Run(ctx context.Context, cfg config.Config, messageBroker broker.Broker, vectorStore vectorstore.VectorStore) error {
+	if messageBroker == nil {
+		if cfg.Broker.Backend == broker.BackendMemory {
+			log.Print("Using the in-process broker; only ragscholar dev publishes to it")
+		}
+		opened, err := broker.Open(cfg.Broker.Backend, cfg.RabbitMQ.URL, cfg.Broker.Path)
+		if err != nil {
+			return err
+		}
+		defer opened.Close()
+		messageBroker = opened
+	}
+
+	queues := queue.NewQueues(cfg.RabbitMQ.Queue)
+	if err := queues.Declare(messageBroker); err != nil {
+		return fmt.Errorf("failed to declare queues: %w", err)
+	}
+
+	// Full-text indexing and the embedding client finish their work after
+	// ctx is done, so they get a context that isn't canceled with it.
+	// Storing entries is canceled, since an unacked message is redelivered.
+	workCtx := context.WithoutCancel(ctx)
+
+	// Initialize Gemini client; it paces each model to the quota Gemini
+	// grants and retries rate-limited and failed calls
+	var geminiClient *gemini.Client
+	if cfg.Embedding.Provider == embedding.ProviderGemini {
+		if cfg.Gemini.APIKey == "" {
+			return errors.New("GEMINI_API_KEY environment variable not set")
+		}
+
+		var err error
+		geminiClient, err = gemini.NewClient(workCtx, cfg.GeminiOptions())
+		if err != nil {
+			return fmt.Errorf("failed to create Gemini client: %w", err)
+		}
+		defer geminiClient.Close()
+	}
+
+	embedder, err := embedding.New(cfg.EmbeddingConfig(), geminiClient)
+	if err != nil {
+		return fmt.Errorf("failed to initialize embedder: %w", err)
+	}
+
+	log.Printf("Using embedding model %s (%d dimensions)", embedder.Model(), embedder.Dimension())
+
+	// Summaries and chunks from every worker share batch requests, so the
+	// workers aren't serialized behind the rate limiter one text at a time
+	batcher := embedding.NewBatcher(embedder, cfg.Consumer.EmbedBatchSize, cfg.Consumer.EmbedBatchDelay)
+	defer batcher.Close()
+
+	// Re-ingested papers reuse the vectors of summaries and chunks that
+	// haven't changed
+	cache, err := embedding.NewCache(batcher, cfg.Embedding.CacheSize, cfg.Embedding.CachePath)
+	if err != nil {
+		return err
+	}
+	defer cache.Close()
+	go logStats(ctx, cache, geminiClient)
+
+	if vectorStore == nil {
+		if cfg.VectorStore.Backend == vectorstore.BackendMemory {
+			log.Print("Using the in-memory vector store; stored papers are lost on exit and not shared with the service")
+			vectorStore = vectorstore.NewMemory()
+		} else {
+			qdrantClient, err := initQdrant(cfg.Qdrant, embedder)
+			if err != nil {
+				return fmt.Errorf("failed to initialize Qdrant: %w", err)
+			}
+			defer qdrantClient.Close()
+			vectorStore = vectorstore.NewQdrant(qdrantClient)
+		}
+	}
+
+	// Full-text stage: downloads PDFs of stored entries and indexes their chunks
+	fullTextEnabled := cfg.Consumer.FullTextEnabled
+
+	// Ingestion workers hand entries to an unbounded backlog, so storing and
+	// acking messages never waits on the rate-limited PDF downloads
+	const numFullTextWorkers = 2
+	fullTextBacklog := make(chan structure.SimplifiedEntry)
+	fullTextChan := make(chan structure.SimplifiedEntry)
+	go backlog(fullTextBacklog, fullTextChan)
+	var fullTextWg sync.WaitGroup
+
+	if fullTextEnabled {
+		fetcher := fulltext.NewFetcher(nil, nil)
+		for i := 0; i < numFullTextWorkers; i++ {
+			fullTextWg.Add(1)
+			go func(workerId int) {
+				defer fullTextWg.Done()
+				log.Printf("Full-text worker %d started", workerId)
+				for entry := range fullTextChan {
+					if err := worker.StoreFullText(workCtx, vectorStore, cfg.Qdrant.ChunkCollection, fetcher, entry, cache); err != nil {
+						log.Printf("Full-text worker %d: Failed to index entry %s: %v", workerId, entry.ID, err)
+					}
+				}
+				log.Printf("Full-text worker %d stopped", workerId)
+			}(i)
+		}
+	} else {
+		log.Println("Full-text ingestion disabled")
+	}
+
+	// Worker pool
+	numWorkers := cfg.Consumer.Workers
+	taskChan := make(chan task, 10)
+	var wg sync.WaitGroup
+
+	// A failed batch is retried after retryDelay, up to maxRetries times,
+	// before it is moved to the dead-letter queue
+	maxRetries, retryDelay := cfg.Consumer.MaxRetries, cfg.Consumer.RetryDelay
+	handleFailure := func(message broker.Delivery, reason string) {
+		var err error
+		if attempt := queue.RetryCount(message); attempt < maxRetries {
+			log.Printf("Retrying message in %s (attempt %d of %d): %s", retryDelay, attempt+1, maxRetries, reason)
+			err = queues.ScheduleRetry(messageBroker, message, retryDelay, reason)
+		} else {
+			err = queues.DeadLetter(messageBroker, message, reason)
+		}
+		if err != nil {
+			// Leave the message with the broker rather than losing it
+			log.Printf("Failed to reroute message, requeueing it: %v", err)
+			message.Nack(true)
+		}
+	}
+
+	// Start workers
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func(workerId int) {
+			defer wg.Done()
+			log.Printf("Worker %d started", workerId)
+			for t := range taskChan {
+				entries := t.entries
+				// Only new papers and new versions need their full text
+				// (re)indexed; older and unchanged versions keep their chunks
+				advanced, err := worker.StoreEntries(ctx, vectorStore, cfg.Qdrant.Collection, entries, cache)
+				if err != nil && ctx.Err() != nil {
+					// Shutting down canceled the writes; the message goes
+					// back to the queue without using up a retry
+					log.Printf("Worker %d: Stopped storing entries at shutdown, requeueing them: %v", workerId, err)
+					t.message.Nack(true)
+				} else if err != nil {
+					log.Printf("Worker %d: Failed to store entries: %v", workerId, err)
+					handleFailure(t.message, err.Error())
+				} else {
+					if err := t.message.Ack(); err != nil {
+						log.Printf("Worker %d: Failed to ack message: %v", workerId, err)
+					}
+					log.Printf("Worker %d: Stored %d entries", workerId, len(entries))
+				}
+				if fullTextEnabled {
+					for _, entry := range advanced {
+						fullTextBacklog <- entry
+					}
+				}
+			}
+			log.Printf("Worker %d stopped", workerId)
+		}(i)
+	}
+
+	// Consume messages; each is acked only after its entries are stored.
+	// Unacked deliveries are bounded so a slow consumer doesn't hoard the
+	// queue.
+	messages, err := messageBroker.Consume(ctx, queues.Main, numWorkers*2)
+	if err != nil {
+		close(taskChan)
+		close(fullTextBacklog)
+		return fmt.Errorf("failed to consume messages: %w", err)
+	}
+
+	log.Println("Connected to the queue, waiting for messages...")
+
+	// Main loop
+loop:
+	for {
+		select {
+		case message, ok := <-messages:
+			if !ok {
+				log.Println("Message channel closed")
+				break loop
+			}
+
+			var entries []structure.SimplifiedEntry
+			if err := json.Unmarshal(message.Body, &entries); err != nil {
+				log.Printf("Failed to parse message: %v", err)
+				// Malformed messages will never parse, so skip the retries
+				if err := queues.DeadLetter(messageBroker, message, fmt.Sprintf("invalid message body: %v", err)); err != nil {
+					// Leave the message with the broker rather than losing it
+					log.Printf("Failed to dead-letter message, requeueing it: %v", err)
+					message.Nack(true)
+				}
+				continue
+			}
+
+			log.Printf("Received message with %d entries", len(entries))
+
+			taskChan <- task{message: message, entries: entries}
+
+		case <-ctx.Done():
+			log.Println("Shutting down...")
+			break loop
+		}
+	}
+
+	close(taskChan)
+	log.Println("Waiting for in-flight tasks to complete...")
+	wg.Wait()
+	close(fullTextBacklog)
+	fullTextWg.Wait()
+	log.Printf("Embedding cache: %s", cache)
+	if geminiClient != nil {
+		log.Printf("Gemini usage: %s", geminiClient)
+	}
+	log.Println("Shutdown complete")
+	return nil
+}
+
+// backlog forwards entries from in to out, queueing as many as it has to so
+// that sends on in never block. out is closed once in is closed and every
+// queued entry has been forwarded.
+func backlog(in <-chan structure.SimplifiedEntry, out chan<- structure.SimplifiedEntry) {
+	defer close(out)
+
+	var queued []structure.SimplifiedEntry
+	for in != nil || len(queued) > 0 {
+		// A nil channel disables its case: nothing to send while the queue
+		// is empty, and nothing to receive once in is closed
+		var send chan<- structure.SimplifiedEntry
+		var next structure.SimplifiedEntry
+		if len(queued) > 0 {
+			send, next = out, queued[0]
+		}
+
+		select {
+		case entry, ok := <-in:
+			if !ok {
+				in = nil
+				continue
+			}
+			queued = append(queued, entry)
+		case send <- next:
+			queued = queued[1:]
+		}
+	}
+}
+
+const statsInterval = 10 * time.Minute
+
+// logStats logs the embedding cache's hit rate and the Gemini usage
+// periodically while the consumer is busy. geminiClient is nil when
+// embedding locally.
+func logStats(ctx context.Context, cache *embedding.Cache, geminiClient *gemini.Client) {
+	ticker := time.NewTicker(statsInterval)
+	defer ticker.Stop()
+
+	var lookups uint64
+	for {
+		select {
+		case <-ticker.C:
+			if stats := cache.Stats(); stats.Lookups != lookups {
+				lookups = stats.Lookups
+				log.Printf("Embedding cache: %s", cache)
+				if geminiClient != nil {
+					log.Printf("Gemini usage: %s", geminiClient)
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
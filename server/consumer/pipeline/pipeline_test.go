@@ -0,0 +1,41 @@
+package pipeline
+
+import (
+	"RAGScholar/consumer/structure"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestBacklogNeverBlocksSenders(t *testing.T) {
+	in := make(chan structure.SimplifiedEntry)
+	out := make(chan structure.SimplifiedEntry)
+	go backlog(in, out)
+
+	// Nothing reads out yet, which is the full-text workers being busy
+	sent := make(chan struct{})
+	go func() {
+		for i := 0; i < 1000; i++ {
+			in <- structure.SimplifiedEntry{ID: strconv.Itoa(i)}
+		}
+		close(in)
+		close(sent)
+	}()
+
+	select {
+	case <-sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("sends blocked while the receiver was busy")
+	}
+
+	received := 0
+	for entry := range out {
+		if entry.ID != strconv.Itoa(received) {
+			t.Fatalf("entry %d arrived as %s", received, entry.ID)
+		}
+		received++
+	}
+	if received != 1000 {
+		t.Errorf("received %d entries, want 1000", received)
+	}
+}
@@ -0,0 +1,152 @@
+package queue
+
+import (
+	"RAGScholar/broker"
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	retryCountHeader    = "x-retry-count"
+	failureReasonHeader = "x-failure-reason"
+	failedAtHeader      = "x-failed-at"
+)
+
+// Queues names the main work queue and the queues derived from it: messages
+// waiting to be retried sit in Retry until their expiration dead-letters them
+// back into Main, and messages that exhausted their retries end up in DLQ.
+type Queues struct {
+	Main  string
+	Retry string
+	DLQ   string
+}
+
+func NewQueues(main string) Queues {
+	return Queues{
+		Main:  main,
+		Retry: main + ".retry",
+		DLQ:   main + ".dlq",
+	}
+}
+
+// Declare declares all three queues. The main queue keeps the options the
+// service declares it with.
+func (q Queues) Declare(b broker.Broker) error {
+	if err := b.Declare(q.Main, broker.QueueOptions{}); err != nil {
+		return err
+	}
+	if err := b.Declare(q.Retry, broker.QueueOptions{DeadLetterTo: q.Main}); err != nil {
+		return err
+	}
+	return b.Declare(q.DLQ, broker.QueueOptions{})
+}
+
+// RetryCount returns how many times the message has already been retried
+func RetryCount(message broker.Delivery) int {
+	switch v := message.Headers[retryCountHeader].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case int:
+		return v
+	case float64:
+		// Headers restored from a file-backed broker's journal
+		return int(v)
+	}
+	return 0
+}
+
+// ScheduleRetry schedules the message to be redelivered to the main queue after
+// delay and acks the original delivery.
+func (q Queues) ScheduleRetry(b broker.Broker, message broker.Delivery, delay time.Duration, reason string) error {
+	headers := copyHeaders(message.Headers)
+	headers[retryCountHeader] = int32(RetryCount(message) + 1)
+	headers[failureReasonHeader] = reason
+
+	err := b.Publish(context.Background(), q.Retry, broker.Message{
+		ContentType: message.ContentType,
+		Headers:     headers,
+		Expiration:  delay,
+		Body:        message.Body,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", q.Retry, err)
+	}
+
+	return message.Ack()
+}
+
+// DeadLetter moves the message to the dead-letter queue with the reason it
+// failed and acks the original delivery.
+func (q Queues) DeadLetter(b broker.Broker, message broker.Delivery, reason string) error {
+	headers := copyHeaders(message.Headers)
+	headers[failureReasonHeader] = reason
+	headers[failedAtHeader] = time.Now().UTC().Format(time.RFC3339)
+
+	err := b.Publish(context.Background(), q.DLQ, broker.Message{
+		ContentType: message.ContentType,
+		Headers:     headers,
+		Body:        message.Body,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", q.DLQ, err)
+	}
+
+	log.Printf("Moved message to %s: %s", q.DLQ, reason)
+	return message.Ack()
+}
+
+// ReplayDLQ moves every message currently in the dead-letter queue back to
+// the main queue with its retry count reset, and returns how many it moved.
+func (q Queues) ReplayDLQ(b broker.Broker) (int, error) {
+	waiting, err := b.Len(q.DLQ)
+	if err != nil {
+		return 0, err
+	}
+
+	// Only replay what was there when we started, in case replayed messages
+	// fail again and land back in the DLQ while we are still draining it
+	replayed := 0
+	for replayed < waiting {
+		message, ok, err := b.Get(q.DLQ)
+		if err != nil {
+			return replayed, fmt.Errorf("failed to read from %s: %w", q.DLQ, err)
+		}
+		if !ok {
+			break
+		}
+
+		headers := copyHeaders(message.Headers)
+		delete(headers, retryCountHeader)
+		delete(headers, failureReasonHeader)
+		delete(headers, failedAtHeader)
+
+		err = b.Publish(context.Background(), q.Main, broker.Message{
+			ContentType: message.ContentType,
+			Headers:     headers,
+			Body:        message.Body,
+		})
+		if err != nil {
+			message.Nack(true)
+			return replayed, fmt.Errorf("failed to publish to %s: %w", q.Main, err)
+		}
+
+		if err := message.Ack(); err != nil {
+			return replayed, err
+		}
+		replayed++
+	}
+
+	return replayed, nil
+}
+
+func copyHeaders(headers map[string]any) map[string]any {
+	copied := map[string]any{}
+	for k, v := range headers {
+		copied[k] = v
+	}
+	return copied
+}
@@ -0,0 +1,190 @@
+package queue
+
+import (
+	"RAGScholar/broker"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newQueues(t *testing.T, b broker.Broker) Queues {
+	t.Helper()
+	queues := NewQueues("papers")
+	if err := queues.Declare(b); err != nil {
+		t.Fatal(err)
+	}
+	return queues
+}
+
+func publish(t *testing.T, b broker.Broker, queue string, body string) {
+	t.Helper()
+	err := b.Publish(context.Background(), queue, broker.Message{ContentType: "application/json", Body: []byte(body)})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func get(t *testing.T, b broker.Broker, queue string) broker.Delivery {
+	t.Helper()
+	message, ok, err := b.Get(queue)
+	if err != nil || !ok {
+		t.Fatalf("Get(%s) = %v, %v, want a message", queue, ok, err)
+	}
+	return message
+}
+
+// waitFor gets the next message from queue, waiting for it to arrive
+func waitFor(t *testing.T, b broker.Broker, queue string) broker.Delivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		message, ok, err := b.Get(queue)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			return message
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("no message arrived in %s", queue)
+	return broker.Delivery{}
+}
+
+func length(t *testing.T, b broker.Broker, queue string) int {
+	t.Helper()
+	n, err := b.Len(queue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestScheduleRetryCountsAttempts(t *testing.T) {
+	b := broker.NewMemory()
+	defer b.Close()
+	queues := newQueues(t, b)
+
+	publish(t, b, queues.Main, "[]")
+	message := get(t, b, queues.Main)
+	if got := RetryCount(message); got != 0 {
+		t.Fatalf("first delivery has retry count %d, want 0", got)
+	}
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		if err := queues.ScheduleRetry(b, message, 10*time.Millisecond, "embedding failed"); err != nil {
+			t.Fatal(err)
+		}
+		if got := length(t, b, queues.Main); got != 0 {
+			t.Fatalf("retry %d is back in the main queue before its delay", attempt)
+		}
+
+		// The retry queue dead-letters the message back once it expires
+		message = waitFor(t, b, queues.Main)
+		if got := RetryCount(message); got != attempt {
+			t.Errorf("retry %d has retry count %d", attempt, got)
+		}
+		if got := message.Headers[failureReasonHeader]; got != "embedding failed" {
+			t.Errorf("retry %d has failure reason %v", attempt, got)
+		}
+		if string(message.Body) != "[]" || message.ContentType != "application/json" {
+			t.Errorf("retry %d changed the message to %q (%s)", attempt, message.Body, message.ContentType)
+		}
+	}
+
+	if err := message.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	if got := length(t, b, queues.Retry); got != 0 {
+		t.Errorf("%d messages left in the retry queue", got)
+	}
+}
+
+func TestDeadLetterAndReplay(t *testing.T) {
+	b := broker.NewMemory()
+	defer b.Close()
+	queues := newQueues(t, b)
+
+	publish(t, b, queues.Main, "first")
+	publish(t, b, queues.Main, "second")
+	for _, message := range []broker.Delivery{get(t, b, queues.Main), get(t, b, queues.Main)} {
+		if err := queues.ScheduleRetry(b, message, time.Millisecond, "failed"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if err := queues.DeadLetter(b, waitFor(t, b, queues.Main), "gave up"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := length(t, b, queues.DLQ); got != 2 {
+		t.Fatalf("DLQ holds %d messages, want 2", got)
+	}
+	dead := get(t, b, queues.DLQ)
+	if dead.Headers[failureReasonHeader] != "gave up" || dead.Headers[failedAtHeader] == nil {
+		t.Errorf("dead-lettered message headers = %v, want the reason and time", dead.Headers)
+	}
+	if got := RetryCount(dead); got != 1 {
+		t.Errorf("dead-lettered message has retry count %d, want the 1 it reached", got)
+	}
+	if err := dead.Nack(true); err != nil {
+		t.Fatal(err)
+	}
+
+	replayed, err := queues.ReplayDLQ(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed != 2 {
+		t.Errorf("replayed %d messages, want 2", replayed)
+	}
+	if got := length(t, b, queues.DLQ); got != 0 {
+		t.Errorf("DLQ still holds %d messages", got)
+	}
+
+	bodies := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		message := get(t, b, queues.Main)
+		bodies[string(message.Body)] = true
+		if len(message.Headers) != 0 {
+			t.Errorf("replayed message kept headers %v", message.Headers)
+		}
+		if got := RetryCount(message); got != 0 {
+			t.Errorf("replayed message has retry count %d, want it reset", got)
+		}
+	}
+	if !bodies["first"] || !bodies["second"] {
+		t.Errorf("replayed %v, want both messages", bodies)
+	}
+}
+
+func TestRetryCountSurvivesJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.journal")
+	b, err := broker.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queues := newQueues(t, b)
+
+	publish(t, b, queues.Main, "[]")
+	if err := queues.ScheduleRetry(b, get(t, b, queues.Main), time.Hour, "failed"); err != nil {
+		t.Fatal(err)
+	}
+	message := get(t, b, queues.Retry)
+	if err := queues.ScheduleRetry(b, message, time.Hour, "failed again"); err != nil {
+		t.Fatal(err)
+	}
+	b.Close()
+
+	// The journal stores headers as JSON, so the count comes back a float
+	b, err = broker.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if got := RetryCount(get(t, b, queues.Retry)); got != 2 {
+		t.Errorf("retry count after reopening = %d, want 2", got)
+	}
+}
@@ -1,27 +1,11 @@
 package structure
 
+import "RAGScholar/domain"
 
-type SimplifiedEntry struct {
-	ID              string   `json:"id"`
-	Updated         string   `json:"updated"`
-	Published       string   `json:"published"`
-	Title           string   `json:"title"`
-	Summary         string   `json:"summary"`
-	Authors         []Author `json:"authors"`
-	Comment         string   `json:"comment"`
-	Links           []Link   `json:"links"`
-	PrimaryCategory string   `json:"primaryCategory"`
-	Categories      []string `json:"categories"`
-	DOI             string   `json:"doi"`
-	JournalRef      string   `json:"journalRef"`
-}
+// The consumer decodes the entries the service publishes, so both sides
+// share the domain types
+type SimplifiedEntry = domain.Entry
 
-type Author struct {
-	Name string `json:"name"`
-}
+type Author = domain.Author
 
-type Link struct {
-	Href string `json:"href"`
-	Rel  string `json:"rel"`
-	Type string `json:"type"`
-}
\ No newline at end of file
+type Link = domain.Link
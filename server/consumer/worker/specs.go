@@ -0,0 +1,51 @@
+package worker
+
+import (
+	"RAGScholar/domain"
+	"RAGScholar/embedding"
+	"RAGScholar/migrate"
+	"RAGScholar/payload"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// PaperSpec describes the papers collection as StoreEntries writes it.
+// Migrations decode stored payloads, normalize them and encode them in the
+// current schema, and embed the summary as StoreEntries does.
+func PaperSpec(alias string, embedder embedding.Embedder, distance string) migrate.Spec {
+	return migrate.Spec{
+		Alias:          alias,
+		SchemaVersion:  payload.SchemaVersion,
+		EmbeddingModel: embedder.Model(),
+		VectorSize:     uint64(embedder.Dimension()),
+		Distance:       distance,
+		Indexes:        payload.PaperIndexes,
+		Remap: func(fields map[string]*qdrant.Value) (map[string]*qdrant.Value, error) {
+			entry, err := domain.DecodePayload(fields)
+			if err != nil {
+				return nil, err
+			}
+			entry.Normalize()
+			return domain.EncodePayload(entry)
+		},
+		EmbedText: func(fields map[string]*qdrant.Value) string {
+			return fields["summary"].GetStringValue()
+		},
+	}
+}
+
+// ChunkSpec describes the full-text chunk collection as StoreFullText
+// writes it; chunks are re-embedded from their stored text
+func ChunkSpec(alias string, embedder embedding.Embedder, distance string) migrate.Spec {
+	return migrate.Spec{
+		Alias:          alias,
+		SchemaVersion:  payload.ChunkSchemaVersion,
+		EmbeddingModel: embedder.Model(),
+		VectorSize:     uint64(embedder.Dimension()),
+		Distance:       distance,
+		Indexes:        payload.ChunkIndexes,
+		EmbedText: func(fields map[string]*qdrant.Value) string {
+			return fields[payload.FieldChunkText].GetStringValue()
+		},
+	}
+}
@@ -1,87 +1,104 @@
 package worker
 
 import (
+	"RAGScholar/arxiv"
+	"RAGScholar/consumer/fulltext"
 	"RAGScholar/consumer/structure"
+	"RAGScholar/domain"
+	"RAGScholar/embedding"
+	"RAGScholar/payload"
+	"RAGScholar/vectorstore"
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
-	"github.com/google/generative-ai-go/genai"
-	"github.com/google/uuid"
 	"github.com/qdrant/go-client/qdrant"
-	"golang.org/x/time/rate"
 )
 
-const (
-	queueName      = "paper-fetcher"
-	collectionName = "papers"
-	vectorSize     = 768 // Gemini text embeddings are 768-dimensional vectors
-)
-
-func GenerateEmbedding(client *genai.Client, model *genai.EmbeddingModel, text string, limiter *rate.Limiter) ([]float32, error) {
-	ctx := context.Background()
+const maxChunksPerPaper = 64
 
-	if err := limiter.Wait(ctx); err != nil {
-		return nil, err
+// StoreEntries embeds the entries' summaries in batches and upserts them into
+// the papers collection, skipping entries older than the version already
+// stored and entries whose summary the embedding model rejects.
+//
+// It returns the upserted entries that are new or a higher version than the
+// one stored, which are the ones whose full text needs (re)indexing. They are
+// returned even when the error reports other entries that failed to embed,
+// since a retry finds them already stored.
+func StoreEntries(ctx context.Context, store vectorstore.VectorStore, collectionName string, entries []structure.SimplifiedEntry, embedder embedding.Embedder) ([]structure.SimplifiedEntry, error) {
+	if len(entries) == 0 {
+		log.Println("Warning: Received empty batch of entries to store")
+		return nil, nil
 	}
 
-	if strings.TrimSpace(text) == "" {
-		return nil, fmt.Errorf("cannot generate embedding for empty text")
-	}
+	entries = latestVersions(entries)
 
-	resp, err := model.EmbedContent(ctx, genai.Text(text))
+	storedVersions, err := fetchStoredVersions(ctx, store, collectionName, entries)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(resp.Embedding.Values) == 0 {
-		return nil, fmt.Errorf("received empty embedding from Gemini")
-	}
-
-	embedding := make([]float32, len(resp.Embedding.Values))
-	for i, v := range resp.Embedding.Values {
-		embedding[i] = float32(v)
-	}
-
-	log.Printf("Generated embedding with size: %d", len(embedding))
-
-	return embedding, nil
-}
-
-func StoreInQdrant(qdrantClient *qdrant.Client, entries []structure.SimplifiedEntry, geminiClient *genai.Client, embeddingModel *genai.EmbeddingModel, limiter *rate.Limiter) error {
-	ctx := context.Background()
-
-	if len(entries) == 0 {
-		log.Println("Warning: Received empty batch of entries to store")
-		return nil
-	}
-
-	points := make([]*qdrant.PointStruct, 0, len(entries))
-
+	pending := make([]structure.SimplifiedEntry, 0, len(entries))
 	for _, entry := range entries {
 		if strings.TrimSpace(entry.Summary) == "" {
 			log.Printf("Entry %s has empty summary, skipping", entry.ID)
 			continue
 		}
 
-		vector, err := GenerateEmbedding(geminiClient, embeddingModel, entry.Summary, limiter)
-		if err != nil {
+		if stored, ok := storedVersions[arxiv.PointID(entry.ID)]; ok && stored.newerThan(entry) {
+			log.Printf("Entry %s is older than the stored version, skipping", entry.ID)
+			continue
+		}
+
+		pending = append(pending, entry)
+	}
+
+	summaries := make([]string, len(pending))
+	for i, entry := range pending {
+		summaries[i] = entry.Summary
+	}
+	vectors, errs := embedding.EmbedEach(ctx, embedder, summaries)
+
+	points := make([]*qdrant.PointStruct, 0, len(pending))
+	var advanced []structure.SimplifiedEntry
+	var embedErr error
+	failed := 0
+
+	for i, entry := range pending {
+		if err := errs[i]; err != nil {
+			if errors.Is(err, embedding.ErrInvalidInput) {
+				// The model will reject it again, so retrying wouldn't help
+				log.Printf("Embedding model rejected entry %s, skipping: %v", entry.ID, err)
+				continue
+			}
 			log.Printf("Failed to generate embedding for entry %s: %v", entry.ID, err)
+			embedErr = err
+			failed++
 			continue
 		}
 
-		if len(vector) != vectorSize {
+		vector := vectors[i]
+		if len(vector) != embedder.Dimension() {
 			log.Printf("Warning: Generated vector size (%d) doesn't match expected size (%d)",
-				len(vector), vectorSize)
+				len(vector), embedder.Dimension())
 		}
 
-		payload := ConvertToQdrantPayload(entry)
+		entry.Normalize()
+		payload, err := domain.EncodePayload(entry)
+		if err != nil {
+			// Encoding is deterministic, so retrying the batch wouldn't help
+			log.Printf("Failed to encode entry %s, skipping: %v", entry.ID, err)
+			continue
+		}
 
 		point := &qdrant.PointStruct{
 			Id: &qdrant.PointId{
 				PointIdOptions: &qdrant.PointId_Uuid{
-					Uuid: uuid.New().String(),
+					Uuid: arxiv.PointID(entry.ID),
 				},
 			},
 			Vectors: &qdrant.Vectors{
@@ -95,87 +112,204 @@ func StoreInQdrant(qdrantClient *qdrant.Client, entries []structure.SimplifiedEn
 		}
 
 		points = append(points, point)
+
+		if stored, ok := storedVersions[arxiv.PointID(entry.ID)]; !ok || stored.olderThan(entry) {
+			advanced = append(advanced, entry)
+		}
 	}
 
 	if len(points) == 0 {
 		log.Println("Warning: No valid points to store after processing")
-		return nil
+		return nil, embeddingFailure(embedErr, failed, len(entries))
 	}
 
 	log.Printf("Sending upsert request with %d points", len(points))
 
-	_, err := qdrantClient.Upsert(ctx, &qdrant.UpsertPoints{
+	err = store.Upsert(ctx, &qdrant.UpsertPoints{
 		CollectionName: collectionName,
 		Points:         points,
 	})
 
 	if err != nil {
 		log.Printf("Upsert error details: %v", err)
-		return err
+		return nil, err
 	}
 
-	log.Printf("Successfully stored %d points in Qdrant", len(points))
-	return nil
+	log.Printf("Successfully stored %d points", len(points))
+	return advanced, embeddingFailure(embedErr, failed, len(entries))
 }
 
-func ConvertToQdrantPayload(entry structure.SimplifiedEntry) map[string]*qdrant.Value {
-	payload := make(map[string]*qdrant.Value)
-
-	payload["id"] = &qdrant.Value{Kind: &qdrant.Value_StringValue{StringValue: entry.ID}}
-	payload["updated"] = &qdrant.Value{Kind: &qdrant.Value_StringValue{StringValue: entry.Updated}}
-	payload["published"] = &qdrant.Value{Kind: &qdrant.Value_StringValue{StringValue: entry.Published}}
-	payload["title"] = &qdrant.Value{Kind: &qdrant.Value_StringValue{StringValue: entry.Title}}
-	payload["summary"] = &qdrant.Value{Kind: &qdrant.Value_StringValue{StringValue: entry.Summary}}
-	payload["comment"] = &qdrant.Value{Kind: &qdrant.Value_StringValue{StringValue: entry.Comment}}
-	payload["primaryCategory"] = &qdrant.Value{Kind: &qdrant.Value_StringValue{StringValue: entry.PrimaryCategory}}
-	payload["doi"] = &qdrant.Value{Kind: &qdrant.Value_StringValue{StringValue: entry.DOI}}
-	payload["journalRef"] = &qdrant.Value{Kind: &qdrant.Value_StringValue{StringValue: entry.JournalRef}}
-
-	authorsArray := make([]*qdrant.Value, len(entry.Authors))
-	for i, author := range entry.Authors {
-		authorMap := map[string]*qdrant.Value{
-			"name": {Kind: &qdrant.Value_StringValue{StringValue: author.Name}},
+// embeddingFailure reports entries whose embedding failed so the batch is
+// retried. The points that did embed are already stored, and because point
+// IDs are deterministic the retry simply overwrites them.
+func embeddingFailure(err error, failed int, total int) error {
+	if failed == 0 {
+		return nil
+	}
+	return fmt.Errorf("failed to embed %d of %d entries: %w", failed, total, err)
+}
+
+// latestVersions drops entries that are superseded by a newer version of the
+// same paper within the batch, keeping the original order otherwise.
+func latestVersions(entries []structure.SimplifiedEntry) []structure.SimplifiedEntry {
+	latest := make(map[string]int, len(entries))
+	for i, entry := range entries {
+		key := arxiv.NormalizeID(entry.ID)
+		if j, ok := latest[key]; !ok || arxiv.Version(entry.ID) > arxiv.Version(entries[j].ID) {
+			latest[key] = i
 		}
-		authorsArray[i] = &qdrant.Value{Kind: &qdrant.Value_StructValue{
-			StructValue: &qdrant.Struct{
-				Fields: authorMap,
-			},
-		}}
-	}
-	payload["authors"] = &qdrant.Value{Kind: &qdrant.Value_ListValue{
-		ListValue: &qdrant.ListValue{
-			Values: authorsArray,
-		},
-	}}
-
-	linksArray := make([]*qdrant.Value, len(entry.Links))
-	for i, link := range entry.Links {
-		linkMap := map[string]*qdrant.Value{
-			"href": {Kind: &qdrant.Value_StringValue{StringValue: link.Href}},
-			"rel":  {Kind: &qdrant.Value_StringValue{StringValue: link.Rel}},
-			"type": {Kind: &qdrant.Value_StringValue{StringValue: link.Type}},
+	}
+
+	result := make([]structure.SimplifiedEntry, 0, len(latest))
+	for i, entry := range entries {
+		if latest[arxiv.NormalizeID(entry.ID)] == i {
+			result = append(result, entry)
 		}
-		linksArray[i] = &qdrant.Value{Kind: &qdrant.Value_StructValue{
-			StructValue: &qdrant.Struct{
-				Fields: linkMap,
+	}
+	return result
+}
+
+// storedVersion is the version of a paper already stored, and when that
+// version was last updated (Unix seconds, 0 if unknown)
+type storedVersion struct {
+	version   int
+	updatedAt int64
+}
+
+// compare orders the entry against the stored version: negative if the
+// entry is older, positive if it is newer. Versions are compared when both
+// are known; entries without one, such as records in the OAI-PMH arXiv
+// format, are compared by their updated timestamp instead.
+func (s storedVersion) compare(entry structure.SimplifiedEntry) int {
+	if version := arxiv.Version(entry.ID); version > 0 && s.version > 0 {
+		return version - s.version
+	}
+
+	updated, err := time.Parse(time.RFC3339, entry.Updated)
+	if err != nil || s.updatedAt == 0 {
+		return 0
+	}
+	return cmp.Compare(updated.Unix(), s.updatedAt)
+}
+
+func (s storedVersion) newerThan(entry structure.SimplifiedEntry) bool {
+	return s.compare(entry) < 0
+}
+
+func (s storedVersion) olderThan(entry structure.SimplifiedEntry) bool {
+	return s.compare(entry) > 0
+}
+
+// fetchStoredVersions looks up the arXiv version already stored for each
+// entry's point, keyed by point ID.
+func fetchStoredVersions(ctx context.Context, store vectorstore.VectorStore, collectionName string, entries []structure.SimplifiedEntry) (map[string]storedVersion, error) {
+	ids := make([]*qdrant.PointId, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, qdrant.NewIDUUID(arxiv.PointID(entry.ID)))
+	}
+
+	existing, err := store.Get(ctx, &qdrant.GetPoints{
+		CollectionName: collectionName,
+		Ids:            ids,
+		WithPayload:    qdrant.NewWithPayloadInclude(payload.FieldVersion, payload.FieldUpdatedAt),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up stored versions: %w", err)
+	}
+
+	versions := make(map[string]storedVersion, len(existing))
+	for _, point := range existing {
+		versions[point.Id.GetUuid()] = storedVersion{
+			version:   int(point.Payload[payload.FieldVersion].GetIntegerValue()),
+			updatedAt: point.Payload[payload.FieldUpdatedAt].GetIntegerValue(),
+		}
+	}
+	return versions, nil
+}
+
+// StoreFullText downloads the entry's PDF, splits its text into chunks and
+// stores one point per chunk in the chunk collection, replacing any chunks
+// stored for an earlier version of the paper.
+func StoreFullText(ctx context.Context, store vectorstore.VectorStore, chunkCollectionName string, fetcher *fulltext.Fetcher, entry structure.SimplifiedEntry, embedder embedding.Embedder) error {
+	link := fulltext.PDFLink(entry.Links)
+	if link == "" {
+		log.Printf("Entry %s has no PDF link, skipping full text", entry.ID)
+		return nil
+	}
+
+	data, err := fetcher.Download(ctx, link)
+	if err != nil {
+		return err
+	}
+
+	text, err := fulltext.ExtractText(data)
+	if err != nil {
+		return fmt.Errorf("failed to extract text for entry %s: %w", entry.ID, err)
+	}
+
+	chunks := fulltext.Split(text, fulltext.DefaultChunkWords, fulltext.DefaultOverlapWords)
+	if len(chunks) > maxChunksPerPaper {
+		log.Printf("Entry %s has %d chunks, keeping the first %d", entry.ID, len(chunks), maxChunksPerPaper)
+		chunks = chunks[:maxChunksPerPaper]
+	}
+	if len(chunks) == 0 {
+		log.Printf("Entry %s produced no text chunks", entry.ID)
+		return nil
+	}
+
+	texts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		texts[i] = chunk.Text
+	}
+
+	var vectors [][]float32
+	for start := 0; start < len(texts); start += embedding.MaxBatchSize {
+		end := min(start+embedding.MaxBatchSize, len(texts))
+		batch, err := embedder.EmbedBatch(ctx, texts[start:end])
+		if err != nil {
+			return fmt.Errorf("failed to embed chunks for entry %s: %w", entry.ID, err)
+		}
+		vectors = append(vectors, batch...)
+	}
+
+	paperID := arxiv.NormalizeID(entry.ID)
+	paperPointID := arxiv.PointID(entry.ID)
+
+	points := make([]*qdrant.PointStruct, len(chunks))
+	for i, chunk := range chunks {
+		points[i] = &qdrant.PointStruct{
+			Id:      qdrant.NewIDUUID(arxiv.ChunkPointID(entry.ID, chunk.Index)),
+			Vectors: qdrant.NewVectorsDense(vectors[i]),
+			Payload: map[string]*qdrant.Value{
+				payload.FieldChunkPaperID:      qdrant.NewValueString(paperID),
+				payload.FieldChunkPaperPointID: qdrant.NewValueString(paperPointID),
+				payload.FieldChunkSourceID:     qdrant.NewValueString(entry.ID),
+				payload.FieldChunkTitle:        qdrant.NewValueString(entry.Title),
+				payload.FieldChunkSection:      qdrant.NewValueString(chunk.Section),
+				payload.FieldChunkIndex:        qdrant.NewValueInt(int64(chunk.Index)),
+				payload.FieldChunkText:         qdrant.NewValueString(chunk.Text),
 			},
-		}}
+		}
+	}
+
+	err = store.Delete(ctx, &qdrant.DeletePoints{
+		CollectionName: chunkCollectionName,
+		Points: qdrant.NewPointsSelectorFilter(&qdrant.Filter{
+			Must: []*qdrant.Condition{qdrant.NewMatch(payload.FieldChunkPaperID, paperID)},
+		}),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete old chunks for entry %s: %w", entry.ID, err)
 	}
-	payload["links"] = &qdrant.Value{Kind: &qdrant.Value_ListValue{
-		ListValue: &qdrant.ListValue{
-			Values: linksArray,
-		},
-	}}
 
-	categoriesArray := make([]*qdrant.Value, len(entry.Categories))
-	for i, category := range entry.Categories {
-		categoriesArray[i] = &qdrant.Value{Kind: &qdrant.Value_StringValue{StringValue: category}}
+	err = store.Upsert(ctx, &qdrant.UpsertPoints{
+		CollectionName: chunkCollectionName,
+		Points:         points,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store chunks for entry %s: %w", entry.ID, err)
 	}
-	payload["categories"] = &qdrant.Value{Kind: &qdrant.Value_ListValue{
-		ListValue: &qdrant.ListValue{
-			Values: categoriesArray,
-		},
-	}}
 
-	return payload
+	log.Printf("Stored %d full-text chunks for entry %s", len(points), entry.ID)
+	return nil
 }
@@ -0,0 +1,144 @@
+package worker
+
+import (
+	"RAGScholar/arxiv"
+	"RAGScholar/consumer/structure"
+	"RAGScholar/embedding"
+	"RAGScholar/vectorstore"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+func paper(id string, summary string) structure.SimplifiedEntry {
+	return structure.SimplifiedEntry{
+		ID:         "http://arxiv.org/abs/" + id,
+		Updated:    "2024-01-02T00:00:00Z",
+		Published:  "2024-01-01T00:00:00Z",
+		Title:      "Paper " + id,
+		Summary:    summary,
+		Categories: []string{"cs.IR"},
+	}
+}
+
+func ids(entries []structure.SimplifiedEntry) []string {
+	result := make([]string, len(entries))
+	for i, entry := range entries {
+		result[i] = entry.ID
+	}
+	return result
+}
+
+func TestStoreEntriesReturnsOnlyAdvancedEntries(t *testing.T) {
+	store := vectorstore.NewMemory()
+	embedder := embedding.NewLocal(32)
+
+	advanced, err := StoreEntries(context.Background(), store, "papers", []structure.SimplifiedEntry{
+		paper("2101.00001v1", "first version"),
+		paper("2101.00001v2", "second version"),
+		paper("2101.00002v1", "another paper"),
+	}, embedder)
+	if err != nil {
+		t.Fatalf("StoreEntries: %v", err)
+	}
+	// v1 is superseded within the batch
+	want := []string{"http://arxiv.org/abs/2101.00001v2", "http://arxiv.org/abs/2101.00002v1"}
+	if got := ids(advanced); !slices.Equal(got, want) {
+		t.Errorf("first batch advanced %v, want %v", got, want)
+	}
+
+	advanced, err = StoreEntries(context.Background(), store, "papers", []structure.SimplifiedEntry{
+		paper("2101.00001v1", "first version"),        // older than stored
+		paper("2101.00002v1", "another paper, again"), // same version
+		paper("2101.00002v3", "revised"),              // newer
+		paper("2101.00003v1", "new paper"),            // new
+	}, embedder)
+	if err != nil {
+		t.Fatalf("StoreEntries: %v", err)
+	}
+	want = []string{"http://arxiv.org/abs/2101.00002v3", "http://arxiv.org/abs/2101.00003v1"}
+	if got := ids(advanced); !slices.Equal(got, want) {
+		t.Errorf("second batch advanced %v, want %v", got, want)
+	}
+
+	advanced, err = StoreEntries(context.Background(), store, "papers", []structure.SimplifiedEntry{
+		paper("2101.00003v1", "new paper"),
+	}, embedder)
+	if err != nil {
+		t.Fatalf("StoreEntries: %v", err)
+	}
+	if len(advanced) != 0 {
+		t.Errorf("re-ingesting an unchanged paper advanced %v", ids(advanced))
+	}
+}
+
+func TestStoreEntriesOrdersVersionlessUpdatesByDate(t *testing.T) {
+	store := vectorstore.NewMemory()
+	embedder := embedding.NewLocal(32)
+
+	stored := paper("2101.00001v2", "second version")
+	if _, err := StoreEntries(context.Background(), store, "papers", []structure.SimplifiedEntry{stored}, embedder); err != nil {
+		t.Fatalf("StoreEntries: %v", err)
+	}
+
+	// Records in the OAI-PMH arXiv format carry no version
+	stale := paper("2101.00001", "stale copy")
+	stale.Updated = "2023-12-01T00:00:00Z"
+	update := paper("2101.00001", "updated abstract")
+	update.Updated = "2024-02-01T00:00:00Z"
+
+	advanced, err := StoreEntries(context.Background(), store, "papers", []structure.SimplifiedEntry{stale}, embedder)
+	if err != nil || len(advanced) != 0 {
+		t.Fatalf("storing an older versionless entry: advanced %v, err %v", ids(advanced), err)
+	}
+	if got := storedSummary(t, store, stored.ID); got != "second version" {
+		t.Errorf("older versionless entry replaced the stored summary with %q", got)
+	}
+
+	advanced, err = StoreEntries(context.Background(), store, "papers", []structure.SimplifiedEntry{update}, embedder)
+	if err != nil || len(advanced) != 1 {
+		t.Fatalf("storing a newer versionless entry: advanced %v, err %v", ids(advanced), err)
+	}
+	if got := storedSummary(t, store, stored.ID); got != "updated abstract" {
+		t.Errorf("stored summary = %q, want the update", got)
+	}
+}
+
+func TestStoreEntriesStopsWhenCanceled(t *testing.T) {
+	store := vectorstore.NewMemory()
+	// The batch waits for more texts long after the caller gives up
+	batcher := embedding.NewBatcher(embedding.NewLocal(32), 10, time.Minute)
+	defer batcher.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := StoreEntries(ctx, store, "papers", []structure.SimplifiedEntry{paper("2101.00001v1", "summary")}, batcher)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("StoreEntries error = %v, want the deadline", err)
+	}
+
+	points, err := store.Get(context.Background(), &qdrant.GetPoints{
+		CollectionName: "papers",
+		Ids:            []*qdrant.PointId{qdrant.NewIDUUID(arxiv.PointID("2101.00001"))},
+	})
+	if err != nil || len(points) != 0 {
+		t.Errorf("canceled StoreEntries stored %d points, err %v", len(points), err)
+	}
+}
+
+func storedSummary(t *testing.T, store vectorstore.VectorStore, id string) string {
+	t.Helper()
+	points, err := store.Get(context.Background(), &qdrant.GetPoints{
+		CollectionName: "papers",
+		Ids:            []*qdrant.PointId{qdrant.NewIDUUID(arxiv.PointID(id))},
+		WithPayload:    qdrant.NewWithPayload(true),
+	})
+	if err != nil || len(points) != 1 {
+		t.Fatalf("Get %s: %d points, err %v", id, len(points), err)
+	}
+	return points[0].Payload["summary"].GetStringValue()
+}
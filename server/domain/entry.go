@@ -0,0 +1,138 @@
+// Package domain holds the paper types shared by the service, the consumer
+// and the tools, and their mapping to Qdrant payloads.
+package domain
+
+import (
+	"RAGScholar/arxiv"
+	"RAGScholar/payload"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Author and Link keep their Go field names in JSON, which is what the
+// client reads
+type Author struct {
+	Name string `xml:"name" json:"Name" payload:"name"`
+}
+
+type Link struct {
+	Href string `xml:"href,attr" json:"Href" payload:"href"`
+	Rel  string `xml:"rel,attr" json:"Rel" payload:"rel"`
+	Type string `xml:"type,attr" json:"Type" payload:"type"`
+}
+
+// Entry is one arXiv paper as it travels from the harvester through the
+// queue into Qdrant and back out of the API. Scores are only set on search
+// results and are never stored.
+type Entry struct {
+	ID                  string   `json:"id" payload:"id"`
+	Updated             string   `json:"updated" payload:"updated"`
+	Published           string   `json:"published" payload:"published"`
+	Title               string   `json:"title" payload:"title"`
+	Summary             string   `json:"summary" payload:"summary"`
+	Authors             []Author `json:"authors" payload:"authors"`
+	Comment             string   `json:"comment" payload:"comment"`
+	Links               []Link   `json:"links" payload:"links"`
+	PrimaryCategory     string   `json:"primaryCategory" payload:"primaryCategory"`
+	PrimaryCategoryName string   `json:"primaryCategoryName,omitempty" payload:"primaryCategoryName,omitempty"`
+	Categories          []string `json:"categories" payload:"categories"`
+	CategoryNames       []string `json:"categoryNames,omitempty" payload:"categoryNames"`
+	DOI                 string   `json:"doi" payload:"doi"`
+	JournalRef          string   `json:"journalRef" payload:"journalRef"`
+	Score               float32  `json:"score,omitempty" payload:"-"`
+	SemanticScore       float32  `json:"semanticScore,omitempty" payload:"-"`
+	LexicalScore        float32  `json:"lexicalScore,omitempty" payload:"-"`
+}
+
+// SetCategories stores the normalized category codes and their names. The
+// primary category falls back to the first category, which is where arXiv
+// lists it.
+func (e *Entry) SetCategories(primary string, categories []string) {
+	e.Categories = arxiv.NormalizeCategories(categories)
+	e.PrimaryCategory = arxiv.NormalizeCategory(primary)
+	if e.PrimaryCategory == "" && len(e.Categories) > 0 {
+		e.PrimaryCategory = e.Categories[0]
+	}
+
+	e.PrimaryCategoryName = ""
+	if e.PrimaryCategory != "" {
+		e.PrimaryCategoryName = arxiv.CategoryName(e.PrimaryCategory)
+	}
+	e.CategoryNames = make([]string, len(e.Categories))
+	for i, code := range e.Categories {
+		e.CategoryNames[i] = arxiv.CategoryName(code)
+	}
+}
+
+// Normalize puts an entry into the form it is stored in: canonical
+// categories with their names, and timestamps in UTC RFC 3339. Timestamps
+// that don't parse are kept as they are.
+func (e *Entry) Normalize() {
+	e.SetCategories(e.PrimaryCategory, e.Categories)
+	for _, timestamp := range []*string{&e.Published, &e.Updated} {
+		if t, err := time.Parse(time.RFC3339, *timestamp); err == nil {
+			*timestamp = t.UTC().Format(time.RFC3339)
+		}
+	}
+}
+
+// EncodePayload encodes an entry as a paper payload. Alongside the entry's
+// own fields it stores the derived fields the indexes and filters rely on
+// (see payload.SchemaVersion); DecodePayload ignores those, so decoding an
+// encoded entry gives back the same entry, minus its scores.
+func EncodePayload(e Entry) (map[string]*qdrant.Value, error) {
+	fields, err := payload.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+
+	fields[payload.FieldArxivID] = qdrant.NewValueString(arxiv.NormalizeID(e.ID))
+	fields[payload.FieldArxivNumber] = qdrant.NewValueString(arxiv.Number(e.ID))
+	fields[payload.FieldVersion] = qdrant.NewValueInt(int64(arxiv.Version(e.ID)))
+	fields[payload.FieldSchemaVersion] = qdrant.NewValueInt(payload.SchemaVersion)
+
+	// Unix seconds for integer ranges and ordering, and the year for facets
+	if updated, err := time.Parse(time.RFC3339, e.Updated); err == nil {
+		fields[payload.FieldUpdatedAt] = qdrant.NewValueInt(updated.Unix())
+	}
+	if published, err := time.Parse(time.RFC3339, e.Published); err == nil {
+		fields[payload.FieldPublishedAt] = qdrant.NewValueInt(published.Unix())
+		fields[payload.FieldPublishedYear] = qdrant.NewValueInt(int64(published.Year()))
+	}
+
+	// Flat copy of the names for exact, keyword-indexed author lookups
+	authorNames := make([]*qdrant.Value, len(e.Authors))
+	for i, author := range e.Authors {
+		authorNames[i] = qdrant.NewValueString(author.Name)
+	}
+	fields[payload.FieldAuthorNames] = qdrant.NewValueList(&qdrant.ListValue{Values: authorNames})
+
+	// Archives and groups let search filter and facet on any level of the
+	// taxonomy
+	var archives, groups []*qdrant.Value
+	seen := make(map[string]bool)
+	for _, code := range e.Categories {
+		category, _ := arxiv.LookupCategory(code)
+		if !seen["archive:"+category.Archive] {
+			seen["archive:"+category.Archive] = true
+			archives = append(archives, qdrant.NewValueString(category.Archive))
+		}
+		if !seen["group:"+category.Group] {
+			seen["group:"+category.Group] = true
+			groups = append(groups, qdrant.NewValueString(category.Group))
+		}
+	}
+	fields[payload.FieldArchives] = qdrant.NewValueList(&qdrant.ListValue{Values: archives})
+	fields[payload.FieldGroups] = qdrant.NewValueList(&qdrant.ListValue{Values: groups})
+
+	return fields, nil
+}
+
+// DecodePayload decodes a paper payload written by EncodePayload, or by an
+// older schema version
+func DecodePayload(fields map[string]*qdrant.Value) (Entry, error) {
+	var e Entry
+	err := payload.Unmarshal(fields, &e)
+	return e, err
+}
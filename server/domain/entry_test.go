@@ -0,0 +1,137 @@
+package domain
+
+import (
+	"RAGScholar/payload"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+// withoutScores is the entry as it comes back from storage, which never
+// keeps scores
+func withoutScores(e Entry) Entry {
+	e.Score, e.SemanticScore, e.LexicalScore = 0, 0, 0
+	return e
+}
+
+func TestPayloadRoundTrip(t *testing.T) {
+	property := func(want Entry) bool {
+		fields, err := EncodePayload(want)
+		if err != nil {
+			t.Logf("EncodePayload: %v", err)
+			return false
+		}
+		got, err := DecodePayload(fields)
+		if err != nil {
+			t.Logf("DecodePayload: %v", err)
+			return false
+		}
+		if want := withoutScores(want); !reflect.DeepEqual(got, want) {
+			t.Logf("got  %+v\nwant %+v", got, want)
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	property := func(want Entry) bool {
+		data, err := json.Marshal(want)
+		if err != nil {
+			t.Logf("Marshal: %v", err)
+			return false
+		}
+		var got Entry
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Logf("Unmarshal: %v", err)
+			return false
+		}
+		// Empty category names are omitted, so they come back as nil
+		if len(want.CategoryNames) == 0 {
+			want.CategoryNames = nil
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Logf("got  %+v\nwant %+v", got, want)
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+// The client reads authors and links by their Go field names
+func TestJSONFieldNames(t *testing.T) {
+	data, err := json.Marshal(Entry{
+		Authors: []Author{{Name: "Ada Lovelace"}},
+		Links:   []Link{{Href: "http://arxiv.org/pdf/2401.01234v1", Rel: "related", Type: "application/pdf"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"authors":[{"Name":"Ada Lovelace"}]`,
+		`"links":[{"Href":"http://arxiv.org/pdf/2401.01234v1","Rel":"related","Type":"application/pdf"}]`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("JSON %s does not contain %s", data, want)
+		}
+	}
+}
+
+func TestEncodePayloadDerivedFields(t *testing.T) {
+	fields, err := EncodePayload(Entry{
+		ID:         "http://arxiv.org/abs/hep-th/9901001v2",
+		Published:  "1999-01-04T12:00:00Z",
+		Updated:    "1999-02-01T00:00:00Z",
+		Authors:    []Author{{Name: "Ada Lovelace"}, {Name: "Alan Turing"}},
+		Categories: []string{"hep-th", "math.AG"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	strings := map[string]string{
+		payload.FieldArxivID:     "hep-th/9901001",
+		payload.FieldArxivNumber: "9901001",
+	}
+	for field, want := range strings {
+		if got := fields[field].GetStringValue(); got != want {
+			t.Errorf("%s = %q, want %q", field, got, want)
+		}
+	}
+
+	integers := map[string]int64{
+		payload.FieldVersion:       2,
+		payload.FieldSchemaVersion: payload.SchemaVersion,
+		payload.FieldPublishedAt:   915451200,
+		payload.FieldPublishedYear: 1999,
+		payload.FieldUpdatedAt:     917827200,
+	}
+	for field, want := range integers {
+		if got := fields[field].GetIntegerValue(); got != want {
+			t.Errorf("%s = %d, want %d", field, got, want)
+		}
+	}
+
+	lists := map[string][]string{
+		payload.FieldAuthorNames: {"Ada Lovelace", "Alan Turing"},
+		payload.FieldArchives:    {"hep-th", "math"},
+		payload.FieldGroups:      {"physics", "math"},
+	}
+	for field, want := range lists {
+		var got []string
+		for _, value := range fields[field].GetListValue().GetValues() {
+			got = append(got, value.GetStringValue())
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %q, want %q", field, got, want)
+		}
+	}
+}
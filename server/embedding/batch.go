@@ -0,0 +1,257 @@
+package embedding
+
+import (
+	"RAGScholar/gemini"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxBatchSize is the most texts Gemini accepts in one batch request
+const MaxBatchSize = 100
+
+// ErrInvalidInput marks texts the embedding model rejected, which will be
+// rejected again if retried
+var ErrInvalidInput = errors.New("invalid embedding input")
+
+var errBatcherClosed = errors.New("embedding batcher closed")
+
+// EmbedEach embeds texts in batches of up to MaxBatchSize and reports an
+// error per text instead of failing them all. Blank texts are rejected up
+// front, and when the model rejects a batch as invalid input the batch is
+// split in halves until the texts it objects to are isolated; those errors
+// wrap ErrInvalidInput. Any other failure is reported for every text of the
+// batch it hit.
+func EmbedEach(ctx context.Context, embedder Embedder, texts []string) ([][]float32, []error) {
+	// Wrappers that coalesce or cache texts map errors to texts themselves
+	if e, ok := embedder.(interface {
+		EmbedEach(context.Context, []string) ([][]float32, []error)
+	}); ok {
+		return e.EmbedEach(ctx, texts)
+	}
+
+	vectors := make([][]float32, len(texts))
+	errs := make([]error, len(texts))
+
+	valid := make([]int, 0, len(texts))
+	for i, text := range texts {
+		if strings.TrimSpace(text) == "" {
+			errs[i] = fmt.Errorf("%w: empty text", ErrInvalidInput)
+			continue
+		}
+		valid = append(valid, i)
+	}
+
+	for start := 0; start < len(valid); start += MaxBatchSize {
+		end := min(start+MaxBatchSize, len(valid))
+		embedSplitting(ctx, embedder, texts, valid[start:end], vectors, errs)
+	}
+
+	return vectors, errs
+}
+
+// embedSplitting embeds the texts at indexes, splitting the batch when the
+// model rejects it as invalid
+func embedSplitting(ctx context.Context, embedder Embedder, texts []string, indexes []int,
+	vectors [][]float32, errs []error) {
+
+	batch := make([]string, len(indexes))
+	for i, index := range indexes {
+		batch[i] = texts[index]
+	}
+
+	embedded, err := embedder.EmbedBatch(ctx, batch)
+	if err == nil {
+		for i, index := range indexes {
+			vectors[index] = embedded[i]
+		}
+		return
+	}
+
+	if !gemini.IsInvalidInput(err) {
+		for _, index := range indexes {
+			errs[index] = err
+		}
+		return
+	}
+
+	if len(indexes) == 1 {
+		errs[indexes[0]] = fmt.Errorf("%w: %v", ErrInvalidInput, err)
+		return
+	}
+
+	half := len(indexes) / 2
+	embedSplitting(ctx, embedder, texts, indexes[:half], vectors, errs)
+	embedSplitting(ctx, embedder, texts, indexes[half:], vectors, errs)
+}
+
+// Batcher coalesces the texts of concurrent callers into shared batch
+// requests. A batch is sent once it holds maxSize texts or maxDelay after its
+// first text arrived, whichever comes first. Batcher is itself an Embedder,
+// so callers that embed one text at a time benefit too.
+type Batcher struct {
+	embedder Embedder
+	maxSize  int
+	maxDelay time.Duration
+
+	items     chan *batchItem
+	done      chan struct{}
+	stopped   chan struct{}
+	inFlight  sync.WaitGroup
+	closeOnce sync.Once
+}
+
+type batchItem struct {
+	text   string
+	result chan batchResult
+}
+
+type batchResult struct {
+	vector []float32
+	err    error
+}
+
+func NewBatcher(embedder Embedder, maxSize int, maxDelay time.Duration) *Batcher {
+	if maxSize <= 0 || maxSize > MaxBatchSize {
+		maxSize = MaxBatchSize
+	}
+	b := &Batcher{
+		embedder: embedder,
+		maxSize:  maxSize,
+		maxDelay: maxDelay,
+		items:    make(chan *batchItem),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+func (b *Batcher) run() {
+	defer close(b.stopped)
+
+	var pending []*batchItem
+	var timer *time.Timer
+	var deadline <-chan time.Time
+
+	flush := func() {
+		if timer != nil {
+			timer.Stop()
+			timer, deadline = nil, nil
+		}
+		if len(pending) == 0 {
+			return
+		}
+		batch := pending
+		pending = nil
+
+		b.inFlight.Add(1)
+		go func() {
+			defer b.inFlight.Done()
+			b.embed(batch)
+		}()
+	}
+
+	for {
+		select {
+		case item := <-b.items:
+			pending = append(pending, item)
+			if len(pending) == 1 {
+				timer = time.NewTimer(b.maxDelay)
+				deadline = timer.C
+			}
+			if len(pending) >= b.maxSize {
+				flush()
+			}
+		case <-deadline:
+			flush()
+		case <-b.done:
+			flush()
+			return
+		}
+	}
+}
+
+// embed sends one batch. It isn't tied to any caller's context, since the
+// batch is shared; callers that give up simply stop waiting for it.
+func (b *Batcher) embed(batch []*batchItem) {
+	texts := make([]string, len(batch))
+	for i, item := range batch {
+		texts[i] = item.text
+	}
+
+	vectors, errs := EmbedEach(context.Background(), b.embedder, texts)
+	for i, item := range batch {
+		item.result <- batchResult{vector: vectors[i], err: errs[i]}
+	}
+}
+
+// EmbedEach queues the texts for the next batches and waits for their
+// vectors, with one error per text as in the package-level EmbedEach.
+func (b *Batcher) EmbedEach(ctx context.Context, texts []string) ([][]float32, []error) {
+	vectors := make([][]float32, len(texts))
+	errs := make([]error, len(texts))
+
+	items := make([]*batchItem, len(texts))
+	for i, text := range texts {
+		item := &batchItem{text: text, result: make(chan batchResult, 1)}
+		select {
+		case b.items <- item:
+			items[i] = item
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+		case <-b.done:
+			errs[i] = errBatcherClosed
+		}
+	}
+
+	for i, item := range items {
+		if item == nil {
+			continue
+		}
+		select {
+		case result := <-item.result:
+			vectors[i], errs[i] = result.vector, result.err
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+		}
+	}
+
+	return vectors, errs
+}
+
+func (b *Batcher) Embed(ctx context.Context, text string) ([]float32, error) {
+	vectors, errs := b.EmbedEach(ctx, []string{text})
+	return vectors[0], errs[0]
+}
+
+func (b *Batcher) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors, errs := b.EmbedEach(ctx, texts)
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed text at index %d: %w", i, err)
+		}
+	}
+	return vectors, nil
+}
+
+func (b *Batcher) Dimension() int {
+	return b.embedder.Dimension()
+}
+
+func (b *Batcher) Model() string {
+	return b.embedder.Model()
+}
+
+// Close sends the texts still waiting for a batch and waits for every batch
+// in flight. Embedding through a closed Batcher fails.
+func (b *Batcher) Close() {
+	b.closeOnce.Do(func() {
+		close(b.done)
+	})
+	<-b.stopped
+	b.inFlight.Wait()
+}
@@ -0,0 +1,231 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// stubEmbedder embeds with Local and records every batch it is sent. Batches
+// holding an invalid text are rejected with a 400 and batches holding an
+// unavailable one with a 503, as Gemini would.
+type stubEmbedder struct {
+	*Local
+	invalid     map[string]bool
+	unavailable map[string]bool
+
+	mu      sync.Mutex
+	batches [][]string
+}
+
+func newStub(invalid, unavailable []string) *stubEmbedder {
+	stub := &stubEmbedder{Local: NewLocal(8), invalid: map[string]bool{}, unavailable: map[string]bool{}}
+	for _, text := range invalid {
+		stub.invalid[text] = true
+	}
+	for _, text := range unavailable {
+		stub.unavailable[text] = true
+	}
+	return stub
+}
+
+func (s *stubEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	s.mu.Lock()
+	s.batches = append(s.batches, slices.Clone(texts))
+	s.mu.Unlock()
+
+	for _, text := range texts {
+		if s.unavailable[text] {
+			return nil, &googleapi.Error{Code: http.StatusServiceUnavailable, Message: "overloaded"}
+		}
+		if s.invalid[text] {
+			return nil, &googleapi.Error{Code: http.StatusBadRequest, Message: "invalid text"}
+		}
+	}
+	return s.Local.EmbedBatch(ctx, texts)
+}
+
+func (s *stubEmbedder) batchSizes() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sizes := make([]int, len(s.batches))
+	for i, batch := range s.batches {
+		sizes[i] = len(batch)
+	}
+	return sizes
+}
+
+func texts(n int) []string {
+	result := make([]string, n)
+	for i := range result {
+		result[i] = fmt.Sprintf("paper %d", i)
+	}
+	return result
+}
+
+func TestEmbedEach(t *testing.T) {
+	tests := []struct {
+		name        string
+		texts       []string
+		invalid     []string
+		unavailable []string
+		// Indexes expected to fail, and whether as invalid input
+		failed       []int
+		invalidInput bool
+		requests     []int
+	}{
+		{name: "all embedded", texts: texts(5), requests: []int{5}},
+		{
+			name:         "blank texts rejected up front",
+			texts:        []string{"paper 0", " ", "paper 2", ""},
+			failed:       []int{1, 3},
+			invalidInput: true,
+			requests:     []int{2},
+		},
+		{
+			// Halved until the bad text is alone: 8, 4 (ok), 4, 2, 1 (ok), 1, 2 (ok)
+			name:         "one invalid text",
+			texts:        texts(8),
+			invalid:      []string{"paper 5"},
+			failed:       []int{5},
+			invalidInput: true,
+			requests:     []int{8, 4, 4, 2, 1, 1, 2},
+		},
+		{
+			name:         "two invalid texts",
+			texts:        texts(4),
+			invalid:      []string{"paper 0", "paper 3"},
+			failed:       []int{0, 3},
+			invalidInput: true,
+			requests:     []int{4, 2, 1, 1, 2, 1, 1},
+		},
+		{
+			// Anything but invalid input fails the whole batch, unsplit
+			name:        "unavailable",
+			texts:       texts(3),
+			unavailable: []string{"paper 1"},
+			failed:      []int{0, 1, 2},
+			requests:    []int{3},
+		},
+		{name: "more than MaxBatchSize", texts: texts(2*MaxBatchSize + 1), requests: []int{MaxBatchSize, MaxBatchSize, 1}},
+	}
+
+	for _, test := range tests {
+		stub := newStub(test.invalid, test.unavailable)
+		vectors, errs := EmbedEach(context.Background(), stub, test.texts)
+
+		if got := stub.batchSizes(); !slices.Equal(got, test.requests) {
+			t.Errorf("%s: sent batches of %v, want %v", test.name, got, test.requests)
+		}
+		for i, text := range test.texts {
+			if slices.Contains(test.failed, i) {
+				if errs[i] == nil || vectors[i] != nil {
+					t.Errorf("%s: text %d = %v, %v, want an error", test.name, i, vectors[i], errs[i])
+				} else if errors.Is(errs[i], ErrInvalidInput) != test.invalidInput {
+					t.Errorf("%s: text %d error %v, invalid input %v", test.name, i, errs[i], test.invalidInput)
+				}
+				continue
+			}
+			if errs[i] != nil {
+				t.Errorf("%s: text %d failed: %v", test.name, i, errs[i])
+				continue
+			}
+			// Each vector belongs to its own text after the splits
+			want, _ := stub.Local.Embed(context.Background(), text)
+			if !slices.Equal(vectors[i], want) {
+				t.Errorf("%s: text %d has the vector of another text", test.name, i)
+			}
+		}
+	}
+}
+
+// embedConcurrently embeds each text from its own goroutine, as the
+// consumer's workers do
+func embedConcurrently(b *Batcher, texts []string) ([][]float32, []error) {
+	vectors := make([][]float32, len(texts))
+	errs := make([]error, len(texts))
+	var wg sync.WaitGroup
+	for i, text := range texts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			vectors[i], errs[i] = b.Embed(context.Background(), text)
+		}()
+	}
+	wg.Wait()
+	return vectors, errs
+}
+
+func TestBatcher(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxSize  int
+		maxDelay time.Duration
+		texts    int
+		invalid  []string
+		requests []int
+		// minWait is how long the callers must wait for their batch
+		minWait time.Duration
+	}{
+		{name: "full batches go out at once", maxSize: 4, maxDelay: time.Hour, texts: 8, requests: []int{4, 4}},
+		{name: "a partial batch waits for maxDelay", maxSize: 100, maxDelay: 50 * time.Millisecond, texts: 3, requests: []int{3}, minWait: 50 * time.Millisecond},
+		{
+			// One batch of 4, halved down to the bad text
+			name:     "an invalid text fails only its caller",
+			maxSize:  4,
+			maxDelay: time.Hour,
+			texts:    4,
+			invalid:  []string{"paper 2"},
+			requests: []int{4, 2, 2, 1, 1},
+		},
+	}
+
+	for _, test := range tests {
+		stub := newStub(test.invalid, nil)
+		b := NewBatcher(stub, test.maxSize, test.maxDelay)
+
+		start := time.Now()
+		vectors, errs := embedConcurrently(b, texts(test.texts))
+		elapsed := time.Since(start)
+		b.Close()
+
+		if elapsed < test.minWait || elapsed > test.minWait+5*time.Second {
+			t.Errorf("%s: callers waited %v, want %v", test.name, elapsed, test.minWait)
+		}
+		sizes := stub.batchSizes()
+		slices.Sort(sizes)
+		want := slices.Sorted(slices.Values(test.requests))
+		if !slices.Equal(sizes, want) {
+			t.Errorf("%s: sent batches of %v, want %v", test.name, stub.batchSizes(), test.requests)
+		}
+		for i, text := range texts(test.texts) {
+			if slices.Contains(test.invalid, text) {
+				if !errors.Is(errs[i], ErrInvalidInput) {
+					t.Errorf("%s: %q error = %v, want ErrInvalidInput", test.name, text, errs[i])
+				}
+				continue
+			}
+			want, _ := stub.Local.Embed(context.Background(), text)
+			if errs[i] != nil || !slices.Equal(vectors[i], want) {
+				t.Errorf("%s: %q = %v, %v, want its own vector", test.name, text, vectors[i], errs[i])
+			}
+		}
+	}
+}
+
+func TestBatcherClosed(t *testing.T) {
+	b := NewBatcher(newStub(nil, nil), 4, time.Hour)
+	b.Close()
+	b.Close()
+
+	if _, err := b.Embed(context.Background(), "paper 0"); !errors.Is(err, errBatcherClosed) {
+		t.Errorf("Embed after Close: %v, want errBatcherClosed", err)
+	}
+}
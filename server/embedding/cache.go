@@ -0,0 +1,282 @@
+package embedding
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	_ "modernc.org/sqlite"
+)
+
+// Cache is an Embedder that remembers vectors by content: the key is a hash
+// of the model name and the text with its whitespace normalized, so the same
+// text embedded by the same model is only sent to the model once. Recently
+// used vectors are kept in an in-memory LRU, and every vector is also kept in
+// an optional SQLite database that survives restarts and can be shared by the
+// service and the consumer. It is safe for concurrent use.
+type Cache struct {
+	embedder Embedder
+
+	mu       sync.Mutex
+	capacity int
+	recent   *list.List // of *cacheEntry, most recently used first
+	entries  map[string]*list.Element
+
+	db *sql.DB
+
+	memoryHits atomic.Uint64
+	diskHits   atomic.Uint64
+	misses     atomic.Uint64
+}
+
+type cacheEntry struct {
+	key    string
+	vector []float32
+}
+
+// CacheStats counts cache lookups since the cache was opened
+type CacheStats struct {
+	Lookups    uint64  `json:"lookups"`
+	MemoryHits uint64  `json:"memoryHits"`
+	DiskHits   uint64  `json:"diskHits"`
+	Misses     uint64  `json:"misses"`
+	HitRate    float64 `json:"hitRate"`
+}
+
+// NewCache wraps embedder with a cache of up to capacity vectors in memory
+// and, when path is set, a database of every vector at path
+func NewCache(embedder Embedder, capacity int, path string) (*Cache, error) {
+	c := &Cache{
+		embedder: embedder,
+		capacity: capacity,
+		recent:   list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+
+	if path != "" {
+		dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", path)
+		db, err := sql.Open("sqlite", dsn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open embedding cache: %w", err)
+		}
+		_, err = db.Exec(`CREATE TABLE IF NOT EXISTS embeddings (
+			key    TEXT PRIMARY KEY,
+			vector BLOB NOT NULL
+		)`)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to create embedding cache: %w", err)
+		}
+		c.db = db
+	}
+
+	return c, nil
+}
+
+func (c *Cache) Close() error {
+	if c.db == nil {
+		return nil
+	}
+	return c.db.Close()
+}
+
+func (c *Cache) Stats() CacheStats {
+	stats := CacheStats{
+		MemoryHits: c.memoryHits.Load(),
+		DiskHits:   c.diskHits.Load(),
+		Misses:     c.misses.Load(),
+	}
+	stats.Lookups = stats.MemoryHits + stats.DiskHits + stats.Misses
+	if stats.Lookups > 0 {
+		stats.HitRate = float64(stats.MemoryHits+stats.DiskHits) / float64(stats.Lookups)
+	}
+	return stats
+}
+
+func (c *Cache) String() string {
+	stats := c.Stats()
+	return fmt.Sprintf("%d lookups, %.1f%% hits (%d in memory, %d on disk), %d misses",
+		stats.Lookups, stats.HitRate*100, stats.MemoryHits, stats.DiskHits, stats.Misses)
+}
+
+// EmbedEach looks every text up in the cache and sends only the misses to
+// the wrapped embedder, once per distinct text, with one error per text as in
+// the package-level EmbedEach. Failed texts aren't cached.
+func (c *Cache) EmbedEach(ctx context.Context, texts []string) ([][]float32, []error) {
+	vectors := make([][]float32, len(texts))
+	errs := make([]error, len(texts))
+
+	keys := make([]string, len(texts))
+	missing := make(map[string][]int)
+	var missTexts, missKeys []string
+	for i, text := range texts {
+		keys[i] = c.key(text)
+		if vector, ok := c.lookup(ctx, keys[i]); ok {
+			vectors[i] = vector
+			continue
+		}
+		c.misses.Add(1)
+		if _, ok := missing[keys[i]]; !ok {
+			missTexts = append(missTexts, text)
+			missKeys = append(missKeys, keys[i])
+		}
+		missing[keys[i]] = append(missing[keys[i]], i)
+	}
+	if len(missTexts) == 0 {
+		return vectors, errs
+	}
+
+	embedded, embedErrs := EmbedEach(ctx, c.embedder, missTexts)
+	stored := make(map[string][]float32, len(missTexts))
+	for i, key := range missKeys {
+		for _, index := range missing[key] {
+			vectors[index], errs[index] = embedded[i], embedErrs[i]
+		}
+		if embedErrs[i] == nil {
+			stored[key] = embedded[i]
+		}
+	}
+	c.store(ctx, stored)
+
+	return vectors, errs
+}
+
+func (c *Cache) Embed(ctx context.Context, text string) ([]float32, error) {
+	vectors, errs := c.EmbedEach(ctx, []string{text})
+	return vectors[0], errs[0]
+}
+
+func (c *Cache) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors, errs := c.EmbedEach(ctx, texts)
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed text at index %d: %w", i, err)
+		}
+	}
+	return vectors, nil
+}
+
+func (c *Cache) Dimension() int {
+	return c.embedder.Dimension()
+}
+
+func (c *Cache) Model() string {
+	return c.embedder.Model()
+}
+
+// key hashes the model name and the text with runs of whitespace collapsed,
+// so reformatted copies of a text share an entry
+func (c *Cache) key(text string) string {
+	hash := sha256.New()
+	hash.Write([]byte(c.embedder.Model()))
+	hash.Write([]byte{0})
+	hash.Write([]byte(strings.Join(strings.Fields(text), " ")))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (c *Cache) lookup(ctx context.Context, key string) ([]float32, bool) {
+	c.mu.Lock()
+	if element, ok := c.entries[key]; ok {
+		c.recent.MoveToFront(element)
+		c.mu.Unlock()
+		c.memoryHits.Add(1)
+		// Callers may modify their vector, so they never get the cached one
+		return slices.Clone(element.Value.(*cacheEntry).vector), true
+	}
+	c.mu.Unlock()
+
+	if c.db == nil {
+		return nil, false
+	}
+
+	var data []byte
+	err := c.db.QueryRowContext(ctx, "SELECT vector FROM embeddings WHERE key = ?", key).Scan(&data)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			// The cache only saves calls, so a broken database falls back to
+			// the model rather than failing
+			log.Printf("Embedding cache lookup failed: %v", err)
+		}
+		return nil, false
+	}
+
+	vector := decodeVector(data)
+	c.remember(key, vector)
+	c.diskHits.Add(1)
+	return vector, true
+}
+
+func (c *Cache) store(ctx context.Context, vectors map[string][]float32) {
+	for key, vector := range vectors {
+		c.remember(key, vector)
+	}
+
+	if c.db == nil || len(vectors) == 0 {
+		return
+	}
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Failed to store embeddings in cache: %v", err)
+		return
+	}
+	for key, vector := range vectors {
+		if _, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO embeddings (key, vector) VALUES (?, ?)", key, encodeVector(vector)); err != nil {
+			tx.Rollback()
+			log.Printf("Failed to store embeddings in cache: %v", err)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to store embeddings in cache: %v", err)
+	}
+}
+
+// remember adds a copy of the vector to the in-memory tier, evicting the
+// least recently used vectors beyond capacity
+func (c *Cache) remember(key string, vector []float32) {
+	if c.capacity <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.recent.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.recent.PushFront(&cacheEntry{key: key, vector: slices.Clone(vector)})
+	for c.recent.Len() > c.capacity {
+		oldest := c.recent.Back()
+		c.recent.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func encodeVector(vector []float32) []byte {
+	data := make([]byte, 4*len(vector))
+	for i, value := range vector {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(value))
+	}
+	return data
+}
+
+func decodeVector(data []byte) []float32 {
+	vector := make([]float32, len(data)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return vector
+}
@@ -0,0 +1,155 @@
+package embedding
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// sent lists every text the stub was asked to embed, in order
+func (s *stubEmbedder) sent() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var texts []string
+	for _, batch := range s.batches {
+		texts = append(texts, batch...)
+	}
+	return texts
+}
+
+// namedEmbedder is a stub that reports another model name
+type namedEmbedder struct {
+	*stubEmbedder
+	model string
+}
+
+func (e namedEmbedder) Model() string {
+	return e.model
+}
+
+func newCache(t *testing.T, embedder Embedder, capacity int, path string) *Cache {
+	t.Helper()
+	cache, err := NewCache(embedder, capacity, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cache.Close() })
+	return cache
+}
+
+func embed(t *testing.T, embedder Embedder, texts ...string) [][]float32 {
+	t.Helper()
+	vectors, err := embedder.EmbedBatch(context.Background(), texts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return vectors
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	stub := newStub(nil, nil)
+	cache := newCache(t, stub, 2, "")
+
+	embed(t, cache, "a", "b")
+	embed(t, cache, "a") // a is now the most recently used
+	embed(t, cache, "c") // evicts b
+	embed(t, cache, "a", "c")
+	embed(t, cache, "b")
+
+	if got, want := stub.sent(), []string{"a", "b", "c", "b"}; !slices.Equal(got, want) {
+		t.Errorf("model embedded %v, want %v", got, want)
+	}
+	want := CacheStats{Lookups: 7, MemoryHits: 3, Misses: 4, HitRate: 3.0 / 7}
+	if got := cache.Stats(); got != want {
+		t.Errorf("stats = %+v, want %+v", got, want)
+	}
+}
+
+func TestCacheServesEvictedVectorsFromDisk(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "embeddings.db")
+	stub := newStub(nil, nil)
+	cache := newCache(t, stub, 1, path)
+
+	first := embed(t, cache, "a")[0]
+	embed(t, cache, "b") // evicts a from memory
+	if got := embed(t, cache, "a")[0]; !slices.Equal(got, first) {
+		t.Errorf("vector from disk = %v, want %v", got, first)
+	}
+	embed(t, cache, "a") // back in memory
+
+	if got, want := stub.sent(), []string{"a", "b"}; !slices.Equal(got, want) {
+		t.Errorf("model embedded %v, want %v", got, want)
+	}
+	want := CacheStats{Lookups: 4, MemoryHits: 1, DiskHits: 1, Misses: 2, HitRate: 0.5}
+	if got := cache.Stats(); got != want {
+		t.Errorf("stats = %+v, want %+v", got, want)
+	}
+
+	// The database outlives the process, and is shared between caches
+	restarted := newStub(nil, nil)
+	embed(t, newCache(t, restarted, 10, path), "a", "b")
+	if got := restarted.sent(); len(got) != 0 {
+		t.Errorf("after reopening, the model embedded %v", got)
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "embeddings.db")
+	stub := newStub(nil, nil)
+	cache := newCache(t, namedEmbedder{stub, "model-a"}, 10, path)
+
+	// Reformatted copies share an entry, other texts don't
+	embed(t, cache, "graph neural networks", "  graph\tneural\n\nnetworks ", "graph neural  networks")
+	embed(t, cache, "graphneural networks", "Graph neural networks")
+	if got, want := stub.sent(), []string{"graph neural networks", "graphneural networks", "Graph neural networks"}; !slices.Equal(got, want) {
+		t.Errorf("model embedded %v, want %v", got, want)
+	}
+
+	// Another model doesn't get this model's vectors from the shared database
+	other := newStub(nil, nil)
+	embed(t, newCache(t, namedEmbedder{other, "model-b"}, 10, path), "graph neural networks")
+	if got := other.sent(); len(got) != 1 {
+		t.Errorf("model-b embedded %v, want its own vector", got)
+	}
+	if cache.key("graph neural networks") == newCache(t, namedEmbedder{other, "model-b"}, 10, "").key("graph neural networks") {
+		t.Error("two models share a key")
+	}
+}
+
+func TestCacheReturnsCopies(t *testing.T) {
+	cache := newCache(t, newStub(nil, nil), 10, "")
+
+	missed := embed(t, cache, "a")[0]
+	want := slices.Clone(missed)
+	missed[0] = 42
+
+	hit := embed(t, cache, "a")[0]
+	if !slices.Equal(hit, want) {
+		t.Fatalf("changing the vector of a miss changed the cached one to %v", hit)
+	}
+	hit[0] = 42
+	if got := embed(t, cache, "a")[0]; !slices.Equal(got, want) {
+		t.Errorf("changing the vector of a hit changed the cached one to %v", got)
+	}
+}
+
+func TestCacheSkipsFailedTexts(t *testing.T) {
+	stub := newStub([]string{"bad"}, nil)
+	cache := newCache(t, stub, 10, "")
+
+	for range 2 {
+		vectors, errs := cache.EmbedEach(context.Background(), []string{"good", "bad", "bad"})
+		if errs[0] != nil || vectors[0] == nil {
+			t.Errorf("good text failed: %v", errs[0])
+		}
+		if errs[1] == nil || errs[2] == nil {
+			t.Errorf("bad text errors = %v, %v, want both to fail", errs[1], errs[2])
+		}
+	}
+
+	// Each distinct miss is sent once per call, and the failure isn't cached
+	if got, want := stub.sent(), []string{"good", "bad", "good", "bad", "bad"}; !slices.Equal(got, want) {
+		t.Errorf("model embedded %v, want %v", got, want)
+	}
+}
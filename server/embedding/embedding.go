@@ -0,0 +1,54 @@
+package embedding
+
+import (
+	"RAGScholar/gemini"
+	"context"
+	"fmt"
+)
+
+const (
+	ProviderGemini = "gemini"
+	ProviderLocal  = "local"
+
+	DefaultGeminiModel = "models/embedding-001"
+	DefaultDimension   = 768 // Gemini text embeddings are 768-dimensional vectors
+)
+
+// Embedder turns text into dense vectors for storage and search in Qdrant
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+	EmbedBatch(ctx context.Context, texts []string) ([][]float32, error)
+	Dimension() int
+	Model() string
+}
+
+type Config struct {
+	Provider  string
+	Model     string
+	Dimension int
+}
+
+// New builds the Embedder selected by cfg. The Gemini client is only used by
+// the Gemini provider and may be nil otherwise.
+func New(cfg Config, client *gemini.Client) (Embedder, error) {
+	dimension := cfg.Dimension
+	if dimension <= 0 {
+		dimension = DefaultDimension
+	}
+
+	switch cfg.Provider {
+	case "", ProviderGemini:
+		if client == nil {
+			return nil, fmt.Errorf("gemini embedder requires a Gemini client")
+		}
+		model := cfg.Model
+		if model == "" {
+			model = DefaultGeminiModel
+		}
+		return NewGemini(client, model, dimension), nil
+	case ProviderLocal:
+		return NewLocal(dimension), nil
+	default:
+		return nil, fmt.Errorf("unknown embedding provider %q", cfg.Provider)
+	}
+}
@@ -0,0 +1,98 @@
+package embedding
+
+import (
+	"RAGScholar/gemini"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// Gemini embeds text with a Gemini embedding model. The client paces and
+// retries its API calls.
+type Gemini struct {
+	model     *gemini.EmbeddingModel
+	modelName string
+	dimension int
+}
+
+func NewGemini(client *gemini.Client, modelName string, dimension int) *Gemini {
+	return &Gemini{
+		model:     client.EmbeddingModel(modelName),
+		modelName: modelName,
+		dimension: dimension,
+	}
+}
+
+func (g *Gemini) Embed(ctx context.Context, text string) ([]float32, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("cannot generate embedding for empty text")
+	}
+
+	resp, err := g.model.EmbedContent(ctx, genai.Text(text))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Embedding == nil || len(resp.Embedding.Values) == 0 {
+		return nil, fmt.Errorf("received empty embedding from Gemini")
+	}
+
+	if len(resp.Embedding.Values) != g.dimension {
+		return nil, g.dimensionError(len(resp.Embedding.Values))
+	}
+
+	return resp.Embedding.Values, nil
+}
+
+func (g *Gemini) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	batch := g.model.NewBatch()
+	for i, text := range texts {
+		if strings.TrimSpace(text) == "" {
+			return nil, fmt.Errorf("cannot generate embedding for empty text at index %d", i)
+		}
+		batch.AddContent(genai.Text(text))
+	}
+
+	resp, err := g.model.BatchEmbedContents(ctx, batch)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("received %d embeddings from Gemini for %d texts", len(resp.Embeddings), len(texts))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for i, embedding := range resp.Embeddings {
+		if embedding == nil || len(embedding.Values) == 0 {
+			return nil, fmt.Errorf("received empty embedding from Gemini at index %d", i)
+		}
+		if len(embedding.Values) != g.dimension {
+			return nil, fmt.Errorf("at index %d: %w", i, g.dimensionError(len(embedding.Values)))
+		}
+		vectors[i] = embedding.Values
+	}
+
+	return vectors, nil
+}
+
+// dimensionError reports a vector whose size doesn't match the configured
+// dimension, which would otherwise be written to a collection sized for
+// another model
+func (g *Gemini) dimensionError(size int) error {
+	return fmt.Errorf("received %d-dimensional embedding from %s, want %d", size, g.modelName, g.dimension)
+}
+
+func (g *Gemini) Dimension() int {
+	return g.dimension
+}
+
+func (g *Gemini) Model() string {
+	return g.modelName
+}
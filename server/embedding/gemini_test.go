@@ -0,0 +1,57 @@
+package embedding_test
+
+import (
+	"RAGScholar/embedding"
+	"RAGScholar/gemini"
+	"RAGScholar/gemini/geminitest"
+	"context"
+	"strings"
+	"testing"
+)
+
+func newGemini(t *testing.T, serverDimension, dimension int) *embedding.Gemini {
+	t.Helper()
+	server := geminitest.NewServer(serverDimension)
+	t.Cleanup(server.Close)
+
+	client, err := gemini.NewClient(context.Background(), gemini.Options{APIKey: "test", Endpoint: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return embedding.NewGemini(client, "embedding-test", dimension)
+}
+
+func TestGeminiEmbed(t *testing.T) {
+	embedder := newGemini(t, 8, 8)
+	ctx := context.Background()
+
+	vector, err := embedder.Embed(ctx, "graph neural networks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vector) != 8 {
+		t.Errorf("Embed returned %d values, want 8", len(vector))
+	}
+
+	vectors, err := embedder.EmbedBatch(ctx, []string{"graph neural networks", "string theory"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) != 2 || len(vectors[0]) != 8 || len(vectors[1]) != 8 {
+		t.Errorf("EmbedBatch returned %d vectors, want 2 of 8 values", len(vectors))
+	}
+}
+
+func TestGeminiRejectsWrongDimension(t *testing.T) {
+	// The model answers with 8 values but the collection expects 16
+	embedder := newGemini(t, 8, 16)
+	ctx := context.Background()
+
+	if _, err := embedder.Embed(ctx, "graph neural networks"); err == nil || !strings.Contains(err.Error(), "want 16") {
+		t.Errorf("Embed error = %v, want a dimension mismatch", err)
+	}
+	if _, err := embedder.EmbedBatch(ctx, []string{"graph neural networks"}); err == nil || !strings.Contains(err.Error(), "want 16") {
+		t.Errorf("EmbedBatch error = %v, want a dimension mismatch", err)
+	}
+}
@@ -0,0 +1,84 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Local is a deterministic feature-hashing embedder. It needs no network
+// access, so it is useful offline and in tests; vectors are only comparable
+// with other vectors produced by Local of the same dimension.
+type Local struct {
+	dimension int
+}
+
+func NewLocal(dimension int) *Local {
+	if dimension <= 0 {
+		dimension = DefaultDimension
+	}
+	return &Local{dimension: dimension}
+}
+
+func (l *Local) Embed(ctx context.Context, text string) ([]float32, error) {
+	tokens := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("cannot generate embedding for empty text")
+	}
+
+	vector := make([]float32, l.dimension)
+	for _, token := range tokens {
+		h := fnv.New64a()
+		h.Write([]byte(token))
+		sum := h.Sum64()
+
+		// The low bits pick the bucket and the top bit the sign, so that
+		// collisions tend to cancel out instead of piling up
+		index := int(sum % uint64(l.dimension))
+		if sum>>63 == 1 {
+			vector[index]--
+		} else {
+			vector[index]++
+		}
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return vector, nil
+	}
+
+	scale := float32(1 / math.Sqrt(norm))
+	for i := range vector {
+		vector[i] *= scale
+	}
+
+	return vector, nil
+}
+
+func (l *Local) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vector, err := l.Embed(ctx, text)
+		if err != nil {
+			return nil, fmt.Errorf("text at index %d: %w", i, err)
+		}
+		vectors[i] = vector
+	}
+	return vectors, nil
+}
+
+func (l *Local) Dimension() int {
+	return l.dimension
+}
+
+func (l *Local) Model() string {
+	return fmt.Sprintf("local-hash-%d", l.dimension)
+}
@@ -0,0 +1,109 @@
+package gemini
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// Class groups Gemini errors by how a caller should react to them
+type Class int
+
+const (
+	// Other errors, including canceled contexts, aren't retried
+	Other Class = iota
+	// RateLimited is a 429: the model's quota is exhausted for now
+	RateLimited
+	// Unavailable is a 5xx or a failed connection
+	Unavailable
+	// InvalidInput is a 400: the same request will be rejected again
+	InvalidInput
+)
+
+func (c Class) String() string {
+	switch c {
+	case RateLimited:
+		return "rate limited"
+	case Unavailable:
+		return "unavailable"
+	case InvalidInput:
+		return "invalid input"
+	default:
+		return "failed"
+	}
+}
+
+func (c Class) Retryable() bool {
+	return c == RateLimited || c == Unavailable
+}
+
+func Classify(err error) Class {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return Other
+	}
+
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.Code == http.StatusTooManyRequests:
+			return RateLimited
+		case apiErr.Code >= 500:
+			return Unavailable
+		case apiErr.Code == http.StatusBadRequest:
+			return InvalidInput
+		default:
+			return Other
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return Unavailable
+	}
+	return Other
+}
+
+// IsInvalidInput reports whether Gemini rejected the request itself
+func IsInvalidInput(err error) bool {
+	return Classify(err) == InvalidInput
+}
+
+// RetryAfter returns how long the API asked the caller to wait before
+// retrying, from a Retry-After header or the RetryInfo detail Gemini
+// attaches to quota errors, or 0 when it didn't say
+func RetryAfter(err error) time.Duration {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return 0
+	}
+
+	if header := strings.TrimSpace(apiErr.Header.Get("Retry-After")); header != "" {
+		if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+		if at, err := http.ParseTime(header); err == nil {
+			return max(time.Until(at), 0)
+		}
+	}
+
+	for _, detail := range apiErr.Details {
+		fields, ok := detail.(map[string]any)
+		if !ok || !strings.HasSuffix(fmt.Sprint(fields["@type"]), "google.rpc.RetryInfo") {
+			continue
+		}
+		// A protobuf Duration in JSON, e.g. "37s" or "1.5s"
+		if delay, ok := fields["retryDelay"].(string); ok {
+			if d, err := time.ParseDuration(delay); err == nil && d > 0 {
+				return d
+			}
+		}
+	}
+	return 0
+}
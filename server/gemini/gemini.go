@@ -0,0 +1,320 @@
+// Package gemini wraps the Gemini client shared by the service and the
+// consumer, so that every call paces itself per model, retries the failures
+// worth retrying and counts the tokens it used.
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"slices"
+
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+type Options struct {
+	APIKey   string
+	Endpoint string // overrides the Gemini API endpoint, e.g. with a geminitest server
+
+	// RequestsPerMinute is the rate each model starts at and never exceeds
+	RequestsPerMinute int
+	// MaxRetries bounds the retries of a call that was rate limited or hit a
+	// server error
+	MaxRetries int
+	// BaseDelay is the backoff before the first retry; it doubles with every
+	// retry up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+func DefaultOptions() Options {
+	return Options{
+		RequestsPerMinute: 60,
+		MaxRetries:        5,
+		BaseDelay:         time.Second,
+		MaxDelay:          time.Minute,
+	}
+}
+
+// Client is a Gemini client whose models wait for their own adaptive rate
+// limiter and retry rate-limited and server errors with backoff. It is safe
+// for concurrent use.
+type Client struct {
+	genai   *genai.Client
+	options Options
+
+	mu     sync.Mutex
+	models map[string]*model
+}
+
+// NewClient connects to Gemini. Zero Options fields take their defaults.
+func NewClient(ctx context.Context, options Options) (*Client, error) {
+	defaults := DefaultOptions()
+	if options.RequestsPerMinute <= 0 {
+		options.RequestsPerMinute = defaults.RequestsPerMinute
+	}
+	if options.MaxRetries < 0 {
+		options.MaxRetries = 0
+	}
+	if options.BaseDelay <= 0 {
+		options.BaseDelay = defaults.BaseDelay
+	}
+	if options.MaxDelay < options.BaseDelay {
+		options.MaxDelay = max(defaults.MaxDelay, options.BaseDelay)
+	}
+
+	clientOptions := []option.ClientOption{option.WithAPIKey(options.APIKey)}
+	if options.Endpoint != "" {
+		clientOptions = append(clientOptions, option.WithEndpoint(options.Endpoint))
+	}
+	client, err := genai.NewClient(ctx, clientOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{genai: client, options: options, models: make(map[string]*model)}, nil
+}
+
+func (c *Client) Close() error {
+	return c.genai.Close()
+}
+
+// GenerativeModel is a genai model whose calls go through the Client. Its
+// fields, such as SystemInstruction, are set as on the genai model.
+type GenerativeModel struct {
+	*genai.GenerativeModel
+	client *Client
+	name   string
+}
+
+func (c *Client) GenerativeModel(name string) *GenerativeModel {
+	return &GenerativeModel{GenerativeModel: c.genai.GenerativeModel(name), client: c, name: name}
+}
+
+func (m *GenerativeModel) GenerateContent(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
+	var resp *genai.GenerateContentResponse
+	err := m.client.Do(ctx, m.name, func(ctx context.Context) error {
+		var err error
+		resp, err = m.GenerativeModel.GenerateContent(ctx, parts...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.client.model(m.name).recordUsage(resp.UsageMetadata)
+	return resp, nil
+}
+
+// Stream generates content like GenerateContent but calls onResponse with
+// every piece of the response as it arrives. Only failures before the first
+// piece are retried, since the caller may already have used it.
+func (m *GenerativeModel) Stream(ctx context.Context, onResponse func(*genai.GenerateContentResponse) error, parts ...genai.Part) error {
+	var usage *genai.UsageMetadata
+	err := m.client.Do(ctx, m.name, func(ctx context.Context) error {
+		started, finished := false, false
+		iter := m.GenerativeModel.GenerateContentStream(ctx, parts...)
+		for {
+			resp, err := iter.Next()
+			if err == iterator.Done {
+				return nil
+			}
+			if err != nil {
+				if finished && isClosingBracket(err) {
+					return nil
+				}
+				if started {
+					return final{err}
+				}
+				return err
+			}
+
+			started = true
+			for _, candidate := range resp.Candidates {
+				if candidate.FinishReason != genai.FinishReasonUnspecified {
+					finished = true
+				}
+			}
+			// Every piece carries the usage so far
+			if resp.UsageMetadata != nil {
+				usage = resp.UsageMetadata
+			}
+			if err := onResponse(resp); err != nil {
+				return final{err}
+			}
+		}
+	})
+	m.client.model(m.name).recordUsage(usage)
+	return err
+}
+
+// isClosingBracket reports whether err is how the REST client ends a
+// stream it read in full. It decodes the stream as a JSON array, one element
+// at a time, and with encoding/json v2 the closing bracket comes back as a
+// syntax error rather than iterator.Done.
+func isClosingBracket(err error) bool {
+	var syntaxErr *json.SyntaxError
+	return errors.As(err, &syntaxErr) && strings.HasPrefix(syntaxErr.Error(), "invalid character ']' looking for beginning of value")
+}
+
+// EmbeddingModel is a genai embedding model whose calls go through the
+// Client
+type EmbeddingModel struct {
+	*genai.EmbeddingModel
+	client *Client
+	name   string
+}
+
+func (c *Client) EmbeddingModel(name string) *EmbeddingModel {
+	return &EmbeddingModel{EmbeddingModel: c.genai.EmbeddingModel(name), client: c, name: name}
+}
+
+func (m *EmbeddingModel) EmbedContent(ctx context.Context, parts ...genai.Part) (*genai.EmbedContentResponse, error) {
+	var resp *genai.EmbedContentResponse
+	err := m.client.Do(ctx, m.name, func(ctx context.Context) error {
+		var err error
+		resp, err = m.EmbeddingModel.EmbedContent(ctx, parts...)
+		return err
+	})
+	return resp, err
+}
+
+func (m *EmbeddingModel) BatchEmbedContents(ctx context.Context, batch *genai.EmbeddingBatch) (*genai.BatchEmbedContentsResponse, error) {
+	var resp *genai.BatchEmbedContentsResponse
+	err := m.client.Do(ctx, m.name, func(ctx context.Context) error {
+		var err error
+		resp, err = m.EmbeddingModel.BatchEmbedContents(ctx, batch)
+		return err
+	})
+	return resp, err
+}
+
+// Do calls the model named modelName through its rate limiter. Rate-limited
+// and server errors are retried up to MaxRetries times, waiting an
+// exponential backoff with jitter or the delay the API asked for, whichever
+// is longer; a rate-limited call also slows the model down. Any other error
+// is returned at once.
+func (c *Client) Do(ctx context.Context, modelName string, call func(context.Context) error) error {
+	m := c.model(modelName)
+
+	for attempt := 0; ; attempt++ {
+		if err := m.wait(ctx); err != nil {
+			return err
+		}
+
+		m.requests.Add(1)
+		err := call(ctx)
+		if err == nil {
+			m.succeeded()
+			return nil
+		}
+
+		var stop final
+		if errors.As(err, &stop) {
+			m.failures.Add(1)
+			return stop.err
+		}
+
+		class := Classify(err)
+		hint := RetryAfter(err)
+		if class == RateLimited {
+			m.rateLimited.Add(1)
+			m.throttle(hint)
+		}
+
+		if !class.Retryable() || attempt >= c.options.MaxRetries || ctx.Err() != nil {
+			m.failures.Add(1)
+			if attempt > 0 {
+				return fmt.Errorf("%s failed after %d attempts: %w", modelName, attempt+1, err)
+			}
+			return err
+		}
+
+		wait := max(c.backoff(attempt), hint)
+		log.Printf("Gemini %s: %s, retrying in %s (attempt %d of %d): %v",
+			modelName, class, wait.Round(time.Millisecond), attempt+1, c.options.MaxRetries, err)
+		m.retries.Add(1)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// backoff doubles BaseDelay with every attempt, capped at MaxDelay, and
+// picks a random delay in its upper half so that callers which failed
+// together don't retry together
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := c.options.MaxDelay
+	if attempt < 30 {
+		delay = min(c.options.BaseDelay<<attempt, c.options.MaxDelay)
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+func (c *Client) model(name string) *model {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m, ok := c.models[name]
+	if !ok {
+		m = newModel(c.options.RequestsPerMinute)
+		c.models[name] = m
+	}
+	return m
+}
+
+// Stats returns the usage of every model called so far, by model name
+func (c *Client) Stats() map[string]ModelStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := make(map[string]ModelStats, len(c.models))
+	for name, m := range c.models {
+		stats[name] = m.stats()
+	}
+	return stats
+}
+
+func (c *Client) String() string {
+	stats := c.Stats()
+	if len(stats) == 0 {
+		return "no requests"
+	}
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	lines := make([]string, len(names))
+	for i, name := range names {
+		s := stats[name]
+		lines[i] = fmt.Sprintf("%s: %d requests, %d retries, %d rate limited, %d failed, %d tokens (%d prompt, %d output), %.1f requests per minute",
+			name, s.Requests, s.Retries, s.RateLimited, s.Failures, s.TotalTokens, s.PromptTokens, s.OutputTokens, s.RequestsPerMinute)
+	}
+	return strings.Join(lines, "; ")
+}
+
+// final marks an error that must not be retried whatever its class
+type final struct {
+	err error
+}
+
+func (f final) Error() string {
+	return f.err.Error()
+}
+
+func (f final) Unwrap() error {
+	return f.err
+}
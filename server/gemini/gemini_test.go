@@ -0,0 +1,298 @@
+package gemini_test
+
+import (
+	"RAGScholar/gemini"
+	"RAGScholar/gemini/geminitest"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+const (
+	modelName         = "gemini-test"
+	requestsPerMinute = 6000
+	maxRetries        = 3
+)
+
+// answer is what the fake generates for a prompt without numbered sources
+const answer = "This is a generated response to the prompt."
+
+func newClient(t *testing.T) (*gemini.Client, *geminitest.Server) {
+	t.Helper()
+	server := geminitest.NewServer(8)
+	t.Cleanup(server.Close)
+
+	client, err := gemini.NewClient(context.Background(), gemini.Options{
+		APIKey:            "test",
+		Endpoint:          server.URL,
+		RequestsPerMinute: requestsPerMinute,
+		MaxRetries:        maxRetries,
+		BaseDelay:         time.Millisecond,
+		MaxDelay:          5 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client, server
+}
+
+func generate(client *gemini.Client) (string, error) {
+	resp, err := client.GenerativeModel(modelName).GenerateContent(context.Background(), genai.Text("one two three"))
+	if err != nil {
+		return "", err
+	}
+	return text(resp.Candidates[0].Content.Parts[0]), nil
+}
+
+func text(part genai.Part) string {
+	if text, ok := part.(genai.Text); ok {
+		return string(text)
+	}
+	return ""
+}
+
+func stats(t *testing.T, client *gemini.Client) gemini.ModelStats {
+	t.Helper()
+	return client.Stats()[modelName]
+}
+
+func closeTo(got, want float64) bool {
+	return math.Abs(got-want) < 1e-6
+}
+
+func TestGenerateContent(t *testing.T) {
+	client, server := newClient(t)
+
+	got, err := generate(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != answer {
+		t.Errorf("answer = %q, want %q", got, answer)
+	}
+	if got := server.Requests(modelName); got != 1 {
+		t.Errorf("server got %d requests, want 1", got)
+	}
+
+	// The fake counts a token per word
+	s := stats(t, client)
+	if s.Requests != 1 || s.PromptTokens != 3 || s.OutputTokens != 8 || s.TotalTokens != 11 {
+		t.Errorf("stats = %+v, want 1 request and 3 + 8 tokens", s)
+	}
+}
+
+func TestRateLimitedHonoursRetryInfo(t *testing.T) {
+	client, server := newClient(t)
+
+	const retryAfter = 300 * time.Millisecond
+	server.FailNext(1, http.StatusTooManyRequests, retryAfter)
+
+	start := time.Now()
+	if _, err := generate(client); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < retryAfter {
+		t.Errorf("retried after %s, want at least the %s Gemini asked for", elapsed, retryAfter)
+	}
+	if got := server.Requests(modelName); got != 2 {
+		t.Errorf("server got %d requests, want 2", got)
+	}
+
+	s := stats(t, client)
+	if s.Requests != 2 || s.Retries != 1 || s.RateLimited != 1 || s.Failures != 0 {
+		t.Errorf("stats = %+v, want 2 requests, 1 retry, 1 rate limited", s)
+	}
+}
+
+func TestRateLimitedHalvesRateUntilCallsSucceed(t *testing.T) {
+	client, server := newClient(t)
+
+	server.FailNext(2, http.StatusTooManyRequests, 0)
+	if _, err := generate(client); err != nil {
+		t.Fatal(err)
+	}
+	// Halved twice, then raised by a twentieth of the maximum for the
+	// successful retry
+	want := requestsPerMinute/4.0 + requestsPerMinute/20.0
+	if got := stats(t, client).RequestsPerMinute; !closeTo(got, want) {
+		t.Fatalf("rate after two 429s = %.1f per minute, want %.1f", got, want)
+	}
+
+	// 15 more successes get back to the maximum, and no further
+	for i := 0; i < 16; i++ {
+		if _, err := generate(client); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := stats(t, client).RequestsPerMinute; !closeTo(got, requestsPerMinute) {
+		t.Errorf("rate after recovering = %.1f per minute, want %d", got, requestsPerMinute)
+	}
+}
+
+func TestQuota(t *testing.T) {
+	client, server := newClient(t)
+	server.SetQuota(1)
+
+	if _, err := generate(client); err != nil {
+		t.Fatal(err)
+	}
+
+	// The next call is over the quota, and the fake asks to wait for the
+	// rest of the minute, longer than the caller is willing to
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	_, err := client.GenerativeModel(modelName).GenerateContent(ctx, genai.Text("one two three"))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("over-quota call error = %v, want the deadline to pass while waiting", err)
+	}
+
+	s := stats(t, client)
+	if s.RateLimited != 1 || !closeTo(s.RequestsPerMinute, requestsPerMinute/2) {
+		t.Errorf("stats = %+v, want 1 rate limited and the rate halved", s)
+	}
+	if got := server.Requests(modelName); got != 2 {
+		t.Errorf("server got %d requests, want 2 while paused", got)
+	}
+}
+
+func TestServerErrorsRetriedUpToMaxRetries(t *testing.T) {
+	client, server := newClient(t)
+
+	// 500s, since the REST client retries 503s on generateContent itself
+	server.FailNext(maxRetries, http.StatusInternalServerError, 0)
+	if _, err := generate(client); err != nil {
+		t.Fatalf("call failing %d times: %v", maxRetries, err)
+	}
+
+	server.FailNext(maxRetries+1, http.StatusInternalServerError, 0)
+	_, err := generate(client)
+	if err == nil {
+		t.Fatal("call failing every attempt succeeded")
+	}
+	if class := gemini.Classify(err); class != gemini.Unavailable {
+		t.Errorf("error class = %s, want %s", class, gemini.Unavailable)
+	}
+
+	// Each call made its first attempt and maxRetries retries
+	if got, want := server.Requests(modelName), 2*(maxRetries+1); got != want {
+		t.Errorf("server got %d requests, want %d", got, want)
+	}
+	s := stats(t, client)
+	if s.Retries != 2*maxRetries || s.Failures != 1 || s.RateLimited != 0 {
+		t.Errorf("stats = %+v, want %d retries and 1 failure", s, 2*maxRetries)
+	}
+	// Server errors don't slow the model down
+	if !closeTo(s.RequestsPerMinute, requestsPerMinute) {
+		t.Errorf("rate = %.1f per minute, want %d", s.RequestsPerMinute, requestsPerMinute)
+	}
+}
+
+func TestInvalidInputNotRetried(t *testing.T) {
+	client, server := newClient(t)
+
+	server.FailNext(1, http.StatusBadRequest, 0)
+	_, err := generate(client)
+	if !gemini.IsInvalidInput(err) {
+		t.Fatalf("error = %v, want invalid input", err)
+	}
+	if got := server.Requests(modelName); got != 1 {
+		t.Errorf("server got %d requests, want 1", got)
+	}
+	if s := stats(t, client); s.Retries != 0 || s.Failures != 1 {
+		t.Errorf("stats = %+v, want no retries and 1 failure", s)
+	}
+}
+
+func TestStream(t *testing.T) {
+	client, server := newClient(t)
+
+	// A failure before the first piece is retried
+	server.FailNext(1, http.StatusServiceUnavailable, 0)
+
+	var pieces []string
+	err := client.GenerativeModel(modelName).Stream(context.Background(), func(resp *genai.GenerateContentResponse) error {
+		for _, candidate := range resp.Candidates {
+			for _, part := range candidate.Content.Parts {
+				pieces = append(pieces, text(part))
+			}
+		}
+		return nil
+	}, genai.Text("one two three"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pieces) < 2 {
+		t.Errorf("got %d pieces, want the answer in several", len(pieces))
+	}
+	if got := strings.Join(pieces, ""); got != answer {
+		t.Errorf("streamed %q, want %q", got, answer)
+	}
+
+	// The usage of the last piece covers the whole answer
+	s := stats(t, client)
+	if s.Requests != 2 || s.Retries != 1 || s.PromptTokens != 3 || s.OutputTokens != 8 || s.TotalTokens != 11 {
+		t.Errorf("stats = %+v, want 2 requests, 1 retry and 3 + 8 tokens", s)
+	}
+}
+
+func TestStreamStopsOnCallbackError(t *testing.T) {
+	client, server := newClient(t)
+
+	stop := errors.New("client went away")
+	calls := 0
+	err := client.GenerativeModel(modelName).Stream(context.Background(), func(*genai.GenerateContentResponse) error {
+		calls++
+		return stop
+	}, genai.Text("one two three"))
+	if !errors.Is(err, stop) {
+		t.Fatalf("error = %v, want the callback's", err)
+	}
+	if calls != 1 || server.Requests(modelName) != 1 {
+		t.Errorf("callback ran %d times over %d requests, want once and no retry", calls, server.Requests(modelName))
+	}
+}
+
+func TestStreamReportsErrorsAfterFinishing(t *testing.T) {
+	// A stream cut off after the piece that finished the answer
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `[{"candidates": [{"content": {"role": "model", "parts": [{"text": "done"}]}, "finishReason": 1}]},`)
+		fmt.Fprint(w, `{"candidates": [{"content": `)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := gemini.NewClient(context.Background(), gemini.Options{
+		APIKey:     "test",
+		Endpoint:   server.URL,
+		MaxRetries: maxRetries,
+		BaseDelay:  time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	var pieces []string
+	err = client.GenerativeModel(modelName).Stream(context.Background(), func(resp *genai.GenerateContentResponse) error {
+		pieces = append(pieces, text(resp.Candidates[0].Content.Parts[0]))
+		return nil
+	}, genai.Text("one two three"))
+	if err == nil {
+		t.Fatal("a broken stream ended without an error")
+	}
+	if len(pieces) != 1 || requests != 1 {
+		t.Errorf("got pieces %q over %d requests, want the finished piece and no retry", pieces, requests)
+	}
+}
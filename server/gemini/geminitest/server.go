@@ -0,0 +1,328 @@
+// Package geminitest is a fake Gemini API for exercising the gemini
+// client's retries and rate limiting without a key or network access, and
+// for running the pipeline offline. It answers generateContent,
+// streamGenerateContent, embedContent and batchEmbedContents with
+// deterministic content, and can be told to fail like the real API does.
+package geminitest
+
+import (
+	"RAGScholar/embedding"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is a running fake Gemini API. Point gemini.Options.Endpoint at its
+// URL.
+type Server struct {
+	*httptest.Server
+
+	embedder *embedding.Local
+
+	mu       sync.Mutex
+	faults   []fault
+	quota    int                    // requests per minute per model; 0 is unlimited
+	recent   map[string][]time.Time // request times within the last minute, by model
+	requests map[string]int
+}
+
+type fault struct {
+	status     int
+	retryAfter time.Duration
+}
+
+// NewServer starts a fake Gemini API whose embeddings have dimension
+// values. Close it when done.
+func NewServer(dimension int) *Server {
+	s := &Server{
+		embedder: embedding.NewLocal(dimension),
+		recent:   make(map[string][]time.Time),
+		requests: make(map[string]int),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// FailNext makes the next n requests, to any model, fail with status. When
+// retryAfter is positive the failures ask the client to wait that long, the
+// way Gemini does on quota errors.
+func (s *Server) FailNext(n int, status int, retryAfter time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := 0; i < n; i++ {
+		s.faults = append(s.faults, fault{status: status, retryAfter: retryAfter})
+	}
+}
+
+// SetQuota lets every model serve requestsPerMinute requests in any minute
+// and rejects the rest with 429 until the oldest falls out of the minute; 0
+// removes the quota
+func (s *Server) SetQuota(requestsPerMinute int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.quota = requestsPerMinute
+}
+
+// Requests returns how many requests the model received, including failed
+// ones. The name may omit the "models/" prefix.
+func (s *Server) Requests(model string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[strings.TrimPrefix(model, "models/")]
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	// Paths are /v1beta/models/{model}:{method}
+	path := strings.TrimPrefix(r.URL.Path, "/v1beta/")
+	i := strings.LastIndex(path, ":")
+	if r.Method != http.MethodPost || i < 0 {
+		writeError(w, http.StatusNotFound, 0, "unknown method "+r.URL.Path)
+		return
+	}
+	model, method := strings.TrimPrefix(path[:i], "models/"), path[i+1:]
+
+	if status, retryAfter, rejected := s.admit(model); rejected {
+		writeError(w, status, retryAfter, "injected failure")
+		return
+	}
+
+	switch method {
+	case "generateContent":
+		s.generate(w, r, false)
+	case "streamGenerateContent":
+		s.generate(w, r, true)
+	case "embedContent":
+		s.embed(w, r, false)
+	case "batchEmbedContents":
+		s.embed(w, r, true)
+	default:
+		writeError(w, http.StatusNotFound, 0, "unknown method "+method)
+	}
+}
+
+// admit counts the request and decides whether it fails, either with an
+// injected fault or for going over the quota
+func (s *Server) admit(model string) (int, time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests[model]++
+
+	if len(s.faults) > 0 {
+		f := s.faults[0]
+		s.faults = s.faults[1:]
+		return f.status, f.retryAfter, true
+	}
+
+	if s.quota <= 0 {
+		return 0, 0, false
+	}
+	now := time.Now()
+	recent := s.recent[model]
+	for len(recent) > 0 && now.Sub(recent[0]) >= time.Minute {
+		recent = recent[1:]
+	}
+	if len(recent) >= s.quota {
+		s.recent[model] = recent
+		return http.StatusTooManyRequests, recent[0].Add(time.Minute).Sub(now), true
+	}
+	s.recent[model] = append(recent, now)
+	return 0, 0, false
+}
+
+type content struct {
+	Role  string `json:"role,omitempty"`
+	Parts []part `json:"parts"`
+}
+
+type part struct {
+	Text string `json:"text"`
+}
+
+func (c content) text() string {
+	texts := make([]string, len(c.Parts))
+	for i, p := range c.Parts {
+		texts[i] = p.Text
+	}
+	return strings.Join(texts, "\n")
+}
+
+type usageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
+}
+
+type candidate struct {
+	Content      content `json:"content"`
+	FinishReason int     `json:"finishReason,omitempty"`
+}
+
+type generateResponse struct {
+	Candidates    []candidate   `json:"candidates"`
+	UsageMetadata usageMetadata `json:"usageMetadata"`
+}
+
+var sourceLine = regexp.MustCompile(`(?m)^\[(\d+)\]`)
+
+// generate answers with a sentence citing every numbered source in the
+// prompt, so answers from the fake carry citations like real ones, and
+// counts a token per word
+func (s *Server) generate(w http.ResponseWriter, r *http.Request, stream bool) {
+	var request struct {
+		Contents          []content `json:"contents"`
+		SystemInstruction *content  `json:"systemInstruction"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || len(request.Contents) == 0 {
+		writeError(w, http.StatusBadRequest, 0, "request must have contents")
+		return
+	}
+
+	var prompt strings.Builder
+	if request.SystemInstruction != nil {
+		prompt.WriteString(request.SystemInstruction.text())
+	}
+	for _, c := range request.Contents {
+		prompt.WriteString("\n")
+		prompt.WriteString(c.text())
+	}
+
+	var citations []string
+	for _, match := range sourceLine.FindAllStringSubmatch(prompt.String(), -1) {
+		citations = append(citations, "["+match[1]+"]")
+	}
+	answer := "This is a generated response to the prompt."
+	if len(citations) > 0 {
+		answer = "This response is based on the retrieved sources " + strings.Join(citations, " ") + "."
+	}
+
+	promptTokens := len(strings.Fields(prompt.String()))
+	words := strings.Fields(answer)
+
+	if !stream {
+		writeJSON(w, generateResponse{
+			Candidates:    []candidate{{Content: content{Role: "model", Parts: []part{{Text: answer}}}, FinishReason: 1}},
+			UsageMetadata: usageMetadata{promptTokens, len(words), promptTokens + len(words)},
+		})
+		return
+	}
+
+	// The REST client reads a stream as one JSON array of responses, a few
+	// words each
+	w.Header().Set("Content-Type", "application/json")
+	flusher, _ := w.(http.Flusher)
+	fmt.Fprint(w, "[")
+	const wordsPerChunk = 4
+	for start := 0; start < len(words); start += wordsPerChunk {
+		end := min(start+wordsPerChunk, len(words))
+		text := strings.Join(words[start:end], " ")
+		if end < len(words) {
+			text += " "
+		}
+		chunk := generateResponse{
+			Candidates:    []candidate{{Content: content{Role: "model", Parts: []part{{Text: text}}}}},
+			UsageMetadata: usageMetadata{promptTokens, end, promptTokens + end},
+		}
+		if end == len(words) {
+			chunk.Candidates[0].FinishReason = 1
+		}
+		if start > 0 {
+			fmt.Fprint(w, ",")
+		}
+		json.NewEncoder(w).Encode(chunk)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	fmt.Fprint(w, "]")
+}
+
+type embedRequest struct {
+	Content content `json:"content"`
+}
+
+type embeddingValues struct {
+	Values []float32 `json:"values"`
+}
+
+// embed answers with the vectors of embedding.Local, so that searches over
+// fake embeddings still rank related texts together. Like Gemini, a batch
+// with one text it can't embed fails as a whole.
+func (s *Server) embed(w http.ResponseWriter, r *http.Request, batch bool) {
+	var requests []embedRequest
+	if batch {
+		var request struct {
+			Requests []embedRequest `json:"requests"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeError(w, http.StatusBadRequest, 0, "invalid request")
+			return
+		}
+		requests = request.Requests
+	} else {
+		var request embedRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeError(w, http.StatusBadRequest, 0, "invalid request")
+			return
+		}
+		requests = []embedRequest{request}
+	}
+
+	vectors := make([]embeddingValues, len(requests))
+	for i, request := range requests {
+		vector, err := s.embedder.Embed(context.Background(), request.Content.text())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, 0, fmt.Sprintf("requests[%d]: %v", i, err))
+			return
+		}
+		vectors[i] = embeddingValues{Values: vector}
+	}
+
+	if batch {
+		writeJSON(w, map[string]any{"embeddings": vectors})
+	} else {
+		writeJSON(w, map[string]any{"embedding": vectors[0]})
+	}
+}
+
+var statusNames = map[int]string{
+	http.StatusBadRequest:          "INVALID_ARGUMENT",
+	http.StatusNotFound:            "NOT_FOUND",
+	http.StatusTooManyRequests:     "RESOURCE_EXHAUSTED",
+	http.StatusInternalServerError: "INTERNAL",
+	http.StatusServiceUnavailable:  "UNAVAILABLE",
+}
+
+// writeError writes an error in the Google API format, with the RetryInfo
+// detail Gemini sends on quota errors when retryAfter is positive
+func writeError(w http.ResponseWriter, status int, retryAfter time.Duration, message string) {
+	body := map[string]any{
+		"code":    status,
+		"message": message,
+		"status":  statusNames[status],
+	}
+	if retryAfter > 0 {
+		body["details"] = []map[string]any{{
+			"@type":      "type.googleapis.com/google.rpc.RetryInfo",
+			"retryDelay": fmt.Sprintf("%.3fs", retryAfter.Seconds()),
+		}}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"error": body})
+}
+
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,121 @@
+package gemini
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"golang.org/x/time/rate"
+)
+
+// minRequestsPerMinute is as far as rate limiting slows a model down
+const minRequestsPerMinute = 1
+
+// model is the rate limiter and usage of one Gemini model. Its rate adapts
+// to the quota the API actually grants: every rate-limited call halves it,
+// and every successful one raises it by a twentieth of the configured rate,
+// back up to that rate.
+type model struct {
+	limiter *rate.Limiter
+	maxRate rate.Limit
+	minRate rate.Limit
+
+	mu sync.Mutex
+	// pausedUntil holds every call back until the time the API asked to
+	// retry at
+	pausedUntil time.Time
+
+	requests     atomic.Uint64
+	retries      atomic.Uint64
+	rateLimited  atomic.Uint64
+	failures     atomic.Uint64
+	promptTokens atomic.Uint64
+	outputTokens atomic.Uint64
+	totalTokens  atomic.Uint64
+}
+
+// ModelStats is the usage of one model since the client was created
+type ModelStats struct {
+	Requests     uint64 `json:"requests"`
+	Retries      uint64 `json:"retries"`
+	RateLimited  uint64 `json:"rateLimited"`
+	Failures     uint64 `json:"failures"`
+	PromptTokens uint64 `json:"promptTokens"`
+	OutputTokens uint64 `json:"outputTokens"`
+	TotalTokens  uint64 `json:"totalTokens"`
+	// RequestsPerMinute is the rate the limiter currently allows
+	RequestsPerMinute float64 `json:"requestsPerMinute"`
+}
+
+func newModel(requestsPerMinute int) *model {
+	maxRate := perMinute(float64(requestsPerMinute))
+	return &model{
+		limiter: rate.NewLimiter(maxRate, 1),
+		maxRate: maxRate,
+		minRate: min(perMinute(minRequestsPerMinute), maxRate),
+	}
+}
+
+func (m *model) wait(ctx context.Context) error {
+	m.mu.Lock()
+	pause := time.Until(m.pausedUntil)
+	m.mu.Unlock()
+
+	if pause > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pause):
+		}
+	}
+	return m.limiter.Wait(ctx)
+}
+
+func (m *model) succeeded() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if limit := m.limiter.Limit(); limit < m.maxRate {
+		m.limiter.SetLimit(min(limit+m.maxRate/20, m.maxRate))
+	}
+}
+
+// throttle halves the rate after a rate-limited call and pauses the model
+// for retryAfter when the API gave a delay
+func (m *model) throttle(retryAfter time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.limiter.SetLimit(max(m.limiter.Limit()/2, m.minRate))
+	if until := time.Now().Add(retryAfter); until.After(m.pausedUntil) {
+		m.pausedUntil = until
+	}
+}
+
+func (m *model) recordUsage(usage *genai.UsageMetadata) {
+	if usage == nil {
+		return
+	}
+	m.promptTokens.Add(uint64(max(usage.PromptTokenCount, 0)))
+	m.outputTokens.Add(uint64(max(usage.CandidatesTokenCount, 0)))
+	m.totalTokens.Add(uint64(max(usage.TotalTokenCount, 0)))
+}
+
+func (m *model) stats() ModelStats {
+	return ModelStats{
+		Requests:          m.requests.Load(),
+		Retries:           m.retries.Load(),
+		RateLimited:       m.rateLimited.Load(),
+		Failures:          m.failures.Load(),
+		PromptTokens:      m.promptTokens.Load(),
+		OutputTokens:      m.outputTokens.Load(),
+		TotalTokens:       m.totalTokens.Load(),
+		RequestsPerMinute: float64(m.limiter.Limit()) * 60,
+	}
+}
+
+func perMinute(requests float64) rate.Limit {
+	return rate.Limit(requests / 60)
+}
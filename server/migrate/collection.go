@@ -0,0 +1,171 @@
+// Package migrate keeps the Qdrant collections behind stable aliases, so the
+// service can keep reading "papers" while a migration builds a new
+// collection with a different schema, embedding model or distance and then
+// switches the alias over.
+package migrate
+
+import (
+	"RAGScholar/payload"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Spec describes the collection an alias should point at
+type Spec struct {
+	Alias          string
+	SchemaVersion  int
+	EmbeddingModel string
+	VectorSize     uint64
+	Distance       string
+	Indexes        []payload.Index
+
+	// Remap rewrites a stored payload into the current schema; nil keeps
+	// payloads as they are
+	Remap func(fields map[string]*qdrant.Value) (map[string]*qdrant.Value, error)
+	// EmbedText returns the text a point's vector is computed from
+	EmbedText func(fields map[string]*qdrant.Value) string
+}
+
+var distances = map[string]qdrant.Distance{
+	"cosine":    qdrant.Distance_Cosine,
+	"dot":       qdrant.Distance_Dot,
+	"euclid":    qdrant.Distance_Euclid,
+	"manhattan": qdrant.Distance_Manhattan,
+}
+
+// ParseDistance maps a configured distance name to Qdrant's enum
+func ParseDistance(name string) (qdrant.Distance, error) {
+	distance, ok := distances[name]
+	if !ok {
+		return qdrant.Distance_UnknownDistance, fmt.Errorf("unknown distance %q", name)
+	}
+	return distance, nil
+}
+
+// target is what an alias name currently resolves to in Qdrant
+type target struct {
+	collection string
+	isAlias    bool
+	exists     bool
+}
+
+func resolve(ctx context.Context, client *qdrant.Client, name string) (target, error) {
+	aliases, err := client.ListAliases(ctx)
+	if err != nil {
+		return target{}, fmt.Errorf("failed to list aliases: %w", err)
+	}
+	for _, alias := range aliases {
+		if alias.GetAliasName() == name {
+			return target{collection: alias.GetCollectionName(), isAlias: true, exists: true}, nil
+		}
+	}
+
+	exists, err := client.CollectionExists(ctx, name)
+	if err != nil {
+		return target{}, fmt.Errorf("failed to check if collection exists: %w", err)
+	}
+	return target{collection: name, exists: exists}, nil
+}
+
+// versionedName names the collection a migration creates for spec
+func versionedName(spec Spec, now time.Time) string {
+	return fmt.Sprintf("%s_v%d_%s", spec.Alias, spec.SchemaVersion, now.UTC().Format("20060102150405"))
+}
+
+func createCollection(ctx context.Context, client *qdrant.Client, name string, spec Spec) error {
+	distance, err := ParseDistance(spec.Distance)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Creating collection '%s' with vector size %d and %s distance", name, spec.VectorSize, spec.Distance)
+	err = client.CreateCollection(ctx, &qdrant.CreateCollection{
+		CollectionName: name,
+		VectorsConfig: qdrant.NewVectorsConfig(&qdrant.VectorParams{
+			Size:     spec.VectorSize,
+			Distance: distance,
+		}),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create collection %s: %w", name, err)
+	}
+
+	return payload.EnsureIndexes(ctx, client, name, spec.Indexes)
+}
+
+// EnsureCollection makes spec.Alias usable. A fresh install gets a
+// versioned collection behind the alias; an existing one is left alone,
+// with a warning when it was built differently from spec and needs
+// `ragscholar migrate`.
+func EnsureCollection(ctx context.Context, client *qdrant.Client, metadataCollection string, spec Spec) error {
+	current, err := resolve(ctx, client, spec.Alias)
+	if err != nil {
+		return err
+	}
+
+	if !current.exists {
+		name := versionedName(spec, time.Now())
+		if err := createCollection(ctx, client, name, spec); err != nil {
+			return err
+		}
+		if err := client.CreateAlias(ctx, spec.Alias, name); err != nil {
+			return fmt.Errorf("failed to point alias %s at %s: %w", spec.Alias, name, err)
+		}
+		log.Printf("Collection '%s' created behind alias '%s'", name, spec.Alias)
+		return SaveMetadata(ctx, client, metadataCollection, metadataFor(spec, name))
+	}
+
+	if !current.isAlias {
+		log.Printf("Collection '%s' is not behind an alias; run `ragscholar migrate` to version it", spec.Alias)
+	} else {
+		meta, err := LoadMetadata(ctx, client, metadataCollection, spec.Alias)
+		if err != nil {
+			return err
+		}
+		if reason := outdated(meta, current, spec); reason != "" {
+			log.Printf("Collection '%s' is outdated (%s); run `ragscholar migrate`", spec.Alias, reason)
+		} else {
+			log.Printf("Collection '%s' -> '%s' is at schema version %d", spec.Alias, current.collection, spec.SchemaVersion)
+		}
+	}
+
+	// Indexes added since the collection was created
+	return payload.EnsureIndexes(ctx, client, spec.Alias, spec.Indexes)
+}
+
+// outdated explains why the collection behind an alias doesn't match spec,
+// or returns "" when it does
+func outdated(meta *Metadata, current target, spec Spec) string {
+	switch {
+	case !current.isAlias:
+		return "not behind an alias"
+	case meta == nil:
+		return "no schema version recorded"
+	case meta.Collection != current.collection:
+		return fmt.Sprintf("metadata describes %s", meta.Collection)
+	case meta.SchemaVersion != spec.SchemaVersion:
+		return fmt.Sprintf("schema version %d, want %d", meta.SchemaVersion, spec.SchemaVersion)
+	case meta.EmbeddingModel != spec.EmbeddingModel || meta.VectorSize != spec.VectorSize:
+		return fmt.Sprintf("embedded with %s (%d dimensions), want %s (%d dimensions)",
+			meta.EmbeddingModel, meta.VectorSize, spec.EmbeddingModel, spec.VectorSize)
+	case meta.Distance != spec.Distance:
+		return fmt.Sprintf("%s distance, want %s", meta.Distance, spec.Distance)
+	}
+	return ""
+}
+
+func metadataFor(spec Spec, collection string) Metadata {
+	return Metadata{
+		Alias:          spec.Alias,
+		Collection:     collection,
+		SchemaVersion:  spec.SchemaVersion,
+		EmbeddingModel: spec.EmbeddingModel,
+		VectorSize:     spec.VectorSize,
+		Distance:       spec.Distance,
+		MigratedAt:     time.Now(),
+	}
+}
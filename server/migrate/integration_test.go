@@ -0,0 +1,185 @@
+//go:build integration
+
+// Run against a disposable Qdrant with
+//
+//	go test -tags integration ./migrate
+//
+// QDRANT_HOST and QDRANT_PORT default to localhost:6334, as in the
+// configuration.
+
+package migrate
+
+import (
+	"RAGScholar/embedding"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+func dialQdrant(t *testing.T) *qdrant.Client {
+	t.Helper()
+	host, port := os.Getenv("QDRANT_HOST"), 6334
+	if host == "" {
+		host = "localhost"
+	}
+	if value := os.Getenv("QDRANT_PORT"); value != "" {
+		var err error
+		if port, err = strconv.Atoi(value); err != nil {
+			t.Fatalf("QDRANT_PORT: %v", err)
+		}
+	}
+
+	client, err := qdrant.NewClient(&qdrant.Config{Host: host, Port: port})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	if _, err := client.HealthCheck(context.Background()); err != nil {
+		t.Fatalf("Qdrant at %s:%d is unreachable: %v", host, port, err)
+	}
+	return client
+}
+
+func count(t *testing.T, client *qdrant.Client, name string) uint64 {
+	t.Helper()
+	n, err := client.Count(context.Background(), &qdrant.CountPoints{CollectionName: name, Exact: qdrant.PtrOf(true)})
+	if err != nil {
+		t.Fatalf("count %s: %v", name, err)
+	}
+	return n
+}
+
+func TestRunReplacesPlainCollectionWithAlias(t *testing.T) {
+	ctx := context.Background()
+	client := dialQdrant(t)
+	embedder := embedding.NewLocal(8)
+
+	alias := fmt.Sprintf("migrate_test_%d", time.Now().UnixNano())
+	metadataCollection := alias + "_metadata"
+	t.Cleanup(func() {
+		client.DeleteAlias(ctx, alias)
+		collections, _ := client.ListCollections(ctx)
+		for _, name := range collections {
+			if strings.HasPrefix(name, alias) {
+				client.DeleteCollection(ctx, name)
+			}
+		}
+	})
+
+	// A pre-alias install: a plain collection under the alias's name
+	err := client.CreateCollection(ctx, &qdrant.CreateCollection{
+		CollectionName: alias,
+		VectorsConfig:  qdrant.NewVectorsConfig(&qdrant.VectorParams{Size: 8, Distance: qdrant.Distance_Cosine}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const stored = 10
+	points := make([]*qdrant.PointStruct, stored)
+	for i := range points {
+		text := fmt.Sprintf("paper %d", i)
+		vector, err := embedder.Embed(ctx, text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		points[i] = &qdrant.PointStruct{
+			Id:      qdrant.NewIDNum(uint64(i + 1)),
+			Vectors: qdrant.NewVectorsDense(vector),
+			Payload: qdrant.NewValueMap(map[string]any{"text": text}),
+		}
+	}
+	// An empty text can't be re-embedded and is left behind
+	points = append(points, &qdrant.PointStruct{
+		Id:      qdrant.NewIDNum(stored + 1),
+		Vectors: qdrant.NewVectorsDense(make([]float32, 8)),
+		Payload: qdrant.NewValueMap(map[string]any{"text": ""}),
+	})
+	if _, err := client.Upsert(ctx, &qdrant.UpsertPoints{CollectionName: alias, Wait: qdrant.PtrOf(true), Points: points}); err != nil {
+		t.Fatal(err)
+	}
+
+	spec := Spec{
+		Alias:          alias,
+		SchemaVersion:  1,
+		EmbeddingModel: embedder.Model(),
+		VectorSize:     8,
+		Distance:       "cosine",
+		EmbedText: func(fields map[string]*qdrant.Value) string {
+			return fields["text"].GetStringValue()
+		},
+	}
+	opts := Options{MetadataCollection: metadataCollection, BatchSize: 4}
+	if err := Run(ctx, client, embedder, spec, opts); err != nil {
+		t.Fatalf("first migration: %v", err)
+	}
+
+	current, err := resolve(ctx, client, alias)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !current.isAlias {
+		t.Fatalf("%s is still a plain collection", alias)
+	}
+	if got := count(t, client, alias); got != stored {
+		t.Errorf("alias serves %d points, want %d", got, stored)
+	}
+
+	// The plain collection was kept verbatim as a backup
+	collections, err := client.ListCollections(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var backup string
+	for _, name := range collections {
+		if strings.HasPrefix(name, alias+"_backup_") {
+			backup = name
+		}
+	}
+	if backup == "" {
+		t.Fatalf("no backup of %s among %v", alias, collections)
+	}
+	if got := count(t, client, backup); got != stored+1 {
+		t.Errorf("backup holds %d points, want all %d", got, stored+1)
+	}
+
+	meta, err := LoadMetadata(ctx, client, metadataCollection, alias)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta == nil || meta.Collection != current.collection || outdated(meta, current, spec) != "" {
+		t.Errorf("metadata after migrating = %+v, want it to describe %s", meta, current.collection)
+	}
+
+	// An up-to-date alias is left alone
+	if err := Run(ctx, client, embedder, spec, opts); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := resolve(ctx, client, alias); again.collection != current.collection {
+		t.Errorf("an up-to-date alias moved from %s to %s", current.collection, again.collection)
+	}
+
+	// A schema change moves the alias and, with DropOld, deletes the
+	// collection it pointed at. Versioned names have one-second resolution.
+	time.Sleep(time.Second)
+	spec.SchemaVersion = 2
+	opts.DropOld = true
+	if err := Run(ctx, client, embedder, spec, opts); err != nil {
+		t.Fatalf("second migration: %v", err)
+	}
+	next, err := resolve(ctx, client, alias)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.collection == current.collection || count(t, client, alias) != stored {
+		t.Errorf("alias points at %s with %d points, want a new collection with %d", next.collection, count(t, client, alias), stored)
+	}
+	if exists, err := client.CollectionExists(ctx, current.collection); err != nil || exists {
+		t.Errorf("%s still exists after DropOld (err %v)", current.collection, err)
+	}
+}
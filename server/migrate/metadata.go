@@ -0,0 +1,113 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// metadataNamespace scopes the UUIDv5 IDs of metadata points, one per alias
+var metadataNamespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("ragscholar:metadata"))
+
+// Metadata records what the collection behind an alias was built with
+type Metadata struct {
+	Alias          string
+	Collection     string
+	SchemaVersion  int
+	EmbeddingModel string
+	VectorSize     uint64
+	Distance       string
+	MigratedAt     time.Time
+}
+
+// ensureMetadataCollection creates the metadata collection. Qdrant requires
+// a vector per point, so each record carries a constant one-dimensional one.
+func ensureMetadataCollection(ctx context.Context, client *qdrant.Client, name string) error {
+	exists, err := client.CollectionExists(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to check if collection exists: %w", err)
+	}
+	if exists {
+		return nil
+	}
+
+	err = client.CreateCollection(ctx, &qdrant.CreateCollection{
+		CollectionName: name,
+		VectorsConfig: qdrant.NewVectorsConfig(&qdrant.VectorParams{
+			Size:     1,
+			Distance: qdrant.Distance_Dot,
+		}),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create metadata collection: %w", err)
+	}
+	return nil
+}
+
+// LoadMetadata returns the record for alias, or nil if there is none
+func LoadMetadata(ctx context.Context, client *qdrant.Client, metadataCollection, alias string) (*Metadata, error) {
+	if err := ensureMetadataCollection(ctx, client, metadataCollection); err != nil {
+		return nil, err
+	}
+
+	points, err := client.Get(ctx, &qdrant.GetPoints{
+		CollectionName: metadataCollection,
+		Ids:            []*qdrant.PointId{metadataPointID(alias)},
+		WithPayload:    qdrant.NewWithPayload(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata for %s: %w", alias, err)
+	}
+	if len(points) == 0 {
+		return nil, nil
+	}
+
+	fields := points[0].Payload
+	meta := &Metadata{
+		Alias:          fields["alias"].GetStringValue(),
+		Collection:     fields["collection"].GetStringValue(),
+		SchemaVersion:  int(fields["schemaVersion"].GetIntegerValue()),
+		EmbeddingModel: fields["embeddingModel"].GetStringValue(),
+		VectorSize:     uint64(fields["vectorSize"].GetIntegerValue()),
+		Distance:       fields["distance"].GetStringValue(),
+	}
+	meta.MigratedAt, _ = time.Parse(time.RFC3339, fields["migratedAt"].GetStringValue())
+
+	return meta, nil
+}
+
+// SaveMetadata replaces the record for meta.Alias
+func SaveMetadata(ctx context.Context, client *qdrant.Client, metadataCollection string, meta Metadata) error {
+	if err := ensureMetadataCollection(ctx, client, metadataCollection); err != nil {
+		return err
+	}
+
+	_, err := client.Upsert(ctx, &qdrant.UpsertPoints{
+		CollectionName: metadataCollection,
+		Wait:           qdrant.PtrOf(true),
+		Points: []*qdrant.PointStruct{{
+			Id:      metadataPointID(meta.Alias),
+			Vectors: qdrant.NewVectorsDense([]float32{1}),
+			Payload: map[string]*qdrant.Value{
+				"alias":          qdrant.NewValueString(meta.Alias),
+				"collection":     qdrant.NewValueString(meta.Collection),
+				"schemaVersion":  qdrant.NewValueInt(int64(meta.SchemaVersion)),
+				"embeddingModel": qdrant.NewValueString(meta.EmbeddingModel),
+				"vectorSize":     qdrant.NewValueInt(int64(meta.VectorSize)),
+				"distance":       qdrant.NewValueString(meta.Distance),
+				"migratedAt":     qdrant.NewValueString(meta.MigratedAt.UTC().Format(time.RFC3339)),
+			},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save metadata for %s: %w", meta.Alias, err)
+	}
+	return nil
+}
+
+func metadataPointID(alias string) *qdrant.PointId {
+	return qdrant.NewIDUUID(uuid.NewSHA1(metadataNamespace, []byte(alias)).String())
+}
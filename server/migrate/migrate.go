@@ -0,0 +1,313 @@
+package migrate
+
+import (
+	"RAGScholar/embedding"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+const DefaultBatchSize = 64
+
+// Options controls one migration
+type Options struct {
+	MetadataCollection string
+	BatchSize          int
+	// Force migrates even when the collection already matches the spec
+	Force bool
+	// Reembed recomputes every vector even when the embedding model is
+	// unchanged
+	Reembed bool
+	// DropOld deletes the previous collection once the alias has moved
+	DropOld bool
+}
+
+// Run copies the collection behind spec.Alias into a new collection built
+// from spec, re-mapping payloads and re-embedding when the embedding model
+// changed, then switches the alias in one atomic update. Readers keep using
+// the old collection until the switch. Writes made to the old collection
+// during the copy are not carried over, so pause the consumer while
+// migrating.
+func Run(ctx context.Context, client *qdrant.Client, embedder embedding.Embedder, spec Spec, opts Options) error {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+
+	source, err := resolve(ctx, client, spec.Alias)
+	if err != nil {
+		return err
+	}
+	if !source.exists {
+		return fmt.Errorf("nothing to migrate: %s does not exist", spec.Alias)
+	}
+
+	meta, err := LoadMetadata(ctx, client, opts.MetadataCollection, spec.Alias)
+	if err != nil {
+		return err
+	}
+
+	reason := outdated(meta, source, spec)
+	if reason == "" && !opts.Force {
+		log.Printf("%s is up to date at schema version %d", spec.Alias, spec.SchemaVersion)
+		return nil
+	}
+	if reason == "" {
+		reason = "forced"
+	}
+	log.Printf("Migrating %s (%s)", spec.Alias, reason)
+
+	reembed := opts.Reembed || meta == nil || meta.EmbeddingModel != spec.EmbeddingModel
+	if !reembed {
+		info, err := client.GetCollectionInfo(ctx, source.collection)
+		if err != nil {
+			return fmt.Errorf("failed to inspect %s: %w", source.collection, err)
+		}
+		reembed = info.GetConfig().GetParams().GetVectorsConfig().GetParams().GetSize() != spec.VectorSize
+	}
+	if reembed && spec.EmbedText == nil {
+		return fmt.Errorf("%s needs re-embedding but its spec has no embed text", spec.Alias)
+	}
+
+	now := time.Now()
+	name := versionedName(spec, now)
+	if err := createCollection(ctx, client, name, spec); err != nil {
+		return err
+	}
+
+	copied, skipped, err := copyVerified(ctx, client, embedder, source.collection, name, spec, reembed, opts.BatchSize)
+	if err != nil {
+		return fmt.Errorf("migration into %s failed, the alias was not switched: %w", name, err)
+	}
+	log.Printf("Copied %d points into %s (%d skipped)", copied, name, skipped)
+
+	// The collection the alias pointed at before the switch, kept for
+	// rollback unless DropOld is set
+	previous := source.collection
+	if source.isAlias {
+		// Both actions are applied atomically, so readers never see the
+		// alias missing
+		err = client.UpdateAliases(ctx, []*qdrant.AliasOperations{
+			qdrant.NewAliasDelete(spec.Alias),
+			qdrant.NewAliasCreate(spec.Alias, name),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to switch alias %s to %s: %w", spec.Alias, name, err)
+		}
+	} else {
+		previous, err = replaceWithAlias(ctx, client, spec, name, now, opts.BatchSize)
+		if err != nil {
+			return err
+		}
+	}
+	log.Printf("Alias %s now points at %s", spec.Alias, name)
+
+	if err := SaveMetadata(ctx, client, opts.MetadataCollection, metadataFor(spec, name)); err != nil {
+		return err
+	}
+
+	if opts.DropOld {
+		if err := client.DeleteCollection(ctx, previous); err != nil {
+			return fmt.Errorf("failed to delete old collection %s: %w", previous, err)
+		}
+		log.Printf("Deleted old collection %s", previous)
+	} else {
+		log.Printf("Kept old collection %s for rollback", previous)
+	}
+
+	return nil
+}
+
+// replaceWithAlias turns the plain collection named spec.Alias into an alias
+// to target, and returns the name of the backup it keeps of the plain
+// collection. A collection and an alias can't share a name, so the plain
+// collection has to go before the alias can exist and requests in between
+// fail; this only happens once, on the first migration. The backup is an
+// exact copy made beforehand, and the alias falls back to it if it can't
+// be pointed at target, so the original data is never lost.
+func replaceWithAlias(ctx context.Context, client *qdrant.Client, spec Spec, target string, now time.Time, batchSize int) (string, error) {
+	backup := fmt.Sprintf("%s_backup_%s", spec.Alias, now.UTC().Format("20060102150405"))
+	info, err := client.GetCollectionInfo(ctx, spec.Alias)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect %s: %w", spec.Alias, err)
+	}
+	log.Printf("Backing up collection %s to %s", spec.Alias, backup)
+	err = client.CreateCollection(ctx, &qdrant.CreateCollection{
+		CollectionName: backup,
+		VectorsConfig:  info.GetConfig().GetParams().GetVectorsConfig(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create backup collection %s: %w", backup, err)
+	}
+	if _, _, err := copyVerified(ctx, client, nil, spec.Alias, backup, Spec{}, false, batchSize); err != nil {
+		return "", fmt.Errorf("backup of %s failed, the alias was not switched: %w", spec.Alias, err)
+	}
+
+	// The migrated copy was verified before the backup started, so only
+	// writes made since then are missing from it; the consumer should be
+	// paused while migrating
+	source, err := client.Count(ctx, &qdrant.CountPoints{CollectionName: spec.Alias, Exact: qdrant.PtrOf(true)})
+	if err != nil {
+		return "", fmt.Errorf("failed to count %s: %w", spec.Alias, err)
+	}
+	copied, err := client.Count(ctx, &qdrant.CountPoints{CollectionName: backup, Exact: qdrant.PtrOf(true)})
+	if err != nil {
+		return "", fmt.Errorf("failed to count %s: %w", backup, err)
+	}
+	if source != copied {
+		discard(ctx, client, backup)
+		return "", fmt.Errorf("%s changed during the migration (%d points, backup has %d), the alias was not switched", spec.Alias, source, copied)
+	}
+
+	log.Printf("Replacing collection %s with an alias to %s", spec.Alias, target)
+	if err := client.DeleteCollection(ctx, spec.Alias); err != nil {
+		discard(ctx, client, backup)
+		return "", fmt.Errorf("failed to delete collection %s: %w", spec.Alias, err)
+	}
+	if err := client.CreateAlias(ctx, spec.Alias, target); err != nil {
+		if fallbackErr := client.CreateAlias(ctx, spec.Alias, backup); fallbackErr != nil {
+			return "", fmt.Errorf("failed to create alias %s to %s (%w), or to the backup %s: %v", spec.Alias, target, err, backup, fallbackErr)
+		}
+		return "", fmt.Errorf("failed to create alias %s to %s, it points at the backup %s instead: %w", spec.Alias, target, backup, err)
+	}
+	return backup, nil
+}
+
+// copyVerified copies the source collection into target with copyPoints and
+// checks that target holds every copied point. On failure target is
+// deleted, so a failed migration leaves nothing behind.
+func copyVerified(ctx context.Context, client *qdrant.Client, embedder embedding.Embedder, sourceName, targetName string,
+	spec Spec, reembed bool, batchSize int) (copied int, skipped int, err error) {
+
+	copied, skipped, err = copyPoints(ctx, client, embedder, sourceName, targetName, spec, reembed, batchSize)
+	if err != nil {
+		discard(ctx, client, targetName)
+		return copied, skipped, err
+	}
+
+	total, err := client.Count(ctx, &qdrant.CountPoints{CollectionName: targetName, Exact: qdrant.PtrOf(true)})
+	if err != nil {
+		discard(ctx, client, targetName)
+		return copied, skipped, fmt.Errorf("failed to count %s: %w", targetName, err)
+	}
+	if total != uint64(copied) {
+		discard(ctx, client, targetName)
+		return copied, skipped, fmt.Errorf("copied %d points into %s but it holds %d", copied, targetName, total)
+	}
+	return copied, skipped, nil
+}
+
+// discard deletes a collection a failed migration created
+func discard(ctx context.Context, client *qdrant.Client, name string) {
+	if err := client.DeleteCollection(ctx, name); err != nil {
+		log.Printf("Failed to delete %s, delete it by hand: %v", name, err)
+		return
+	}
+	log.Printf("Deleted %s", name)
+}
+
+// copyPoints scrolls through the source collection in batches, writing each
+// point into the target under the same ID
+func copyPoints(ctx context.Context, client *qdrant.Client, embedder embedding.Embedder, sourceName, targetName string,
+	spec Spec, reembed bool, batchSize int) (copied int, skipped int, err error) {
+
+	limit := uint32(batchSize)
+	var offset *qdrant.PointId
+
+	for {
+		// The client's Scroll helper drops the next page offset, so call the
+		// gRPC service directly
+		resp, err := client.GetPointsClient().Scroll(ctx, &qdrant.ScrollPoints{
+			CollectionName: sourceName,
+			Offset:         offset,
+			Limit:          &limit,
+			WithPayload:    qdrant.NewWithPayload(true),
+			WithVectors:    qdrant.NewWithVectors(!reembed),
+		})
+		if err != nil {
+			return copied, skipped, fmt.Errorf("failed to scroll %s: %w", sourceName, err)
+		}
+
+		points, batchSkipped, err := convertBatch(ctx, embedder, resp.GetResult(), spec, reembed)
+		if err != nil {
+			return copied, skipped, err
+		}
+		skipped += batchSkipped
+
+		if len(points) > 0 {
+			_, err = client.Upsert(ctx, &qdrant.UpsertPoints{
+				CollectionName: targetName,
+				Wait:           qdrant.PtrOf(true),
+				Points:         points,
+			})
+			if err != nil {
+				return copied, skipped, fmt.Errorf("failed to write batch to %s: %w", targetName, err)
+			}
+			copied += len(points)
+			log.Printf("Migrated %d points so far", copied)
+		}
+
+		offset = resp.GetNextPageOffset()
+		if offset == nil {
+			return copied, skipped, nil
+		}
+	}
+}
+
+func convertBatch(ctx context.Context, embedder embedding.Embedder, batch []*qdrant.RetrievedPoint, spec Spec,
+	reembed bool) ([]*qdrant.PointStruct, int, error) {
+
+	points := make([]*qdrant.PointStruct, 0, len(batch))
+	var texts []string
+	skipped := 0
+
+	for _, point := range batch {
+		fields := point.GetPayload()
+		if spec.Remap != nil {
+			remapped, err := spec.Remap(fields)
+			if err != nil {
+				return nil, 0, fmt.Errorf("failed to remap point %s: %w", pointIDString(point.GetId()), err)
+			}
+			fields = remapped
+		}
+
+		converted := &qdrant.PointStruct{Id: point.GetId(), Payload: fields}
+		if reembed {
+			text := spec.EmbedText(fields)
+			if strings.TrimSpace(text) == "" {
+				log.Printf("Skipping point %s: nothing to embed", pointIDString(point.GetId()))
+				skipped++
+				continue
+			}
+			texts = append(texts, text)
+		} else {
+			converted.Vectors = qdrant.NewVectorsDense(point.GetVectors().GetVector().GetData())
+		}
+		points = append(points, converted)
+	}
+
+	if reembed && len(texts) > 0 {
+		vectors, err := embedder.EmbedBatch(ctx, texts)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to re-embed batch: %w", err)
+		}
+		if len(vectors) != len(points) {
+			return nil, 0, fmt.Errorf("re-embedding %d points returned %d vectors", len(points), len(vectors))
+		}
+		for i := range points {
+			points[i].Vectors = qdrant.NewVectorsDense(vectors[i])
+		}
+	}
+
+	return points, skipped, nil
+}
+
+func pointIDString(id *qdrant.PointId) string {
+	if uuid := id.GetUuid(); uuid != "" {
+		return uuid
+	}
+	return fmt.Sprint(id.GetNum())
+}
@@ -0,0 +1,213 @@
+package migrate
+
+import (
+	"RAGScholar/embedding"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+func testSpec() Spec {
+	return Spec{
+		Alias:          "papers",
+		SchemaVersion:  3,
+		EmbeddingModel: "models/embedding-001",
+		VectorSize:     768,
+		Distance:       "cosine",
+	}
+}
+
+func TestOutdated(t *testing.T) {
+	spec := testSpec()
+	current := target{collection: "papers_v3_20240101000000", isAlias: true, exists: true}
+	upToDate := metadataFor(spec, current.collection)
+
+	tests := []struct {
+		name    string
+		meta    *Metadata
+		current target
+		want    string
+	}{
+		{"up to date", &upToDate, current, ""},
+		{"plain collection", &upToDate, target{collection: "papers", exists: true}, "not behind an alias"},
+		{"no metadata", nil, current, "no schema version recorded"},
+		{"metadata of another collection", &Metadata{Collection: "papers_v2_20230101000000"}, current, "metadata describes papers_v2_20230101000000"},
+		{"older schema", with(upToDate, func(m *Metadata) { m.SchemaVersion = 2 }), current, "schema version 2, want 3"},
+		{
+			"other embedding model",
+			with(upToDate, func(m *Metadata) { m.EmbeddingModel = "local" }),
+			current,
+			"embedded with local (768 dimensions), want models/embedding-001 (768 dimensions)",
+		},
+		{
+			"other dimension",
+			with(upToDate, func(m *Metadata) { m.VectorSize = 256 }),
+			current,
+			"embedded with models/embedding-001 (256 dimensions), want models/embedding-001 (768 dimensions)",
+		},
+		{"other distance", with(upToDate, func(m *Metadata) { m.Distance = "dot" }), current, "dot distance, want cosine"},
+	}
+	for _, test := range tests {
+		if got := outdated(test.meta, test.current, spec); got != test.want {
+			t.Errorf("%s: outdated = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func with(meta Metadata, change func(*Metadata)) *Metadata {
+	change(&meta)
+	return &meta
+}
+
+func TestVersionedName(t *testing.T) {
+	now := time.Date(2024, 3, 5, 7, 8, 9, 0, time.FixedZone("CET", 3600))
+	if got, want := versionedName(testSpec(), now), "papers_v3_20240305060809"; got != want {
+		t.Errorf("versionedName = %q, want %q in UTC", got, want)
+	}
+
+	// Migrations a second apart don't collide
+	if versionedName(testSpec(), now) == versionedName(testSpec(), now.Add(time.Second)) {
+		t.Error("versionedName repeats a second later")
+	}
+}
+
+func TestParseDistance(t *testing.T) {
+	for name, want := range map[string]qdrant.Distance{
+		"cosine":    qdrant.Distance_Cosine,
+		"dot":       qdrant.Distance_Dot,
+		"euclid":    qdrant.Distance_Euclid,
+		"manhattan": qdrant.Distance_Manhattan,
+	} {
+		if got, err := ParseDistance(name); err != nil || got != want {
+			t.Errorf("ParseDistance(%q) = %v, %v, want %v", name, got, err, want)
+		}
+	}
+	for _, name := range []string{"", "Cosine", "l2"} {
+		if _, err := ParseDistance(name); err == nil {
+			t.Errorf("ParseDistance(%q) succeeded", name)
+		}
+	}
+}
+
+func storedPoint(id uint64, text string, vector []float32) *qdrant.RetrievedPoint {
+	return &qdrant.RetrievedPoint{
+		Id:      qdrant.NewIDNum(id),
+		Payload: qdrant.NewValueMap(map[string]any{"text": text}),
+		Vectors: &qdrant.VectorsOutput{
+			VectorsOptions: &qdrant.VectorsOutput_Vector{Vector: &qdrant.VectorOutput{Data: vector}},
+		},
+	}
+}
+
+func textSpec() Spec {
+	spec := testSpec()
+	spec.EmbedText = func(fields map[string]*qdrant.Value) string {
+		return fields["text"].GetStringValue()
+	}
+	return spec
+}
+
+func TestConvertBatchKeepsVectors(t *testing.T) {
+	batch := []*qdrant.RetrievedPoint{
+		storedPoint(1, "graph neural networks", []float32{1, 0}),
+		storedPoint(2, "", []float32{0, 1}),
+	}
+	spec := textSpec()
+	spec.Remap = func(fields map[string]*qdrant.Value) (map[string]*qdrant.Value, error) {
+		fields["schemaVersion"] = qdrant.NewValueInt(3)
+		return fields, nil
+	}
+
+	points, skipped, err := convertBatch(context.Background(), nil, batch, spec, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without re-embedding there is nothing to skip
+	if skipped != 0 || len(points) != 2 {
+		t.Fatalf("converted %d points and skipped %d, want 2 and 0", len(points), skipped)
+	}
+	for i, point := range points {
+		if point.GetId().GetNum() != batch[i].GetId().GetNum() {
+			t.Errorf("point %d has ID %v, want %v", i, point.GetId(), batch[i].GetId())
+		}
+		if got, want := point.GetVectors().GetVector().GetData(), batch[i].GetVectors().GetVector().GetData(); !slices.Equal(got, want) {
+			t.Errorf("point %d has vector %v, want the stored %v", i, got, want)
+		}
+		if point.GetPayload()["schemaVersion"].GetIntegerValue() != 3 {
+			t.Errorf("point %d payload %v wasn't remapped", i, point.GetPayload())
+		}
+	}
+}
+
+func TestConvertBatchReembedsAndSkipsEmptyText(t *testing.T) {
+	embedder := embedding.NewLocal(16)
+	ctx := context.Background()
+	batch := []*qdrant.RetrievedPoint{
+		storedPoint(1, "graph neural networks", []float32{1, 0}),
+		storedPoint(2, "  ", []float32{0, 1}),
+		storedPoint(3, "string theory", []float32{1, 1}),
+		storedPoint(4, "", []float32{0, 0}),
+		storedPoint(5, "protein folding", []float32{1, 0}),
+	}
+
+	points, skipped, err := convertBatch(ctx, embedder, batch, textSpec(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skipped != 2 {
+		t.Errorf("skipped %d points, want the 2 without text", skipped)
+	}
+
+	// Each vector must belong to its own point after the skips
+	want := map[uint64]string{1: "graph neural networks", 3: "string theory", 5: "protein folding"}
+	if len(points) != len(want) {
+		t.Fatalf("converted %d points, want %d", len(points), len(want))
+	}
+	for _, point := range points {
+		text, ok := want[point.GetId().GetNum()]
+		if !ok {
+			t.Errorf("converted point %v, which has no text", point.GetId())
+			continue
+		}
+		vector, err := embedder.Embed(ctx, text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := point.GetVectors().GetVector().GetData(); !slices.Equal(got, vector) {
+			t.Errorf("point %v has the vector of another text", point.GetId())
+		}
+	}
+}
+
+func TestConvertBatchErrors(t *testing.T) {
+	ctx := context.Background()
+	batch := []*qdrant.RetrievedPoint{storedPoint(7, "graph neural networks", []float32{1, 0})}
+
+	spec := textSpec()
+	spec.Remap = func(map[string]*qdrant.Value) (map[string]*qdrant.Value, error) {
+		return nil, errors.New("missing id")
+	}
+	if _, _, err := convertBatch(ctx, nil, batch, spec, false); err == nil || !strings.Contains(err.Error(), "point 7") {
+		t.Errorf("remap error = %v, want it to name point 7", err)
+	}
+
+	short := stubEmbedder{Embedder: embedding.NewLocal(4), vectors: 0}
+	if _, _, err := convertBatch(ctx, short, batch, textSpec(), true); err == nil {
+		t.Error("an embedder returning too few vectors went unnoticed")
+	}
+}
+
+// stubEmbedder returns a fixed number of vectors from every batch
+type stubEmbedder struct {
+	embedding.Embedder
+	vectors int
+}
+
+func (e stubEmbedder) EmbedBatch(context.Context, []string) ([][]float32, error) {
+	return make([][]float32, e.vectors), nil
+}
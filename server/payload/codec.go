@@ -0,0 +1,259 @@
+package payload
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Marshal encodes a struct as a Qdrant payload. Fields are named by their
+// `payload` tag, falling back to the json tag and then the field name; a
+// "-" name skips the field and ",omitempty" drops zero values. Strings,
+// bools, integers, floats, slices, maps with string keys, nested structs and
+// pointers to those are supported. Nil slices, maps and pointers are stored
+// as null so they decode back to nil.
+func Marshal(v any) (map[string]*qdrant.Value, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, fmt.Errorf("payload: cannot marshal nil %s", rv.Type())
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("payload: cannot marshal %s, want a struct", rv.Type())
+	}
+	return marshalStruct(rv)
+}
+
+// Unmarshal decodes a payload into the struct target points to, using the
+// same field names as Marshal. Keys without a matching field are ignored,
+// so payloads may carry extra derived fields.
+func Unmarshal(fields map[string]*qdrant.Value, target any) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("payload: cannot unmarshal into %T, want a non-nil struct pointer", target)
+	}
+	return unmarshalStruct(fields, rv.Elem())
+}
+
+type fieldInfo struct {
+	index     int
+	name      string
+	omitEmpty bool
+}
+
+func structFields(t reflect.Type) []fieldInfo {
+	var fields []fieldInfo
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag, ok := field.Tag.Lookup("payload")
+		if !ok {
+			tag = field.Tag.Get("json")
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "-" && options == "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		fields = append(fields, fieldInfo{
+			index:     i,
+			name:      name,
+			omitEmpty: strings.Contains(","+options+",", ",omitempty,"),
+		})
+	}
+	return fields
+}
+
+func marshalStruct(rv reflect.Value) (map[string]*qdrant.Value, error) {
+	fields := make(map[string]*qdrant.Value)
+	for _, info := range structFields(rv.Type()) {
+		fv := rv.Field(info.index)
+		if info.omitEmpty && fv.IsZero() {
+			continue
+		}
+		value, err := marshalValue(fv)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", info.name, err)
+		}
+		fields[info.name] = value
+	}
+	return fields, nil
+}
+
+func marshalValue(rv reflect.Value) (*qdrant.Value, error) {
+	switch rv.Kind() {
+	case reflect.String:
+		return qdrant.NewValueString(rv.String()), nil
+	case reflect.Bool:
+		return qdrant.NewValueBool(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return qdrant.NewValueInt(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		// Qdrant integers are signed
+		if rv.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("%d overflows a payload integer", rv.Uint())
+		}
+		return qdrant.NewValueInt(int64(rv.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return qdrant.NewValueDouble(rv.Float()), nil
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return qdrant.NewValueNull(), nil
+		}
+		return marshalValue(rv.Elem())
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return qdrant.NewValueNull(), nil
+		}
+		values := make([]*qdrant.Value, rv.Len())
+		for i := range values {
+			value, err := marshalValue(rv.Index(i))
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			values[i] = value
+		}
+		return qdrant.NewValueList(&qdrant.ListValue{Values: values}), nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %s", rv.Type().Key())
+		}
+		if rv.IsNil() {
+			return qdrant.NewValueNull(), nil
+		}
+		fields := make(map[string]*qdrant.Value, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			value, err := marshalValue(iter.Value())
+			if err != nil {
+				return nil, fmt.Errorf("[%s]: %w", iter.Key().String(), err)
+			}
+			fields[iter.Key().String()] = value
+		}
+		return qdrant.NewValueStruct(&qdrant.Struct{Fields: fields}), nil
+	case reflect.Struct:
+		fields, err := marshalStruct(rv)
+		if err != nil {
+			return nil, err
+		}
+		return qdrant.NewValueStruct(&qdrant.Struct{Fields: fields}), nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", rv.Type())
+}
+
+func unmarshalStruct(fields map[string]*qdrant.Value, rv reflect.Value) error {
+	for _, info := range structFields(rv.Type()) {
+		value, ok := fields[info.name]
+		if !ok {
+			continue
+		}
+		if err := unmarshalValue(value, rv.Field(info.index)); err != nil {
+			return fmt.Errorf("%s: %w", info.name, err)
+		}
+	}
+	return nil
+}
+
+func unmarshalValue(value *qdrant.Value, rv reflect.Value) error {
+	if _, isNull := value.GetKind().(*qdrant.Value_NullValue); isNull || value.GetKind() == nil {
+		rv.SetZero()
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.String:
+		s, ok := value.GetKind().(*qdrant.Value_StringValue)
+		if !ok {
+			return mismatch(value, rv)
+		}
+		rv.SetString(s.StringValue)
+	case reflect.Bool:
+		b, ok := value.GetKind().(*qdrant.Value_BoolValue)
+		if !ok {
+			return mismatch(value, rv)
+		}
+		rv.SetBool(b.BoolValue)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := value.GetKind().(*qdrant.Value_IntegerValue)
+		if !ok || rv.OverflowInt(n.IntegerValue) {
+			return mismatch(value, rv)
+		}
+		rv.SetInt(n.IntegerValue)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := value.GetKind().(*qdrant.Value_IntegerValue)
+		if !ok || n.IntegerValue < 0 || rv.OverflowUint(uint64(n.IntegerValue)) {
+			return mismatch(value, rv)
+		}
+		rv.SetUint(uint64(n.IntegerValue))
+	case reflect.Float32, reflect.Float64:
+		// Whole numbers may come back from Qdrant as integers
+		switch n := value.GetKind().(type) {
+		case *qdrant.Value_DoubleValue:
+			rv.SetFloat(n.DoubleValue)
+		case *qdrant.Value_IntegerValue:
+			rv.SetFloat(float64(n.IntegerValue))
+		default:
+			return mismatch(value, rv)
+		}
+	case reflect.Pointer:
+		elem := reflect.New(rv.Type().Elem())
+		if err := unmarshalValue(value, elem.Elem()); err != nil {
+			return err
+		}
+		rv.Set(elem)
+	case reflect.Slice, reflect.Array:
+		list, ok := value.GetKind().(*qdrant.Value_ListValue)
+		if !ok {
+			return mismatch(value, rv)
+		}
+		items := list.ListValue.GetValues()
+		if rv.Kind() == reflect.Slice {
+			rv.Set(reflect.MakeSlice(rv.Type(), len(items), len(items)))
+		} else if len(items) != rv.Len() {
+			return fmt.Errorf("cannot decode %d items into %s", len(items), rv.Type())
+		}
+		for i, item := range items {
+			if err := unmarshalValue(item, rv.Index(i)); err != nil {
+				return fmt.Errorf("[%d]: %w", i, err)
+			}
+		}
+	case reflect.Map:
+		s, ok := value.GetKind().(*qdrant.Value_StructValue)
+		if !ok || rv.Type().Key().Kind() != reflect.String {
+			return mismatch(value, rv)
+		}
+		m := reflect.MakeMapWithSize(rv.Type(), len(s.StructValue.GetFields()))
+		for key, item := range s.StructValue.GetFields() {
+			elem := reflect.New(rv.Type().Elem()).Elem()
+			if err := unmarshalValue(item, elem); err != nil {
+				return fmt.Errorf("[%s]: %w", key, err)
+			}
+			m.SetMapIndex(reflect.ValueOf(key).Convert(rv.Type().Key()), elem)
+		}
+		rv.Set(m)
+	case reflect.Struct:
+		s, ok := value.GetKind().(*qdrant.Value_StructValue)
+		if !ok {
+			return mismatch(value, rv)
+		}
+		return unmarshalStruct(s.StructValue.GetFields(), rv)
+	default:
+		return fmt.Errorf("unsupported type %s", rv.Type())
+	}
+	return nil
+}
+
+func mismatch(value *qdrant.Value, rv reflect.Value) error {
+	return fmt.Errorf("cannot decode %T into %s", value.GetKind(), rv.Type())
+}
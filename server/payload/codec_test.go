@@ -0,0 +1,128 @@
+package payload
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/protobuf/proto"
+)
+
+type inner struct {
+	Label string
+	Count int
+}
+
+// record covers every kind of field the codec supports
+type record struct {
+	Text     string            `json:"text"`
+	Renamed  string            `json:"ignored" payload:"renamed"`
+	Optional string            `payload:"optional,omitempty"`
+	Skipped  string            `payload:"-"`
+	Flag     bool              `payload:"flag"`
+	Small    int8              `payload:"small"`
+	Large    int64             `payload:"large"`
+	Unsigned uint32            `payload:"unsigned"`
+	Ratio    float64           `payload:"ratio"`
+	Words    []string          `payload:"words"`
+	Counts   map[string]int    `payload:"counts"`
+	Nested   inner             `payload:"nested"`
+	Items    []inner           `payload:"items"`
+	Pointer  *inner            `payload:"pointer"`
+	Pair     [2]int16          `payload:"pair"`
+	Labels   map[string]string `payload:"labels,omitempty"`
+}
+
+// roundTrip sends a payload through the protobuf encoding it travels to
+// Qdrant in
+func roundTrip(t *testing.T, fields map[string]*qdrant.Value) map[string]*qdrant.Value {
+	t.Helper()
+	data, err := proto.Marshal(&qdrant.Struct{Fields: fields})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded qdrant.Struct
+	if err := proto.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	return decoded.GetFields()
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	property := func(want record) bool {
+		fields, err := Marshal(want)
+		if err != nil {
+			t.Logf("Marshal: %v", err)
+			return false
+		}
+		var got record
+		if err := Unmarshal(roundTrip(t, fields), &got); err != nil {
+			t.Logf("Unmarshal: %v", err)
+			return false
+		}
+
+		// Skipped fields aren't stored
+		want.Skipped = ""
+		if !reflect.DeepEqual(got, want) {
+			t.Logf("got  %+v\nwant %+v", got, want)
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMarshalFieldNames(t *testing.T) {
+	fields, err := Marshal(record{Renamed: "r", Skipped: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"text", "renamed", "words", "pointer"} {
+		if _, ok := fields[name]; !ok {
+			t.Errorf("payload has no %q field", name)
+		}
+	}
+	for _, name := range []string{"ignored", "Renamed", "optional", "Skipped", "labels"} {
+		if _, ok := fields[name]; ok {
+			t.Errorf("payload has unexpected %q field", name)
+		}
+	}
+	if _, ok := fields["words"].GetKind().(*qdrant.Value_NullValue); !ok {
+		t.Errorf("nil slice encoded as %v, want null", fields["words"])
+	}
+}
+
+func TestMarshalRejectsUnsupported(t *testing.T) {
+	tests := []any{
+		struct{ Huge uint64 }{math.MaxUint64},
+		struct{ Keys map[int]string }{map[int]string{1: "a"}},
+		struct{ Fn func() }{func() {}},
+		"not a struct",
+	}
+	for _, v := range tests {
+		if _, err := Marshal(v); err == nil {
+			t.Errorf("Marshal(%T) succeeded, want an error", v)
+		}
+	}
+}
+
+func TestUnmarshalTypeMismatch(t *testing.T) {
+	var target record
+	fields := map[string]*qdrant.Value{"small": qdrant.NewValueInt(1000)}
+	if err := Unmarshal(fields, &target); err == nil {
+		t.Error("decoding 1000 into int8 succeeded, want an overflow error")
+	}
+	fields = map[string]*qdrant.Value{"text": qdrant.NewValueInt(1)}
+	if err := Unmarshal(fields, &target); err == nil {
+		t.Error("decoding an integer into a string succeeded, want an error")
+	}
+	// Whole doubles may come back from Qdrant as integers
+	fields = map[string]*qdrant.Value{"ratio": qdrant.NewValueInt(3)}
+	if err := Unmarshal(fields, &target); err != nil || target.Ratio != 3 {
+		t.Errorf("decoding an integer ratio = %v, %v; want 3", target.Ratio, err)
+	}
+}
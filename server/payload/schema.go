@@ -0,0 +1,124 @@
+// Package payload describes how papers and their full-text chunks are laid
+// out in Qdrant payloads, and the payload indexes that layout relies on.
+package payload
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// SchemaVersion is stored in every paper payload as schemaVersion. Bump it
+// whenever the payload layout changes so older points can be found and
+// migrated.
+//
+// Version 2 adds the integer publishedAt/updatedAt timestamps, the flat
+// authorNames list and schemaVersion itself; points without schemaVersion
+// are version 1.
+//
+// Version 3 adds arxivNumber, the last path segment of the arXiv ID, which
+// is how the client links to old-style papers.
+const SchemaVersion = 3
+
+// ChunkSchemaVersion is the payload layout version of full-text chunks
+const ChunkSchemaVersion = 1
+
+// Paper payload fields that are filtered on or indexed
+const (
+	FieldID                  = "id"
+	FieldArxivID             = "arxivId"
+	FieldArxivNumber         = "arxivNumber"
+	FieldVersion             = "version"
+	FieldSchemaVersion       = "schemaVersion"
+	FieldPublished           = "published"
+	FieldPublishedAt         = "publishedAt"
+	FieldPublishedYear       = "publishedYear"
+	FieldUpdated             = "updated"
+	FieldUpdatedAt           = "updatedAt"
+	FieldDOI                 = "doi"
+	FieldPrimaryCategory     = "primaryCategory"
+	FieldPrimaryCategoryName = "primaryCategoryName"
+	FieldCategories          = "categories"
+	FieldCategoryNames       = "categoryNames"
+	FieldArchives            = "archives"
+	FieldGroups              = "groups"
+	FieldAuthorNames         = "authorNames"
+	FieldAuthorNameText      = "authors[].name"
+)
+
+// Chunk payload fields. Chunks are filtered and grouped on their paper's ID
+// and point ID; the rest are read back for answers and the BM25 index.
+const (
+	FieldChunkPaperID      = "paperId"
+	FieldChunkPaperPointID = "paperPointId"
+	FieldChunkSourceID     = "sourceId"
+	FieldChunkTitle        = "title"
+	FieldChunkSection      = "section"
+	FieldChunkIndex        = "chunkIndex"
+	FieldChunkText         = "text"
+)
+
+// Index is a payload index Qdrant keeps on one field
+type Index struct {
+	Field  string
+	Type   qdrant.FieldType
+	Params *qdrant.PayloadIndexParams
+}
+
+// PaperIndexes lists the payload indexes of the papers collection. Exact
+// lookups use keyword indexes; author names also get a full-text index on
+// the nested field so partial names match.
+var PaperIndexes = []Index{
+	{Field: FieldID, Type: qdrant.FieldType_FieldTypeKeyword},
+	{Field: FieldArxivID, Type: qdrant.FieldType_FieldTypeKeyword},
+	{Field: FieldArxivNumber, Type: qdrant.FieldType_FieldTypeKeyword},
+	{Field: FieldSchemaVersion, Type: qdrant.FieldType_FieldTypeInteger},
+	{Field: FieldDOI, Type: qdrant.FieldType_FieldTypeKeyword},
+	{Field: FieldPrimaryCategory, Type: qdrant.FieldType_FieldTypeKeyword},
+	{Field: FieldPrimaryCategoryName, Type: qdrant.FieldType_FieldTypeKeyword},
+	{Field: FieldCategories, Type: qdrant.FieldType_FieldTypeKeyword},
+	{Field: FieldCategoryNames, Type: qdrant.FieldType_FieldTypeKeyword},
+	{Field: FieldArchives, Type: qdrant.FieldType_FieldTypeKeyword},
+	{Field: FieldGroups, Type: qdrant.FieldType_FieldTypeKeyword},
+	{Field: FieldAuthorNames, Type: qdrant.FieldType_FieldTypeKeyword},
+	{Field: FieldPublished, Type: qdrant.FieldType_FieldTypeDatetime},
+	{Field: FieldPublishedAt, Type: qdrant.FieldType_FieldTypeInteger},
+	{Field: FieldPublishedYear, Type: qdrant.FieldType_FieldTypeInteger},
+	{Field: FieldUpdatedAt, Type: qdrant.FieldType_FieldTypeInteger},
+	{
+		Field: FieldAuthorNameText,
+		Type:  qdrant.FieldType_FieldTypeText,
+		Params: qdrant.NewPayloadIndexParamsText(&qdrant.TextIndexParams{
+			Tokenizer: qdrant.TokenizerType_Word,
+			Lowercase: qdrant.PtrOf(true),
+		}),
+	},
+}
+
+// ChunkIndexes lists the payload indexes of the full-text chunk collection
+var ChunkIndexes = []Index{
+	{Field: FieldChunkPaperID, Type: qdrant.FieldType_FieldTypeKeyword},
+	{Field: FieldChunkPaperPointID, Type: qdrant.FieldType_FieldTypeKeyword},
+}
+
+// EnsureIndexes creates the given payload indexes on a collection; Qdrant
+// treats re-creating an existing index as a no-op
+func EnsureIndexes(ctx context.Context, client *qdrant.Client, collectionName string, indexes []Index) error {
+	for _, index := range indexes {
+		_, err := client.CreateFieldIndex(ctx, &qdrant.CreateFieldIndexCollection{
+			CollectionName:   collectionName,
+			Wait:             qdrant.PtrOf(true),
+			FieldName:        index.Field,
+			FieldType:        qdrant.PtrOf(index.Type),
+			FieldIndexParams: index.Params,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create payload index on %s.%s: %w", collectionName, index.Field, err)
+		}
+	}
+
+	log.Printf("Payload indexes on '%s' are in place", collectionName)
+	return nil
+}
@@ -0,0 +1,180 @@
+package answer
+
+import (
+	"RAGScholar/embedding"
+	"RAGScholar/payload"
+	"RAGScholar/service/llm"
+	"RAGScholar/service/models"
+	"RAGScholar/vectorstore"
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+const (
+	DefaultTopK = 5
+	// MaxTopK bounds the sources per answer, which all go into the prompt
+	MaxTopK = 20
+)
+
+// SystemPrompt keeps the model grounded in the numbered sources it is given
+const SystemPrompt = `You are a research assistant answering questions using only the numbered sources provided.
+Cite every claim with the number of the source it comes from, in square brackets, for example [1] or [2][3].
+If the sources do not contain the answer, say so instead of guessing.
+Keep the answer focused, accurate, and concise.`
+
+var citationPattern = regexp.MustCompile(`\[(\d+)\]`)
+
+// Ask retrieves the sources closest to the question, asks the model to answer
+// from them and maps the inline citations back to papers.
+func Ask(ctx context.Context, store vectorstore.VectorStore, embedder embedding.Embedder, model llm.LLM,
+	collectionName string, chunkCollectionName string, question string, topK int) (*models.AskResponse, error) {
+
+	if strings.TrimSpace(question) == "" {
+		return nil, fmt.Errorf("cannot answer an empty question")
+	}
+	if topK <= 0 {
+		topK = DefaultTopK
+	}
+	topK = min(topK, MaxTopK)
+
+	sources, err := Retrieve(ctx, store, embedder, collectionName, chunkCollectionName, question, topK)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sources) == 0 {
+		return &models.AskResponse{
+			Answer:    "No papers in the corpus match this question yet.",
+			Citations: []models.Citation{},
+			Sources:   []models.Source{},
+		}, nil
+	}
+
+	text, err := model.Generate(ctx, SystemPrompt, BuildPrompt(question, sources))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate answer: %w", err)
+	}
+
+	return &models.AskResponse{
+		Answer:    text,
+		Citations: Citations(text, sources),
+		Sources:   sources,
+	}, nil
+}
+
+// Retrieve returns the topK best matching summaries and full-text chunks,
+// numbered from 1 in order of decreasing score.
+func Retrieve(ctx context.Context, store vectorstore.VectorStore, embedder embedding.Embedder,
+	collectionName string, chunkCollectionName string, question string, topK int) ([]models.Source, error) {
+
+	vector, err := embedder.Embed(ctx, question)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed question: %w", err)
+	}
+
+	limit := uint64(topK)
+
+	papers, err := store.Query(ctx, &qdrant.QueryPoints{
+		CollectionName: collectionName,
+		Query:          qdrant.NewQueryDense(vector),
+		Limit:          &limit,
+		WithPayload:    qdrant.NewWithPayloadInclude("id", "title", "summary"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search papers: %w", err)
+	}
+
+	chunks, err := store.Query(ctx, &qdrant.QueryPoints{
+		CollectionName: chunkCollectionName,
+		Query:          qdrant.NewQueryDense(vector),
+		Limit:          &limit,
+		WithPayload:    qdrant.NewWithPayloadInclude(payload.FieldChunkSourceID, payload.FieldChunkTitle, payload.FieldChunkSection, payload.FieldChunkText),
+	})
+	if err != nil {
+		log.Printf("Full-text chunk search failed, using summaries only: %v", err)
+	}
+
+	var sources []models.Source
+	for _, point := range papers {
+		sources = append(sources, models.Source{
+			PaperID: point.Payload["id"].GetStringValue(),
+			Title:   point.Payload["title"].GetStringValue(),
+			Section: "Abstract",
+			Text:    point.Payload["summary"].GetStringValue(),
+			Score:   point.Score,
+		})
+	}
+	for _, point := range chunks {
+		sources = append(sources, models.Source{
+			PaperID: point.Payload[payload.FieldChunkSourceID].GetStringValue(),
+			Title:   point.Payload[payload.FieldChunkTitle].GetStringValue(),
+			Section: point.Payload[payload.FieldChunkSection].GetStringValue(),
+			Text:    point.Payload[payload.FieldChunkText].GetStringValue(),
+			Score:   point.Score,
+		})
+	}
+
+	sort.SliceStable(sources, func(i, j int) bool {
+		return sources[i].Score > sources[j].Score
+	})
+	if len(sources) > topK {
+		sources = sources[:topK]
+	}
+
+	for i := range sources {
+		sources[i].Number = i + 1
+	}
+
+	return sources, nil
+}
+
+// BuildPrompt lays out the numbered sources followed by the question
+func BuildPrompt(question string, sources []models.Source) string {
+	var sb strings.Builder
+	sb.WriteString("Sources:\n\n")
+	for _, source := range sources {
+		fmt.Fprintf(&sb, "[%d] %s (%s)", source.Number, source.Title, source.PaperID)
+		if source.Section != "" {
+			fmt.Fprintf(&sb, ", section: %s", source.Section)
+		}
+		fmt.Fprintf(&sb, "\n%s\n\n", strings.TrimSpace(source.Text))
+	}
+	fmt.Fprintf(&sb, "Question: %s\n\nAnswer using the sources above and cite them by number.", question)
+	return sb.String()
+}
+
+// Citations maps the [n] markers in an answer back to their sources, in order
+// of first appearance. Numbers that match no source are ignored.
+func Citations(text string, sources []models.Source) []models.Citation {
+	byNumber := make(map[int]models.Source, len(sources))
+	for _, source := range sources {
+		byNumber[source.Number] = source
+	}
+
+	citations := []models.Citation{}
+	seen := make(map[int]bool)
+	for _, match := range citationPattern.FindAllStringSubmatch(text, -1) {
+		number, err := strconv.Atoi(match[1])
+		if err != nil || seen[number] {
+			continue
+		}
+		source, ok := byNumber[number]
+		if !ok {
+			continue
+		}
+		seen[number] = true
+		citations = append(citations, models.Citation{
+			Number:  number,
+			PaperID: source.PaperID,
+			Title:   source.Title,
+		})
+	}
+	return citations
+}
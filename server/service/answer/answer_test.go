@@ -0,0 +1,168 @@
+package answer
+
+import (
+	"RAGScholar/domain"
+	"RAGScholar/embedding"
+	"RAGScholar/service/llm"
+	"RAGScholar/service/models"
+	"RAGScholar/vectorstore"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+const (
+	papers = "papers"
+	chunks = "paper_chunks"
+)
+
+// newStore stores one paper per summary, with IDs 2401.0000<n>v1
+func newStore(t *testing.T, embedder embedding.Embedder, summaries ...string) *vectorstore.Memory {
+	t.Helper()
+	ctx := context.Background()
+	store := vectorstore.NewMemory()
+
+	for i, summary := range summaries {
+		entry := domain.Entry{
+			ID:      fmt.Sprintf("http://arxiv.org/abs/2401.%05dv1", i+1),
+			Title:   fmt.Sprintf("Paper %d", i+1),
+			Summary: summary,
+		}
+		fields, err := domain.EncodePayload(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vector, err := embedder.Embed(ctx, summary)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = store.Upsert(ctx, &qdrant.UpsertPoints{
+			CollectionName: papers,
+			Points: []*qdrant.PointStruct{{
+				Id:      qdrant.NewIDNum(uint64(i + 1)),
+				Vectors: qdrant.NewVectorsDense(vector),
+				Payload: fields,
+			}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+// recordingLLM remembers the prompt it was given and answers with Fake
+type recordingLLM struct {
+	prompt string
+}
+
+func (r *recordingLLM) Generate(ctx context.Context, systemPrompt string, prompt string) (string, error) {
+	r.prompt = prompt
+	return llm.Fake{}.Generate(ctx, systemPrompt, prompt)
+}
+
+type failingLLM struct{}
+
+func (failingLLM) Generate(ctx context.Context, systemPrompt string, prompt string) (string, error) {
+	return "", errors.New("model should not be called")
+}
+
+func TestAskNumbersSourcesAndMapsCitationsToPapers(t *testing.T) {
+	embedder := embedding.NewLocal(64)
+	store := newStore(t, embedder,
+		"graph neural networks for molecules",
+		"dense retrieval of scientific papers",
+		"retrieval augmented generation with citations",
+	)
+	model := &recordingLLM{}
+
+	response, err := Ask(context.Background(), store, embedder, model, papers, chunks, "retrieval of papers with citations", 3)
+	if err != nil {
+		t.Fatalf("Ask: %v", err)
+	}
+
+	if len(response.Sources) != 3 {
+		t.Fatalf("got %d sources, want 3", len(response.Sources))
+	}
+	for i, source := range response.Sources {
+		if source.Number != i+1 {
+			t.Errorf("source %d is numbered %d", i, source.Number)
+		}
+		if i > 0 && source.Score > response.Sources[i-1].Score {
+			t.Errorf("source %d scores higher than source %d", i+1, i)
+		}
+		header := fmt.Sprintf("[%d] %s (%s)", source.Number, source.Title, source.PaperID)
+		if !strings.Contains(model.prompt, header) {
+			t.Errorf("prompt is missing %q:\n%s", header, model.prompt)
+		}
+	}
+
+	if len(response.Citations) != 3 {
+		t.Fatalf("got %d citations, want 3: %+v", len(response.Citations), response.Citations)
+	}
+	for _, citation := range response.Citations {
+		source := response.Sources[citation.Number-1]
+		if citation.PaperID != source.PaperID || citation.Title != source.Title {
+			t.Errorf("citation %+v doesn't match source %+v", citation, source)
+		}
+		if !strings.HasPrefix(citation.PaperID, "http://arxiv.org/abs/2401.") {
+			t.Errorf("citation %d maps to %q, not a stored paper ID", citation.Number, citation.PaperID)
+		}
+	}
+}
+
+func TestAskWithoutMatchingPapers(t *testing.T) {
+	embedder := embedding.NewLocal(64)
+	store := vectorstore.NewMemory()
+
+	response, err := Ask(context.Background(), store, embedder, failingLLM{}, papers, chunks, "anything at all", 0)
+	if err != nil {
+		t.Fatalf("Ask: %v", err)
+	}
+	if response.Answer == "" || len(response.Citations) != 0 || len(response.Sources) != 0 {
+		t.Errorf("got %+v, want an answer with no citations or sources", response)
+	}
+}
+
+func TestAskCapsTopK(t *testing.T) {
+	embedder := embedding.NewLocal(64)
+	summaries := make([]string, MaxTopK+5)
+	for i := range summaries {
+		summaries[i] = fmt.Sprintf("paper number %d about retrieval", i)
+	}
+	store := newStore(t, embedder, summaries...)
+
+	response, err := Ask(context.Background(), store, embedder, llm.Fake{}, papers, chunks, "retrieval", 1000)
+	if err != nil {
+		t.Fatalf("Ask: %v", err)
+	}
+	if len(response.Sources) != MaxTopK {
+		t.Errorf("got %d sources, want %d", len(response.Sources), MaxTopK)
+	}
+}
+
+func TestCitations(t *testing.T) {
+	sources := []models.Source{
+		{Number: 1, PaperID: "http://arxiv.org/abs/2401.00001v1", Title: "One"},
+		{Number: 2, PaperID: "http://arxiv.org/abs/2401.00002v1", Title: "Two"},
+	}
+
+	got := Citations("Claims [2], more claims [1][2], and a made up one [7].", sources)
+
+	want := []models.Citation{
+		{Number: 2, PaperID: "http://arxiv.org/abs/2401.00002v1", Title: "Two"},
+		{Number: 1, PaperID: "http://arxiv.org/abs/2401.00001v1", Title: "One"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Citations = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("citation %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package explanation
 
 import (
+	"RAGScholar/gemini"
 	"context"
 	"fmt"
 	"log"
@@ -18,21 +19,30 @@ Provide a clear, concise explanation that:
 
 Keep your explanation focused, accurate, and helpful for someone trying to understand this research.`
 
-func ExplainText(ctx context.Context, client *genai.Client, selectedText string, paperContext string) (string, error) {
-	model := client.GenerativeModel("gemini-1.5-pro")
+// buildPrompt returns the system prompt and prompt for explaining
+// selectedText. A custom prompt replaces both the default system prompt and
+// the closing request to explain the text.
+func buildPrompt(selectedText string, paperContext string, customPrompt string) (string, string) {
+	prompt := fmt.Sprintf("The following text is from a research paper titled '%s':\n\n%s", paperContext, selectedText)
+	if customPrompt != "" {
+		return customPrompt, prompt
+	}
+	return SystemPrompt, prompt + "\n\nPlease explain this text."
+}
+
+func ExplainText(ctx context.Context, client *gemini.Client, modelName string, selectedText string, paperContext string) (string, error) {
+	model := client.GenerativeModel(modelName)
 	if model == nil {
 		return "", fmt.Errorf("failed to initialize Gemini model")
 	}
 
+	systemPrompt, prompt := buildPrompt(selectedText, paperContext, "")
 	model.SystemInstruction = &genai.Content{
 		Parts: []genai.Part{
-			genai.Text(SystemPrompt),
+			genai.Text(systemPrompt),
 		},
 	}
 
-	prompt := fmt.Sprintf("The following text is from a research paper titled '%s':\n\n%s\n\nPlease explain this text.",
-		paperContext, selectedText)
-
 	resp, err := model.GenerateContent(ctx, genai.Text(prompt))
 	if err != nil {
 		log.Printf("Error generating explanation: %v", err)
@@ -51,21 +61,19 @@ func ExplainText(ctx context.Context, client *genai.Client, selectedText string,
 	return string(explanation), nil
 }
 
-func CustomExplainText(ctx context.Context, client *genai.Client, selectedText string, paperContext string, customPrompt string) (string, error) {
-	model := client.GenerativeModel("gemini-1.5-flash")
+func CustomExplainText(ctx context.Context, client *gemini.Client, modelName string, selectedText string, paperContext string, customPrompt string) (string, error) {
+	model := client.GenerativeModel(modelName)
 	if model == nil {
 		return "", fmt.Errorf("failed to initialize Gemini model")
 	}
 
+	systemPrompt, prompt := buildPrompt(selectedText, paperContext, customPrompt)
 	model.SystemInstruction = &genai.Content{
 		Parts: []genai.Part{
-			genai.Text(customPrompt),
+			genai.Text(systemPrompt),
 		},
 	}
 
-	prompt := fmt.Sprintf("The following text is from a research paper titled '%s':\n\n%s",
-		paperContext, selectedText)
-
 	resp, err := model.GenerateContent(ctx, genai.Text(prompt))
 	if err != nil {
 		log.Printf("Error generating explanation: %v", err)
@@ -83,3 +91,48 @@ func CustomExplainText(ctx context.Context, client *genai.Client, selectedText s
 
 	return string(explanation), nil
 }
+
+// StreamExplainText generates the same explanation as ExplainText, or
+// CustomExplainText with customModelName when customPrompt is set, but calls
+// onChunk with each piece of text as Gemini produces it. Cancelling ctx stops
+// the generation.
+func StreamExplainText(ctx context.Context, client *gemini.Client, modelName string, customModelName string, selectedText string, paperContext string, customPrompt string, onChunk func(string) error) error {
+	systemPrompt, prompt := buildPrompt(selectedText, paperContext, customPrompt)
+	if customPrompt != "" {
+		modelName = customModelName
+	}
+
+	model := client.GenerativeModel(modelName)
+	if model == nil {
+		return fmt.Errorf("failed to initialize Gemini model")
+	}
+
+	model.SystemInstruction = &genai.Content{
+		Parts: []genai.Part{
+			genai.Text(systemPrompt),
+		},
+	}
+
+	var chunkErr error
+	err := model.Stream(ctx, func(resp *genai.GenerateContentResponse) error {
+		for _, candidate := range resp.Candidates {
+			if candidate.Content == nil {
+				continue
+			}
+			for _, part := range candidate.Content.Parts {
+				text, ok := part.(genai.Text)
+				if !ok || text == "" {
+					continue
+				}
+				if chunkErr = onChunk(string(text)); chunkErr != nil {
+					return chunkErr
+				}
+			}
+		}
+		return nil
+	}, genai.Text(prompt))
+	if err != nil && err != chunkErr {
+		log.Printf("Error streaming explanation: %v", err)
+	}
+	return err
+}
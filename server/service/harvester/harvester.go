@@ -0,0 +1,406 @@
+package harvester
+
+import (
+	"RAGScholar/service/oaipmh"
+	"RAGScholar/service/structure"
+	"RAGScholar/service/worker"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	StateIdle      = "idle"
+	StateRunning   = "running"
+	StateCompleted = "completed"
+	StatePartial   = "partial" // finished, but some topics failed
+	StateFailed    = "failed"
+
+	ModeSearch      = "search"
+	ModeIncremental = "oai-pmh"
+)
+
+var ErrAlreadyRunning = errors.New("a harvest job is already running")
+
+type Config struct {
+	Topics           []string
+	StatePath        string        // JSON file holding the per-topic cursors
+	PageSize         int           // entries requested per arXiv API call
+	MaxPagesPerTopic int           // pages fetched per topic in a single run
+	Window           time.Duration // span of submission dates queried at once
+	InitialLookback  time.Duration // how far back a topic's first harvest starts
+	RequestInterval  time.Duration // minimum delay between arXiv API calls
+	MaxRetries       int
+	InitialBackoff   time.Duration
+	OAIBaseURL       string // OAI-PMH endpoint for incremental harvests
+}
+
+func DefaultConfig(topics []string) Config {
+	return Config{
+		Topics:           topics,
+		StatePath:        "harvest_state.json",
+		PageSize:         100,
+		MaxPagesPerTopic: 5,
+		Window:           7 * 24 * time.Hour,
+		InitialLookback:  30 * 24 * time.Hour,
+		RequestInterval:  3 * time.Second, // arXiv asks for one request every three seconds
+		MaxRetries:       4,
+		InitialBackoff:   5 * time.Second,
+		OAIBaseURL:       oaipmh.DefaultBaseURL,
+	}
+}
+
+// announceDelay is how long after submission a paper may still show up in
+// the arXiv API; a window is only closed once it ended longer ago than this
+const announceDelay = 4 * 24 * time.Hour
+
+// Cursor is the harvest position of one topic. Topics are harvested one
+// window of submission dates at a time, oldest first and in ascending order
+// within the window, so offsets stay small however busy the topic is. From
+// is the start of the current window and Start the offset into it.
+type Cursor struct {
+	From      time.Time `json:"from"`
+	Start     int       `json:"start"`
+	Total     int       `json:"total"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// persistedState is the layout of the state file
+type persistedState struct {
+	Cursors map[string]Cursor `json:"cursors"`
+	// Harvested maps an OAI-PMH set ("" for all sets) to the last day an
+	// incremental harvest of it completed through
+	Harvested map[string]time.Time `json:"harvested"`
+}
+
+type Status struct {
+	JobID            int               `json:"jobId"`
+	Mode             string            `json:"mode,omitempty"`
+	State            string            `json:"state"`
+	StartedAt        *time.Time        `json:"startedAt,omitempty"`
+	FinishedAt       *time.Time        `json:"finishedAt,omitempty"`
+	CurrentTopic     string            `json:"currentTopic,omitempty"`
+	PagesFetched     int               `json:"pagesFetched"`
+	EntriesPublished int               `json:"entriesPublished"`
+	Errors           []string          `json:"errors"`
+	Cursors          map[string]Cursor `json:"cursors"`
+}
+
+// Harvester walks every topic page by page, publishing each page of entries
+// and persisting its cursors so that the next run resumes where this one
+// stopped. Only one run is active at a time.
+type Harvester struct {
+	cfg     Config
+	client  *http.Client
+	publish func([]byte) error
+	limiter *rate.Limiter
+	oai     *oaipmh.Client
+
+	mu        sync.Mutex
+	status    Status
+	cursors   map[string]Cursor
+	harvested map[string]time.Time
+}
+
+func New(cfg Config, client *http.Client, publish func([]byte) error) (*Harvester, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 60 * time.Second}
+	}
+
+	// Search and OAI-PMH requests both hit export.arxiv.org, so they share
+	// one limiter
+	limiter := rate.NewLimiter(rate.Every(cfg.RequestInterval), 1)
+
+	h := &Harvester{
+		cfg:       cfg,
+		client:    client,
+		publish:   publish,
+		limiter:   limiter,
+		oai:       oaipmh.NewClient(cfg.OAIBaseURL, client, limiter),
+		status:    Status{State: StateIdle, Errors: []string{}},
+		cursors:   make(map[string]Cursor),
+		harvested: make(map[string]time.Time),
+	}
+
+	if err := h.loadState(); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// Start launches a harvest run over every topic in the background and
+// returns its initial status. The run stops early if ctx is cancelled.
+func (h *Harvester) Start(ctx context.Context) (Status, error) {
+	return h.begin(ModeSearch, func() { h.run(ctx) })
+}
+
+// begin marks a new job as running and launches it, unless one is already
+// in progress
+func (h *Harvester) begin(mode string, job func()) (Status, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.status.State == StateRunning {
+		return h.snapshot(), ErrAlreadyRunning
+	}
+
+	now := time.Now()
+	h.status = Status{
+		JobID:     h.status.JobID + 1,
+		Mode:      mode,
+		State:     StateRunning,
+		StartedAt: &now,
+		Errors:    []string{},
+	}
+
+	go job()
+
+	return h.snapshot(), nil
+}
+
+// RunEvery starts a harvest run every interval until ctx is cancelled,
+// skipping ticks while a previous run is still in progress.
+func (h *Harvester) RunEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := h.Start(ctx); err != nil && !errors.Is(err, ErrAlreadyRunning) {
+			log.Printf("Scheduled harvest failed to start: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *Harvester) Status() Status {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.snapshot()
+}
+
+// snapshot copies the status so callers can't race with the running job.
+// h.mu must be held.
+func (h *Harvester) snapshot() Status {
+	status := h.status
+	status.Errors = append([]string{}, h.status.Errors...)
+	status.Cursors = make(map[string]Cursor, len(h.cursors))
+	for topic, cursor := range h.cursors {
+		status.Cursors[topic] = cursor
+	}
+	return status
+}
+
+func (h *Harvester) run(ctx context.Context) {
+	seen := make(map[string]bool)
+	failures := 0
+
+	for _, topic := range h.cfg.Topics {
+		if seen[topic] {
+			continue
+		}
+		seen[topic] = true
+
+		if err := h.harvestTopic(ctx, topic); err != nil {
+			log.Printf("Harvest of topic %q stopped: %v", topic, err)
+			h.recordError(fmt.Sprintf("%s: %v", topic, err))
+			failures++
+			if ctx.Err() != nil {
+				break
+			}
+		}
+	}
+
+	state := StateCompleted
+	switch {
+	case ctx.Err() != nil || (failures > 0 && failures == len(seen)):
+		state = StateFailed
+	case failures > 0:
+		state = StatePartial
+	}
+	h.finish(state)
+}
+
+func (h *Harvester) finish(state string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	h.status.FinishedAt = &now
+	h.status.CurrentTopic = ""
+	h.status.State = state
+
+	log.Printf("Harvest job %d (%s) %s: %d pages, %d entries published",
+		h.status.JobID, h.status.Mode, h.status.State, h.status.PagesFetched, h.status.EntriesPublished)
+}
+
+func (h *Harvester) harvestTopic(ctx context.Context, topic string) error {
+	h.mu.Lock()
+	h.status.CurrentTopic = topic
+	h.mu.Unlock()
+
+	for page := 0; page < h.cfg.MaxPagesPerTopic; page++ {
+		h.mu.Lock()
+		cursor := h.cursors[topic]
+		h.mu.Unlock()
+
+		now := time.Now().UTC()
+		if cursor.From.IsZero() {
+			// A new topic, or a cursor saved before harvests were windowed
+			cursor = Cursor{From: now.Add(-h.cfg.InitialLookback).Truncate(24 * time.Hour)}
+		}
+		until := cursor.From.Add(h.cfg.Window)
+
+		entries, data, total, err := h.fetchWithRetry(ctx, windowQuery(topic, cursor.From, until), cursor.Start)
+		if err != nil {
+			return err
+		}
+
+		if len(entries) > 0 {
+			if err := h.publish(data); err != nil {
+				return fmt.Errorf("failed to publish page at %d: %w", cursor.Start, err)
+			}
+			log.Printf("Published %d entries for topic %q from %s at offset %d",
+				len(entries), topic, cursor.From.Format(time.DateOnly), cursor.Start)
+		}
+
+		// A short page means the window is exhausted. Windows that may still
+		// receive papers are kept and resumed at the offset reached; older
+		// ones are done, and the next window starts where they ended.
+		exhausted := len(entries) < h.cfg.PageSize
+		closed := exhausted && until.Before(now.Add(-announceDelay))
+
+		next := Cursor{From: cursor.From, Start: cursor.Start + len(entries), Total: total, UpdatedAt: now}
+		if closed {
+			next = Cursor{From: until, UpdatedAt: now}
+		}
+
+		h.mu.Lock()
+		h.cursors[topic] = next
+		h.status.PagesFetched++
+		h.status.EntriesPublished += len(entries)
+		err = h.saveState()
+		h.mu.Unlock()
+
+		if err != nil {
+			return fmt.Errorf("failed to save harvest state: %w", err)
+		}
+
+		// The topic is caught up until new papers arrive
+		if exhausted && !closed {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// windowQuery restricts an arXiv search query to papers submitted between
+// from and until
+func windowQuery(topic string, from time.Time, until time.Time) string {
+	const layout = "200601021504" // the API's GMT submittedDate format
+	return fmt.Sprintf("(%s) AND submittedDate:[%s TO %s]", topic, from.UTC().Format(layout), until.UTC().Format(layout))
+}
+
+func (h *Harvester) fetchWithRetry(ctx context.Context, query string, start int) ([]structure.SimplifiedEntry, []byte, int, error) {
+	backoff := h.cfg.InitialBackoff
+
+	for attempt := 0; ; attempt++ {
+		if err := h.limiter.Wait(ctx); err != nil {
+			return nil, nil, 0, err
+		}
+
+		entries, data, total, err := worker.FetchEntries(ctx, h.client, query, start, h.cfg.PageSize)
+		if err == nil {
+			return entries, data, total, nil
+		}
+
+		if attempt >= h.cfg.MaxRetries || !retryable(err) {
+			return nil, nil, 0, err
+		}
+
+		log.Printf("Fetching %q at %d failed (attempt %d): %v, retrying in %s", query, start, attempt+1, err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return nil, nil, 0, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func retryable(err error) bool {
+	var statusErr *worker.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+func (h *Harvester) recordError(message string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.status.Errors = append(h.status.Errors, message)
+}
+
+func (h *Harvester) loadState() error {
+	data, err := os.ReadFile(h.cfg.StatePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read harvest state: %w", err)
+	}
+
+	var state persistedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to parse harvest state %s: %w", h.cfg.StatePath, err)
+	}
+	if state.Cursors != nil {
+		h.cursors = state.Cursors
+	}
+	if state.Harvested != nil {
+		h.harvested = state.Harvested
+	}
+	return nil
+}
+
+// saveState writes the cursors and OAI-PMH watermarks through a temporary file so a crash never
+// leaves a truncated state file behind. h.mu must be held.
+func (h *Harvester) saveState() error {
+	data, err := json.MarshalIndent(persistedState{Cursors: h.cursors, Harvested: h.harvested}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(h.cfg.StatePath), ".harvest-state-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), h.cfg.StatePath)
+}
@@ -0,0 +1,173 @@
+package harvester
+
+import (
+	"RAGScholar/service/oaipmh"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// redirect sends every request to the test server, whatever host it names
+type redirect struct {
+	target *url.URL
+}
+
+func (r redirect) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = r.target.Scheme, r.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// fakeArxiv answers search queries with windowSize papers in every date
+// window, and with 400 for queries mentioning "broken"
+type fakeArxiv struct {
+	windowSize int
+
+	mu      sync.Mutex
+	queries []string
+}
+
+func (f *fakeArxiv) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("search_query")
+	f.mu.Lock()
+	f.queries = append(f.queries, query)
+	f.mu.Unlock()
+
+	if strings.Contains(query, "broken") {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	start, _ := strconv.Atoi(r.URL.Query().Get("start"))
+	maxResults, _ := strconv.Atoi(r.URL.Query().Get("max_results"))
+
+	var sb strings.Builder
+	sb.WriteString(`<feed xmlns="http://www.w3.org/2005/Atom" xmlns:opensearch="http://a9.com/-/spec/opensearch/1.1/">`)
+	fmt.Fprintf(&sb, `<opensearch:totalResults>%d</opensearch:totalResults>`, f.windowSize)
+	for i := start; i < min(start+maxResults, f.windowSize); i++ {
+		fmt.Fprintf(&sb, `<entry><id>http://arxiv.org/abs/2401.%05dv1</id><title>Paper %d</title></entry>`, i, i)
+	}
+	sb.WriteString(`</feed>`)
+	w.Write([]byte(sb.String()))
+}
+
+func newTestHarvester(t *testing.T, handler http.Handler, topics ...string) *Harvester {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	target, _ := url.Parse(server.URL)
+
+	cfg := DefaultConfig(topics)
+	cfg.StatePath = filepath.Join(t.TempDir(), "state.json")
+	cfg.PageSize = 2
+	cfg.MaxPagesPerTopic = 10
+	cfg.Window = 7 * 24 * time.Hour
+	cfg.InitialLookback = 20 * 24 * time.Hour
+	cfg.RequestInterval = 0
+	cfg.MaxRetries = 0
+
+	h, err := New(cfg, &http.Client{Transport: redirect{target}}, func(data []byte) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func runToCompletion(t *testing.T, h *Harvester) Status {
+	t.Helper()
+	if _, err := h.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for h.Status().State == StateRunning {
+		if time.Now().After(deadline) {
+			t.Fatal("harvest did not finish")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return h.Status()
+}
+
+func TestHarvestWalksDateWindowsAndResumesOpenWindow(t *testing.T) {
+	arxiv := &fakeArxiv{windowSize: 3}
+	h := newTestHarvester(t, arxiv, "cat:cs.IR")
+
+	status := runToCompletion(t, h)
+	if status.State != StateCompleted {
+		t.Fatalf("state = %s, errors %v", status.State, status.Errors)
+	}
+
+	// Three windows cover the 20 day lookback; the first two are closed after
+	// two pages each and the last, still open, is kept at the offset reached
+	if status.PagesFetched != 6 || status.EntriesPublished != 9 {
+		t.Errorf("fetched %d pages and %d entries, want 6 and 9", status.PagesFetched, status.EntriesPublished)
+	}
+	first := time.Now().UTC().Add(-20 * 24 * time.Hour).Truncate(24 * time.Hour)
+	cursor := status.Cursors["cat:cs.IR"]
+	if want := first.Add(14 * 24 * time.Hour); !cursor.From.Equal(want) || cursor.Start != 3 {
+		t.Errorf("cursor = %+v, want window from %s at offset 3", cursor, want)
+	}
+	if want := "(cat:cs.IR) AND submittedDate:[" + first.Format("200601021504") + " TO "; !strings.HasPrefix(arxiv.queries[0], want) {
+		t.Errorf("first query = %q, want prefix %q", arxiv.queries[0], want)
+	}
+
+	// The next run only checks the open window for papers past its offset
+	status = runToCompletion(t, h)
+	if status.PagesFetched != 1 || status.EntriesPublished != 0 {
+		t.Errorf("second run fetched %d pages and %d entries, want 1 and 0", status.PagesFetched, status.EntriesPublished)
+	}
+}
+
+func TestHarvestReportsTopicFailures(t *testing.T) {
+	h := newTestHarvester(t, &fakeArxiv{}, "cat:cs.IR", "broken")
+	if status := runToCompletion(t, h); status.State != StatePartial || len(status.Errors) != 1 {
+		t.Errorf("with one failing topic: state = %s, errors %v, want %s", status.State, status.Errors, StatePartial)
+	}
+
+	h = newTestHarvester(t, &fakeArxiv{}, "broken", "also broken")
+	if status := runToCompletion(t, h); status.State != StateFailed {
+		t.Errorf("with every topic failing: state = %s, want %s", status.State, StateFailed)
+	}
+}
+
+func TestIncrementalBackfillKeepsWatermark(t *testing.T) {
+	oai := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<OAI-PMH xmlns="http://www.openarchives.org/OAI/2.0/"><error code="noRecordsMatch">empty</error></OAI-PMH>`))
+	}))
+	defer oai.Close()
+
+	cfg := DefaultConfig(nil)
+	cfg.StatePath = filepath.Join(t.TempDir(), "state.json")
+	cfg.RequestInterval = 0
+	cfg.OAIBaseURL = oai.URL
+	h, err := New(cfg, nil, func([]byte) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	harvest := func(from, until time.Time) {
+		t.Helper()
+		if _, err := h.StartIncremental(context.Background(), oaipmh.Params{Set: "cs", From: from, Until: until}); err != nil {
+			t.Fatal(err)
+		}
+		for h.Status().State == StateRunning {
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	latest := time.Date(2025, 4, 10, 0, 0, 0, 0, time.UTC)
+	harvest(latest.AddDate(0, 0, -1), latest)
+	harvest(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC))
+
+	if got := h.harvested["cs"]; !got.Equal(latest) {
+		t.Errorf("watermark = %s after a backfill, want %s", got, latest)
+	}
+}
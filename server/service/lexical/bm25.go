@@ -0,0 +1,165 @@
+package lexical
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	k1 = 1.2
+	b  = 0.75
+
+	// titleBoost repeats title terms so a title match outweighs the same
+	// term in a long summary
+	titleBoost = 3
+)
+
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true,
+	"by": true, "for": true, "from": true, "in": true, "is": true, "it": true, "of": true,
+	"on": true, "or": true, "that": true, "the": true, "this": true, "to": true, "we": true,
+	"with": true, "which": true, "our": true, "these": true, "can": true, "has": true,
+}
+
+// Tokenize lowercases text and splits it into terms, dropping stopwords and
+// single characters
+func Tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	terms := fields[:0]
+	for _, field := range fields {
+		if len(field) > 1 && !stopwords[field] {
+			terms = append(terms, field)
+		}
+	}
+	return terms
+}
+
+type posting struct {
+	doc int
+	tf  int
+}
+
+// Index is an immutable BM25 index over papers, keyed by Qdrant point ID
+type Index struct {
+	ids      []string
+	lengths  []int
+	avgLen   float64
+	postings map[string][]posting
+}
+
+type Hit struct {
+	PointID string
+	Score   float32
+}
+
+// Builder accumulates text per paper; a paper's title, summary and every
+// chunk of its full text all count towards the same document
+type Builder struct {
+	docs  map[string]int
+	ids   []string
+	terms []map[string]int
+}
+
+func NewBuilder() *Builder {
+	return &Builder{docs: make(map[string]int)}
+}
+
+func (bl *Builder) AddTitle(pointID string, title string) {
+	for i := 0; i < titleBoost; i++ {
+		bl.Add(pointID, title)
+	}
+}
+
+func (bl *Builder) Add(pointID string, text string) {
+	doc, ok := bl.docs[pointID]
+	if !ok {
+		doc = len(bl.ids)
+		bl.docs[pointID] = doc
+		bl.ids = append(bl.ids, pointID)
+		bl.terms = append(bl.terms, make(map[string]int))
+	}
+
+	for _, term := range Tokenize(text) {
+		bl.terms[doc][term]++
+	}
+}
+
+func (bl *Builder) Build() *Index {
+	index := &Index{
+		ids:      bl.ids,
+		lengths:  make([]int, len(bl.ids)),
+		postings: make(map[string][]posting),
+	}
+
+	total := 0
+	for doc, terms := range bl.terms {
+		for term, tf := range terms {
+			index.postings[term] = append(index.postings[term], posting{doc: doc, tf: tf})
+			index.lengths[doc] += tf
+		}
+		total += index.lengths[doc]
+	}
+	if len(bl.ids) > 0 {
+		index.avgLen = float64(total) / float64(len(bl.ids))
+	}
+
+	return index
+}
+
+func (idx *Index) Len() int {
+	return len(idx.ids)
+}
+
+// Search returns up to limit papers ranked by BM25 score for query
+func (idx *Index) Search(query string, limit int) []Hit {
+	if idx == nil || len(idx.ids) == 0 {
+		return nil
+	}
+
+	n := float64(len(idx.ids))
+	scores := make(map[int]float64)
+	seen := make(map[string]bool)
+
+	for _, term := range Tokenize(query) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+
+		postings := idx.postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+
+		df := float64(len(postings))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+
+		for _, p := range postings {
+			tf := float64(p.tf)
+			norm := k1 * (1 - b + b*float64(idx.lengths[p.doc])/idx.avgLen)
+			scores[p.doc] += idf * tf * (k1 + 1) / (tf + norm)
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for doc, score := range scores {
+		hits = append(hits, Hit{PointID: idx.ids[doc], Score: float32(score)})
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].PointID < hits[j].PointID
+	})
+
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
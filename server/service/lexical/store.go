@@ -0,0 +1,251 @@
+package lexical
+
+import (
+	"RAGScholar/payload"
+	"RAGScholar/vectorstore"
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+const scrollPageSize = 256
+
+// Store holds the current index and keeps it up to date with Qdrant in the
+// background, since papers keep arriving through the consumer.
+//
+// A refresh lists the version of every paper and the source of every chunk,
+// which are small, and only fetches the text of papers that are new or
+// changed since the last refresh. The index and the term counts it is built
+// from stay in memory, so the service's memory grows with the corpus; past a
+// few hundred thousand papers a search engine, or Qdrant's own full-text
+// index, is the better place for lexical search.
+type Store struct {
+	mu    sync.RWMutex
+	index *Index
+
+	// refreshMu serializes refreshes, which own docs
+	refreshMu sync.Mutex
+	docs      map[string]*document
+}
+
+// document is the indexed text of one paper. signature identifies the paper
+// version and full text it was built from.
+type document struct {
+	signature string
+	terms     map[string]int
+}
+
+func NewStore() *Store {
+	return &Store{index: NewBuilder().Build(), docs: make(map[string]*document)}
+}
+
+func (s *Store) Index() *Index {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.index
+}
+
+// Refresh brings the index up to date with both collections and swaps it in
+func (s *Store) Refresh(ctx context.Context, store vectorstore.VectorStore, collectionName string, chunkCollectionName string) error {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	signatures := make(map[string]string)
+	err := scroll(ctx, store, collectionName, nil, []string{payload.FieldVersion, payload.FieldUpdatedAt}, func(point *qdrant.RetrievedPoint) {
+		signatures[point.Id.GetUuid()] = fmt.Sprintf("v%d@%d",
+			point.Payload[payload.FieldVersion].GetIntegerValue(), point.Payload[payload.FieldUpdatedAt].GetIntegerValue())
+	})
+	if err != nil {
+		return err
+	}
+
+	// Chunks are stored after their paper, and replaced when a new version's
+	// full text is indexed, so they count towards the paper's signature
+	chunkSources := make(map[string]map[string]int)
+	err = scroll(ctx, store, chunkCollectionName, nil, []string{payload.FieldChunkPaperPointID, payload.FieldChunkSourceID}, func(point *qdrant.RetrievedPoint) {
+		paperPointID := point.Payload[payload.FieldChunkPaperPointID].GetStringValue()
+		if chunkSources[paperPointID] == nil {
+			chunkSources[paperPointID] = make(map[string]int)
+		}
+		chunkSources[paperPointID][point.Payload[payload.FieldChunkSourceID].GetStringValue()]++
+	})
+	if err != nil {
+		// Full text is optional; index what we have
+		log.Printf("Skipping full-text chunks in lexical index: %v", err)
+	}
+
+	var changed []string
+	for pointID, signature := range signatures {
+		signature += chunkSignature(chunkSources[pointID])
+		signatures[pointID] = signature
+		if doc, ok := s.docs[pointID]; !ok || doc.signature != signature {
+			changed = append(changed, pointID)
+		}
+	}
+
+	removed := 0
+	for pointID := range s.docs {
+		if _, ok := signatures[pointID]; !ok {
+			delete(s.docs, pointID)
+			removed++
+		}
+	}
+
+	if len(changed) == 0 && removed == 0 {
+		return nil
+	}
+
+	updated, err := fetchDocuments(ctx, store, collectionName, chunkCollectionName, changed, chunkSources)
+	if err != nil {
+		return err
+	}
+	for pointID, terms := range updated {
+		s.docs[pointID] = &document{signature: signatures[pointID], terms: terms}
+	}
+
+	index := buildIndex(s.docs)
+
+	s.mu.Lock()
+	s.index = index
+	s.mu.Unlock()
+
+	log.Printf("Lexical index refreshed with %d papers (%d updated, %d removed)", index.Len(), len(updated), removed)
+	return nil
+}
+
+// RefreshEvery refreshes the index immediately and then every interval until
+// ctx is cancelled
+func (s *Store) RefreshEvery(ctx context.Context, store vectorstore.VectorStore, collectionName string, chunkCollectionName string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Refresh(ctx, store, collectionName, chunkCollectionName); err != nil {
+			log.Printf("Failed to refresh lexical index: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// chunkSignature summarizes a paper's chunks by the versions they came from
+func chunkSignature(sources map[string]int) string {
+	parts := make([]string, 0, len(sources))
+	for sourceID, count := range sources {
+		parts = append(parts, fmt.Sprintf("|%s*%d", sourceID, count))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "")
+}
+
+// fetchDocuments reads the title, summary and chunks of the given papers and
+// returns their term counts, keyed by point ID. Papers deleted since they
+// were listed are left out.
+func fetchDocuments(ctx context.Context, store vectorstore.VectorStore, collectionName string, chunkCollectionName string,
+	pointIDs []string, chunkSources map[string]map[string]int) (map[string]map[string]int, error) {
+
+	builder := NewBuilder()
+
+	for start := 0; start < len(pointIDs); start += scrollPageSize {
+		batch := pointIDs[start:min(start+scrollPageSize, len(pointIDs))]
+
+		ids := make([]*qdrant.PointId, len(batch))
+		var withChunks []string
+		for i, pointID := range batch {
+			ids[i] = qdrant.NewIDUUID(pointID)
+			if len(chunkSources[pointID]) > 0 {
+				withChunks = append(withChunks, pointID)
+			}
+		}
+
+		points, err := store.Get(ctx, &qdrant.GetPoints{
+			CollectionName: collectionName,
+			Ids:            ids,
+			WithPayload:    qdrant.NewWithPayloadInclude("title", "summary"),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch papers from %s: %w", collectionName, err)
+		}
+		for _, point := range points {
+			pointID := point.Id.GetUuid()
+			builder.AddTitle(pointID, point.Payload["title"].GetStringValue())
+			builder.Add(pointID, point.Payload["summary"].GetStringValue())
+		}
+
+		if len(withChunks) == 0 {
+			continue
+		}
+		filter := &qdrant.Filter{
+			Must: []*qdrant.Condition{qdrant.NewMatchKeywords(payload.FieldChunkPaperPointID, withChunks...)},
+		}
+		err = scroll(ctx, store, chunkCollectionName, filter, []string{payload.FieldChunkPaperPointID, payload.FieldChunkText}, func(point *qdrant.RetrievedPoint) {
+			pointID := point.Payload[payload.FieldChunkPaperPointID].GetStringValue()
+			if _, ok := builder.docs[pointID]; ok {
+				builder.Add(pointID, point.Payload[payload.FieldChunkText].GetStringValue())
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	documents := make(map[string]map[string]int, len(builder.ids))
+	for doc, pointID := range builder.ids {
+		documents[pointID] = builder.terms[doc]
+	}
+	return documents, nil
+}
+
+// buildIndex builds an index over every document, in point ID order so equal
+// scores always rank the same way
+func buildIndex(docs map[string]*document) *Index {
+	builder := NewBuilder()
+	for pointID := range docs {
+		builder.ids = append(builder.ids, pointID)
+	}
+	sort.Strings(builder.ids)
+	for doc, pointID := range builder.ids {
+		builder.docs[pointID] = doc
+		builder.terms = append(builder.terms, docs[pointID].terms)
+	}
+	return builder.Build()
+}
+
+func scroll(ctx context.Context, store vectorstore.VectorStore, collectionName string, filter *qdrant.Filter,
+	fields []string, fn func(*qdrant.RetrievedPoint)) error {
+
+	limit := uint32(scrollPageSize)
+	var offset *qdrant.PointId
+
+	for {
+		points, next, err := store.Scroll(ctx, &qdrant.ScrollPoints{
+			CollectionName: collectionName,
+			Filter:         filter,
+			Offset:         offset,
+			Limit:          &limit,
+			WithPayload:    qdrant.NewWithPayloadInclude(fields...),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to scroll %s: %w", collectionName, err)
+		}
+
+		for _, point := range points {
+			fn(point)
+		}
+
+		offset = next
+		if offset == nil {
+			return nil
+		}
+	}
+}
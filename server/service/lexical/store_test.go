@@ -0,0 +1,146 @@
+package lexical
+
+import (
+	"RAGScholar/domain"
+	"RAGScholar/vectorstore"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// countingStore counts the papers whose text a refresh fetches
+type countingStore struct {
+	vectorstore.VectorStore
+	fetched int
+}
+
+func (c *countingStore) Get(ctx context.Context, request *qdrant.GetPoints) ([]*qdrant.RetrievedPoint, error) {
+	c.fetched += len(request.GetIds())
+	return c.VectorStore.Get(ctx, request)
+}
+
+func pointID(n int) string {
+	return fmt.Sprintf("00000000-0000-0000-0000-%012d", n)
+}
+
+func storePaper(t *testing.T, store vectorstore.VectorStore, n int, version int, title string, summary string) {
+	t.Helper()
+	fields, err := domain.EncodePayload(domain.Entry{
+		ID:      fmt.Sprintf("http://arxiv.org/abs/2401.%05dv%d", n, version),
+		Title:   title,
+		Summary: summary,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Upsert(context.Background(), &qdrant.UpsertPoints{
+		CollectionName: "papers",
+		Points: []*qdrant.PointStruct{{
+			Id:      qdrant.NewIDUUID(pointID(n)),
+			Vectors: qdrant.NewVectorsDense([]float32{1, 0}),
+			Payload: fields,
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func storeChunk(t *testing.T, store vectorstore.VectorStore, n int, sourceID string, text string) {
+	t.Helper()
+	err := store.Upsert(context.Background(), &qdrant.UpsertPoints{
+		CollectionName: "paper_chunks",
+		Points: []*qdrant.PointStruct{{
+			Id:      qdrant.NewIDUUID(pointID(1000 + n)),
+			Vectors: qdrant.NewVectorsDense([]float32{1, 0}),
+			Payload: qdrant.NewValueMap(map[string]any{
+				"paperPointId": pointID(n),
+				"sourceId":     sourceID,
+				"text":         text,
+			}),
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func topHit(index *Index, query string) string {
+	hits := index.Search(query, 1)
+	if len(hits) == 0 {
+		return ""
+	}
+	return hits[0].PointID
+}
+
+func TestRefreshOnlyFetchesChangedPapers(t *testing.T) {
+	ctx := context.Background()
+	memory := vectorstore.NewMemory()
+	store := &countingStore{VectorStore: memory}
+	lexical := NewStore()
+
+	refresh := func() {
+		t.Helper()
+		store.fetched = 0
+		if err := lexical.Refresh(ctx, store, "papers", "paper_chunks"); err != nil {
+			t.Fatalf("Refresh: %v", err)
+		}
+	}
+
+	storePaper(t, memory, 1, 1, "Graph networks", "message passing on molecules")
+	storePaper(t, memory, 2, 1, "Dense retrieval", "dual encoders for passages")
+	refresh()
+	if store.fetched != 2 || lexical.Index().Len() != 2 {
+		t.Fatalf("first refresh fetched %d papers and indexed %d, want 2 and 2", store.fetched, lexical.Index().Len())
+	}
+	if got := topHit(lexical.Index(), "molecules"); got != pointID(1) {
+		t.Errorf("top hit for molecules = %q", got)
+	}
+
+	refresh()
+	if store.fetched != 0 {
+		t.Errorf("refresh without changes fetched %d papers", store.fetched)
+	}
+
+	// Full text arriving later changes the paper's document
+	storeChunk(t, memory, 2, "http://arxiv.org/abs/2401.00002v1", "we evaluate on benchmarks of citations")
+	storePaper(t, memory, 3, 1, "Sparse retrieval", "inverted indexes")
+	refresh()
+	if store.fetched != 2 {
+		t.Errorf("refresh after a new chunk and paper fetched %d papers, want 2", store.fetched)
+	}
+	if got := topHit(lexical.Index(), "citations"); got != pointID(2) {
+		t.Errorf("top hit for a chunk term = %q", got)
+	}
+
+	// A new version replaces the old text
+	storePaper(t, memory, 1, 2, "Graph transformers", "attention over proteins")
+	refresh()
+	if store.fetched != 1 {
+		t.Errorf("refresh after a new version fetched %d papers, want 1", store.fetched)
+	}
+	if got := topHit(lexical.Index(), "molecules"); got != "" {
+		t.Errorf("old version's text still matches %q", got)
+	}
+	if got := topHit(lexical.Index(), "proteins"); got != pointID(1) {
+		t.Errorf("top hit for the new version's text = %q", got)
+	}
+
+	// Deleted papers drop out without fetching anything
+	err := memory.Delete(ctx, &qdrant.DeletePoints{
+		CollectionName: "papers",
+		Points:         qdrant.NewPointsSelector(qdrant.NewIDUUID(pointID(3))),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	refresh()
+	if store.fetched != 0 || lexical.Index().Len() != 2 {
+		t.Errorf("refresh after a delete fetched %d papers and indexed %d, want 0 and 2", store.fetched, lexical.Index().Len())
+	}
+	if got := topHit(lexical.Index(), "inverted"); got != "" {
+		t.Errorf("deleted paper still matches: %q", got)
+	}
+}
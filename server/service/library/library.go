@@ -0,0 +1,363 @@
+package library
+
+import (
+	"RAGScholar/arxiv"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type User struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// SavedPaper keeps the title from when the paper was saved, so the library
+// can be listed without a round trip to Qdrant
+type SavedPaper struct {
+	PaperID string    `json:"paperId"`
+	Title   string    `json:"title"`
+	Note    string    `json:"note"`
+	SavedAt time.Time `json:"savedAt"`
+}
+
+type Collection struct {
+	ID          int64        `json:"id"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	CreatedAt   time.Time    `json:"createdAt"`
+	PaperCount  int          `json:"paperCount"`
+	Papers      []SavedPaper `json:"papers,omitempty"`
+}
+
+type Highlight struct {
+	ID           int64         `json:"id"`
+	PaperID      string        `json:"paperId"`
+	Text         string        `json:"text"`
+	Note         string        `json:"note"`
+	CreatedAt    time.Time     `json:"createdAt"`
+	Explanations []Explanation `json:"explanations"`
+}
+
+type Explanation struct {
+	ID          int64     `json:"id"`
+	HighlightID int64     `json:"highlightId"`
+	Model       string    `json:"model"`
+	Content     string    `json:"content"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+func (s *Store) CreateUser(ctx context.Context, name string) (User, error) {
+	user := User{Name: name, CreatedAt: now()}
+	result, err := s.db.ExecContext(ctx, "INSERT INTO users (name, created_at) VALUES (?, ?)", user.Name, user.CreatedAt)
+	if err != nil {
+		return User{}, storeError(err, "create user")
+	}
+	user.ID, err = result.LastInsertId()
+	return user, err
+}
+
+func (s *Store) GetUser(ctx context.Context, userID int64) (User, error) {
+	var user User
+	err := s.db.QueryRowContext(ctx, "SELECT id, name, created_at FROM users WHERE id = ?", userID).
+		Scan(&user.ID, &user.Name, &user.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, fmt.Errorf("user %d: %w", userID, ErrNotFound)
+	}
+	if err != nil {
+		return User{}, fmt.Errorf("failed to load user %d: %w", userID, err)
+	}
+	return user, nil
+}
+
+// SavePaper adds a paper to the user's library, or updates its title and
+// note when it is already there
+func (s *Store) SavePaper(ctx context.Context, userID int64, paperID, title, note string) (SavedPaper, error) {
+	saved := SavedPaper{PaperID: arxiv.NormalizeID(paperID), Title: title, Note: note, SavedAt: now()}
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO saved_papers (user_id, paper_id, title, note, saved_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, paper_id) DO UPDATE SET title = excluded.title, note = excluded.note
+		RETURNING saved_at`,
+		userID, saved.PaperID, saved.Title, saved.Note, saved.SavedAt).Scan(&saved.SavedAt)
+	if err != nil {
+		return SavedPaper{}, storeError(err, "save paper")
+	}
+	return saved, nil
+}
+
+// SavedPapers lists the user's library, most recently saved first
+func (s *Store) SavedPapers(ctx context.Context, userID int64) ([]SavedPaper, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT paper_id, title, note, saved_at FROM saved_papers
+		WHERE user_id = ? ORDER BY saved_at DESC, paper_id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list saved papers: %w", err)
+	}
+	return scanSavedPapers(rows)
+}
+
+// RemovePaper drops a paper from the library and from every collection
+func (s *Store) RemovePaper(ctx context.Context, userID int64, paperID string) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM saved_papers WHERE user_id = ? AND paper_id = ?",
+		userID, arxiv.NormalizeID(paperID))
+	return expectRow(result, err, "remove paper")
+}
+
+func (s *Store) CreateCollection(ctx context.Context, userID int64, name, description string) (Collection, error) {
+	collection := Collection{Name: name, Description: description, CreatedAt: now()}
+	result, err := s.db.ExecContext(ctx,
+		"INSERT INTO collections (user_id, name, description, created_at) VALUES (?, ?, ?, ?)",
+		userID, collection.Name, collection.Description, collection.CreatedAt)
+	if err != nil {
+		return Collection{}, storeError(err, "create collection")
+	}
+	collection.ID, err = result.LastInsertId()
+	return collection, err
+}
+
+// Collections lists the user's collections with their paper counts
+func (s *Store) Collections(ctx context.Context, userID int64) ([]Collection, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT c.id, c.name, c.description, c.created_at, COUNT(cp.paper_id)
+		FROM collections c LEFT JOIN collection_papers cp ON cp.collection_id = c.id
+		WHERE c.user_id = ? GROUP BY c.id ORDER BY c.name`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collections: %w", err)
+	}
+	defer rows.Close()
+
+	collections := []Collection{}
+	for rows.Next() {
+		var c Collection
+		if err := rows.Scan(&c.ID, &c.Name, &c.Description, &c.CreatedAt, &c.PaperCount); err != nil {
+			return nil, fmt.Errorf("failed to read collection: %w", err)
+		}
+		collections = append(collections, c)
+	}
+	return collections, rows.Err()
+}
+
+// GetCollection returns a collection with its papers, most recently added
+// first
+func (s *Store) GetCollection(ctx context.Context, userID, collectionID int64) (Collection, error) {
+	var c Collection
+	err := s.db.QueryRowContext(ctx,
+		"SELECT id, name, description, created_at FROM collections WHERE id = ? AND user_id = ?", collectionID, userID).
+		Scan(&c.ID, &c.Name, &c.Description, &c.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Collection{}, fmt.Errorf("collection %d: %w", collectionID, ErrNotFound)
+	}
+	if err != nil {
+		return Collection{}, fmt.Errorf("failed to load collection %d: %w", collectionID, err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT sp.paper_id, sp.title, sp.note, sp.saved_at
+		FROM collection_papers cp JOIN saved_papers sp ON sp.user_id = cp.user_id AND sp.paper_id = cp.paper_id
+		WHERE cp.collection_id = ? ORDER BY cp.added_at DESC, sp.paper_id`, collectionID)
+	if err != nil {
+		return Collection{}, fmt.Errorf("failed to list collection papers: %w", err)
+	}
+	if c.Papers, err = scanSavedPapers(rows); err != nil {
+		return Collection{}, err
+	}
+	c.PaperCount = len(c.Papers)
+	return c, nil
+}
+
+// DeleteCollection deletes a collection; its papers stay in the library
+func (s *Store) DeleteCollection(ctx context.Context, userID, collectionID int64) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM collections WHERE id = ? AND user_id = ?", collectionID, userID)
+	return expectRow(result, err, "delete collection")
+}
+
+// AddToCollection puts a paper in a collection, saving it to the library
+// first if needed
+func (s *Store) AddToCollection(ctx context.Context, userID, collectionID int64, paperID, title string) error {
+	paperID = arxiv.NormalizeID(paperID)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var owner int64
+	err = tx.QueryRowContext(ctx, "SELECT user_id FROM collections WHERE id = ?", collectionID).Scan(&owner)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to load collection %d: %w", collectionID, err)
+	}
+	if err != nil || owner != userID {
+		return fmt.Errorf("collection %d: %w", collectionID, ErrNotFound)
+	}
+
+	added := now()
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO saved_papers (user_id, paper_id, title, saved_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_id, paper_id) DO NOTHING`, userID, paperID, title, added)
+	if err != nil {
+		return storeError(err, "save paper")
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO collection_papers (collection_id, user_id, paper_id, added_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (collection_id, paper_id) DO NOTHING`, collectionID, userID, paperID, added)
+	if err != nil {
+		return storeError(err, "add paper to collection")
+	}
+
+	return tx.Commit()
+}
+
+// RemoveFromCollection takes a paper out of a collection; it stays in the
+// library
+func (s *Store) RemoveFromCollection(ctx context.Context, userID, collectionID int64, paperID string) error {
+	result, err := s.db.ExecContext(ctx,
+		"DELETE FROM collection_papers WHERE collection_id = ? AND user_id = ? AND paper_id = ?",
+		collectionID, userID, arxiv.NormalizeID(paperID))
+	return expectRow(result, err, "remove paper from collection")
+}
+
+func (s *Store) AddHighlight(ctx context.Context, userID int64, paperID, text, note string) (Highlight, error) {
+	highlight := Highlight{PaperID: arxiv.NormalizeID(paperID), Text: text, Note: note, CreatedAt: now(), Explanations: []Explanation{}}
+	result, err := s.db.ExecContext(ctx,
+		"INSERT INTO highlights (user_id, paper_id, text, note, created_at) VALUES (?, ?, ?, ?, ?)",
+		userID, highlight.PaperID, highlight.Text, highlight.Note, highlight.CreatedAt)
+	if err != nil {
+		return Highlight{}, storeError(err, "add highlight")
+	}
+	highlight.ID, err = result.LastInsertId()
+	return highlight, err
+}
+
+// Highlights lists the user's highlights with their explanations, oldest
+// first. An empty paperID lists highlights across all papers.
+func (s *Store) Highlights(ctx context.Context, userID int64, paperID string) ([]Highlight, error) {
+	query := "SELECT id, paper_id, text, note, created_at FROM highlights WHERE user_id = ?"
+	args := []any{userID}
+	if paperID != "" {
+		query += " AND paper_id = ?"
+		args = append(args, arxiv.NormalizeID(paperID))
+	}
+	query += " ORDER BY created_at, id"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list highlights: %w", err)
+	}
+	defer rows.Close()
+
+	highlights := []Highlight{}
+	index := make(map[int64]int)
+	for rows.Next() {
+		h := Highlight{Explanations: []Explanation{}}
+		if err := rows.Scan(&h.ID, &h.PaperID, &h.Text, &h.Note, &h.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to read highlight: %w", err)
+		}
+		index[h.ID] = len(highlights)
+		highlights = append(highlights, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list highlights: %w", err)
+	}
+	if len(highlights) == 0 {
+		return highlights, nil
+	}
+
+	rows, err = s.db.QueryContext(ctx, `
+		SELECT e.id, e.highlight_id, e.model, e.content, e.created_at
+		FROM explanations e JOIN highlights h ON h.id = e.highlight_id
+		WHERE h.user_id = ? ORDER BY e.created_at, e.id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list explanations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e Explanation
+		if err := rows.Scan(&e.ID, &e.HighlightID, &e.Model, &e.Content, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to read explanation: %w", err)
+		}
+		if i, ok := index[e.HighlightID]; ok {
+			highlights[i].Explanations = append(highlights[i].Explanations, e)
+		}
+	}
+	return highlights, rows.Err()
+}
+
+// DeleteHighlight deletes a highlight and its explanations
+func (s *Store) DeleteHighlight(ctx context.Context, userID, highlightID int64) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM highlights WHERE id = ? AND user_id = ?", highlightID, userID)
+	return expectRow(result, err, "delete highlight")
+}
+
+// AddExplanation stores an explanation generated for one of the user's
+// highlights
+func (s *Store) AddExplanation(ctx context.Context, userID, highlightID int64, model, content string) (Explanation, error) {
+	explanation := Explanation{HighlightID: highlightID, Model: model, Content: content, CreatedAt: now()}
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO explanations (highlight_id, model, content, created_at)
+		SELECT id, ?, ?, ? FROM highlights WHERE id = ? AND user_id = ?`,
+		explanation.Model, explanation.Content, explanation.CreatedAt, highlightID, userID)
+	if err := expectRow(result, err, "add explanation"); err != nil {
+		return Explanation{}, err
+	}
+	explanation.ID, err = result.LastInsertId()
+	return explanation, err
+}
+
+// DismissPaper marks a paper as not interesting to the user, so the
+// personalized feed leaves it out and steers away from papers like it
+func (s *Store) DismissPaper(ctx context.Context, userID int64, paperID string) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO dismissed_papers (user_id, paper_id, dismissed_at) VALUES (?, ?, ?)
+		ON CONFLICT (user_id, paper_id) DO NOTHING`, userID, arxiv.NormalizeID(paperID), now())
+	if err != nil {
+		return storeError(err, "dismiss paper")
+	}
+	return nil
+}
+
+// RestorePaper undoes DismissPaper
+func (s *Store) RestorePaper(ctx context.Context, userID int64, paperID string) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM dismissed_papers WHERE user_id = ? AND paper_id = ?",
+		userID, arxiv.NormalizeID(paperID))
+	return expectRow(result, err, "restore paper")
+}
+
+// DismissedPapers lists the IDs of the papers the user dismissed, most
+// recent first
+func (s *Store) DismissedPapers(ctx context.Context, userID int64) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT paper_id FROM dismissed_papers WHERE user_id = ? ORDER BY dismissed_at DESC, paper_id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dismissed papers: %w", err)
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to read dismissed paper: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func scanSavedPapers(rows *sql.Rows) ([]SavedPaper, error) {
+	defer rows.Close()
+
+	papers := []SavedPaper{}
+	for rows.Next() {
+		var p SavedPaper
+		if err := rows.Scan(&p.PaperID, &p.Title, &p.Note, &p.SavedAt); err != nil {
+			return nil, fmt.Errorf("failed to read saved paper: %w", err)
+		}
+		papers = append(papers, p)
+	}
+	return papers, rows.Err()
+}
@@ -0,0 +1,276 @@
+package library
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func openStore(t *testing.T) *Store {
+	t.Helper()
+	store, err := Open(filepath.Join(t.TempDir(), "library.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func createUser(t *testing.T, store *Store, name string) User {
+	t.Helper()
+	user, err := store.CreateUser(context.Background(), name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func userVersion(t *testing.T, db *sql.DB) int {
+	t.Helper()
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	return version
+}
+
+func TestOpenMigrates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "library.db")
+
+	// A database from before the second migration
+	db, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(migrations[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("PRAGMA user_version = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO users (name, created_at) VALUES ('ada', ?)", now()); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if got := userVersion(t, store.db); got != len(migrations) {
+		t.Errorf("user_version = %d after opening, want %d", got, len(migrations))
+	}
+	// The existing rows are kept and the new table works
+	if err := store.DismissPaper(context.Background(), 1, "2401.01234"); err != nil {
+		t.Errorf("DismissPaper after migrating: %v", err)
+	}
+	store.Close()
+
+	// Opening an up-to-date database changes nothing
+	store, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	dismissed, err := store.DismissedPapers(context.Background(), 1)
+	if err != nil || len(dismissed) != 1 {
+		t.Errorf("dismissed papers after reopening = %v, %v", dismissed, err)
+	}
+	if _, err := store.db.Exec(fmt.Sprintf("PRAGMA user_version = %d", len(migrations)+1)); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	// A database from a newer build is left alone
+	if store, err := Open(path); err == nil {
+		store.Close()
+		t.Error("Open accepted a database with a newer schema")
+	}
+}
+
+func TestSavedPapers(t *testing.T) {
+	ctx := context.Background()
+	store := openStore(t)
+	user := createUser(t, store, "ada")
+
+	// Papers are stored under their versionless ID
+	saved, err := store.SavePaper(ctx, user.ID, "http://arxiv.org/abs/2401.01234v2", "Attention", "read later")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.PaperID != "2401.01234" {
+		t.Errorf("saved paper ID = %q, want 2401.01234", saved.PaperID)
+	}
+	if _, err := store.SavePaper(ctx, user.ID, "hep-th/9901001v1", "Strings", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// Saving another version updates the note and keeps the save time
+	again, err := store.SavePaper(ctx, user.ID, "arXiv:2401.01234v3", "Attention", "section 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Note != "section 3" || !again.SavedAt.Equal(saved.SavedAt) {
+		t.Errorf("saving again = %+v, want the new note and saved at %v", again, saved.SavedAt)
+	}
+
+	papers, err := store.SavedPapers(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(papers) != 2 {
+		t.Fatalf("library holds %+v, want 2 papers", papers)
+	}
+	if papers[0].PaperID != "hep-th/9901001" || papers[1].PaperID != "2401.01234" {
+		t.Errorf("library order = %s, %s, want the most recently saved first", papers[0].PaperID, papers[1].PaperID)
+	}
+
+	if err := store.RemovePaper(ctx, user.ID, "2401.01234v1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RemovePaper(ctx, user.ID, "2401.01234"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("removing a removed paper: %v, want ErrNotFound", err)
+	}
+	if papers, _ := store.SavedPapers(ctx, user.ID); len(papers) != 1 {
+		t.Errorf("library holds %+v after removing a paper", papers)
+	}
+
+	// Libraries are kept apart
+	other := createUser(t, store, "grace")
+	if papers, _ := store.SavedPapers(ctx, other.ID); len(papers) != 0 {
+		t.Errorf("another user's library holds %+v", papers)
+	}
+	if err := store.RemovePaper(ctx, other.ID, "hep-th/9901001"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("removing another user's paper: %v, want ErrNotFound", err)
+	}
+}
+
+func TestCollections(t *testing.T) {
+	ctx := context.Background()
+	store := openStore(t)
+	user := createUser(t, store, "ada")
+
+	collection, err := store.CreateCollection(ctx, user.ID, "Transformers", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Adding a paper saves it to the library too
+	if err := store.AddToCollection(ctx, user.ID, collection.ID, "2401.01234v1", "Attention"); err != nil {
+		t.Fatal(err)
+	}
+	if papers, _ := store.SavedPapers(ctx, user.ID); len(papers) != 1 {
+		t.Errorf("library holds %+v after adding to a collection", papers)
+	}
+	got, err := store.GetCollection(ctx, user.ID, collection.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.PaperCount != 1 || got.Papers[0].PaperID != "2401.01234" {
+		t.Errorf("collection = %+v, want 2401.01234 in it", got)
+	}
+
+	// Removing a paper from the library takes it out of its collections
+	if err := store.RemovePaper(ctx, user.ID, "2401.01234"); err != nil {
+		t.Fatal(err)
+	}
+	collections, err := store.Collections(ctx, user.ID)
+	if err != nil || len(collections) != 1 || collections[0].PaperCount != 0 {
+		t.Errorf("collections after removing the paper = %+v, %v", collections, err)
+	}
+
+	other := createUser(t, store, "grace")
+	if err := store.AddToCollection(ctx, other.ID, collection.ID, "2401.01234", "Attention"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("adding to another user's collection: %v, want ErrNotFound", err)
+	}
+	if err := store.DeleteCollection(ctx, user.ID, collection.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetCollection(ctx, user.ID, collection.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("loading a deleted collection: %v, want ErrNotFound", err)
+	}
+}
+
+func TestHighlights(t *testing.T) {
+	ctx := context.Background()
+	store := openStore(t)
+	user := createUser(t, store, "ada")
+
+	first, err := store.AddHighlight(ctx, user.ID, "2401.01234v2", "scaled dot-product attention", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.AddHighlight(ctx, user.ID, "hep-th/9901001", "branes", "check"); err != nil {
+		t.Fatal(err)
+	}
+	explanation, err := store.AddExplanation(ctx, user.ID, first.ID, "gemini", "Attention weights values by query-key similarity.")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	highlights, err := store.Highlights(ctx, user.ID, "http://arxiv.org/abs/2401.01234v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(highlights) != 1 || highlights[0].ID != first.ID {
+		t.Fatalf("highlights of 2401.01234 = %+v, want only the first", highlights)
+	}
+	if len(highlights[0].Explanations) != 1 || highlights[0].Explanations[0].ID != explanation.ID {
+		t.Errorf("highlight explanations = %+v, want the one added", highlights[0].Explanations)
+	}
+	if all, _ := store.Highlights(ctx, user.ID, ""); len(all) != 2 {
+		t.Errorf("all highlights = %+v, want 2", all)
+	}
+
+	other := createUser(t, store, "grace")
+	if _, err := store.AddExplanation(ctx, other.ID, first.ID, "gemini", "text"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("explaining another user's highlight: %v, want ErrNotFound", err)
+	}
+	if err := store.DeleteHighlight(ctx, other.ID, first.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleting another user's highlight: %v, want ErrNotFound", err)
+	}
+
+	if err := store.DeleteHighlight(ctx, user.ID, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DeleteHighlight(ctx, user.ID, first.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleting a deleted highlight: %v, want ErrNotFound", err)
+	}
+	var explanations int
+	if err := store.db.QueryRow("SELECT COUNT(*) FROM explanations").Scan(&explanations); err != nil || explanations != 0 {
+		t.Errorf("%d explanations left after deleting their highlight (err %v)", explanations, err)
+	}
+}
+
+func TestStoreErrors(t *testing.T) {
+	ctx := context.Background()
+	store := openStore(t)
+	user := createUser(t, store, "ada")
+	if _, err := store.CreateCollection(ctx, user.ID, "Reading", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		action string
+		err    error
+		want   error
+	}{
+		{"duplicate user", second(store.CreateUser(ctx, "ada")), ErrConflict},
+		{"duplicate collection", second(store.CreateCollection(ctx, user.ID, "Reading", "")), ErrConflict},
+		{"missing user", second(store.GetUser(ctx, 99)), ErrNotFound},
+		{"save for a missing user", second(store.SavePaper(ctx, 99, "2401.01234", "Attention", "")), ErrNotFound},
+		{"collection for a missing user", second(store.CreateCollection(ctx, 99, "Reading", "")), ErrNotFound},
+		{"missing collection", store.AddToCollection(ctx, user.ID, 99, "2401.01234", "Attention"), ErrNotFound},
+		{"restore an undismissed paper", store.RestorePaper(ctx, user.ID, "2401.01234"), ErrNotFound},
+	}
+	for _, test := range tests {
+		if !errors.Is(test.err, test.want) {
+			t.Errorf("%s: %v, want %v", test.action, test.err, test.want)
+		}
+	}
+}
+
+func second[T any](_ T, err error) error {
+	return err
+}
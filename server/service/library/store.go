@@ -0,0 +1,165 @@
+// Package library keeps each user's saved papers, collections, highlights,
+// the explanations generated for them and the papers they marked as not
+// interesting in a SQLite database. Papers are
+// referenced by their versionless arXiv ID, as accepted by
+// paper.FetchPaperByID.
+package library
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+var (
+	ErrNotFound = errors.New("not found")
+	ErrConflict = errors.New("already exists")
+)
+
+// migrations upgrade the schema one step each; the database's user_version
+// records how many have been applied. Only ever append to this list.
+var migrations = []string{
+	`CREATE TABLE users (
+		id         INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL UNIQUE,
+		created_at DATETIME NOT NULL
+	);
+	CREATE TABLE saved_papers (
+		user_id  INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		paper_id TEXT NOT NULL,
+		title    TEXT NOT NULL,
+		note     TEXT NOT NULL DEFAULT '',
+		saved_at DATETIME NOT NULL,
+		PRIMARY KEY (user_id, paper_id)
+	);
+	CREATE TABLE collections (
+		id          INTEGER PRIMARY KEY,
+		user_id     INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		name        TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		created_at  DATETIME NOT NULL,
+		UNIQUE (user_id, name)
+	);
+	CREATE TABLE collection_papers (
+		collection_id INTEGER NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+		user_id       INTEGER NOT NULL,
+		paper_id      TEXT NOT NULL,
+		added_at      DATETIME NOT NULL,
+		PRIMARY KEY (collection_id, paper_id),
+		FOREIGN KEY (user_id, paper_id) REFERENCES saved_papers(user_id, paper_id) ON DELETE CASCADE
+	);
+	CREATE TABLE highlights (
+		id         INTEGER PRIMARY KEY,
+		user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		paper_id   TEXT NOT NULL,
+		text       TEXT NOT NULL,
+		note       TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL
+	);
+	CREATE INDEX highlights_by_paper ON highlights(user_id, paper_id);
+	CREATE TABLE explanations (
+		id           INTEGER PRIMARY KEY,
+		highlight_id INTEGER NOT NULL REFERENCES highlights(id) ON DELETE CASCADE,
+		model        TEXT NOT NULL DEFAULT '',
+		content      TEXT NOT NULL,
+		created_at   DATETIME NOT NULL
+	);`,
+	`CREATE TABLE dismissed_papers (
+		user_id      INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		paper_id     TEXT NOT NULL,
+		dismissed_at DATETIME NOT NULL,
+		PRIMARY KEY (user_id, paper_id)
+	);`,
+}
+
+// Store is the library database. It is safe for concurrent use.
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens the database at path, creating it if needed, and brings its
+// schema up to date
+func Open(path string) (*Store, error) {
+	// Foreign keys are off by default in SQLite and are set per connection
+	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open library database: %w", err)
+	}
+
+	store := &Store{db: db}
+	if err := store.migrate(context.Background()); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) migrate(ctx context.Context) error {
+	var version int
+	if err := s.db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read library schema version: %w", err)
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("library database is at schema version %d, newer than this build (%d)", version, len(migrations))
+	}
+
+	for ; version < len(migrations); version++ {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to begin library migration: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, migrations[version]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to migrate library to schema version %d: %w", version+1, err)
+		}
+		// PRAGMA doesn't take bind parameters
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", version+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record library schema version: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit library migration: %w", err)
+		}
+	}
+	return nil
+}
+
+// now is the timestamp stored on new rows
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+// storeError maps constraint violations to ErrConflict and ErrNotFound
+func storeError(err error, action string) error {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			return fmt.Errorf("failed to %s: %w", action, ErrConflict)
+		case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+			return fmt.Errorf("failed to %s: %w", action, ErrNotFound)
+		}
+	}
+	return fmt.Errorf("failed to %s: %w", action, err)
+}
+
+// expectRow turns an update or delete that touched nothing into ErrNotFound
+func expectRow(result sql.Result, err error, action string) error {
+	if err != nil {
+		return storeError(err, action)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("failed to %s: %w", action, ErrNotFound)
+	}
+	return nil
+}
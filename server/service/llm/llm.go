@@ -0,0 +1,85 @@
+package llm
+
+import (
+	"RAGScholar/gemini"
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// LLM generates a text completion for a prompt under a system instruction
+type LLM interface {
+	Generate(ctx context.Context, systemPrompt string, prompt string) (string, error)
+}
+
+// Gemini generates with a Gemini model through the shared client, which
+// paces and retries its calls
+type Gemini struct {
+	client    *gemini.Client
+	modelName string
+}
+
+func NewGemini(client *gemini.Client, modelName string) *Gemini {
+	return &Gemini{client: client, modelName: modelName}
+}
+
+func (g *Gemini) Generate(ctx context.Context, systemPrompt string, prompt string) (string, error) {
+	model := g.client.GenerativeModel(g.modelName)
+	if model == nil {
+		return "", fmt.Errorf("failed to initialize Gemini model")
+	}
+
+	model.SystemInstruction = &genai.Content{
+		Parts: []genai.Part{
+			genai.Text(systemPrompt),
+		},
+	}
+
+	resp, err := model.GenerateContent(ctx, genai.Text(prompt))
+	if err != nil {
+		return "", err
+	}
+
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil || len(resp.Candidates[0].Content.Parts) == 0 {
+		return "", fmt.Errorf("received empty response from Gemini")
+	}
+
+	var sb strings.Builder
+	for _, part := range resp.Candidates[0].Content.Parts {
+		if text, ok := part.(genai.Text); ok {
+			sb.WriteString(string(text))
+		}
+	}
+	if sb.Len() == 0 {
+		return "", fmt.Errorf("unexpected response format from Gemini")
+	}
+
+	return sb.String(), nil
+}
+
+var sourceLine = regexp.MustCompile(`(?m)^\[(\d+)\]`)
+
+// Fake is a deterministic LLM for tests and offline development. It answers
+// by citing every numbered source found in the prompt.
+type Fake struct{}
+
+func (Fake) Generate(ctx context.Context, systemPrompt string, prompt string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	matches := sourceLine.FindAllStringSubmatch(prompt, -1)
+	if len(matches) == 0 {
+		return "The provided sources do not contain enough information to answer.", nil
+	}
+
+	citations := make([]string, len(matches))
+	for i, match := range matches {
+		citations[i] = "[" + match[1] + "]"
+	}
+
+	return "This answer is based on the retrieved sources " + strings.Join(citations, " ") + ".", nil
+}
@@ -13,6 +13,88 @@ type TextAnalysisResponse struct {
 }
 
 type PaperResult struct {
-	Paper interface{} `json:"paper"`
-	Score float32     `json:"score"` 
+	Paper         interface{} `json:"paper"`
+	Score         float32     `json:"score"`
+	SemanticScore float32     `json:"semanticScore"`
+	LexicalScore  float32     `json:"lexicalScore"`
+}
+
+type AskRequest struct {
+	Question string `json:"question"`
+	TopK     int    `json:"topK"`
+}
+
+type AskResponse struct {
+	Answer    string     `json:"answer"`
+	Citations []Citation `json:"citations"`
+	Sources   []Source   `json:"sources"`
+}
+
+// Source is one retrieved passage, numbered as it appears in the prompt
+type Source struct {
+	Number  int     `json:"number"`
+	PaperID string  `json:"paperId"`
+	Title   string  `json:"title"`
+	Section string  `json:"section,omitempty"`
+	Text    string  `json:"text"`
+	Score   float32 `json:"score"`
+}
+
+type Citation struct {
+	Number  int    `json:"number"`
+	PaperID string `json:"paperId"`
+	Title   string `json:"title"`
+}
+
+// IncrementalHarvestRequest selects an OAI-PMH harvest; dates are YYYY-MM-DD
+type IncrementalHarvestRequest struct {
+	From           string `json:"from"`
+	Until          string `json:"until"`
+	Set            string `json:"set"`
+	MetadataPrefix string `json:"metadataPrefix"`
+}
+
+type SearchResponse struct {
+	Results    []PaperResult `json:"results"`
+	NextCursor string        `json:"nextCursor,omitempty"`
+	Facets     Facets        `json:"facets"`
+}
+
+// Facets counts the papers matching the filters of a /search request
+type Facets struct {
+	Categories []FacetCount `json:"categories"`
+	Archives   []FacetCount `json:"archives"`
+	Years      []FacetCount `json:"years"`
+}
+
+// FacetCount is the number of matching papers with a value; Label is the
+// human-readable name of category and archive codes
+type FacetCount struct {
+	Value string `json:"value"`
+	Label string `json:"label,omitempty"`
+	Count uint64 `json:"count"`
+}
+
+type CreateUserRequest struct {
+	Name string `json:"name"`
+}
+
+type SavePaperRequest struct {
+	Note string `json:"note"`
+}
+
+type CreateCollectionRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type HighlightRequest struct {
+	PaperID string `json:"paperId"`
+	Text    string `json:"text"`
+	Note    string `json:"note"`
+}
+
+type ExplanationRequest struct {
+	Model   string `json:"model"`
+	Content string `json:"content"`
 }
@@ -0,0 +1,188 @@
+package oaipmh
+
+import (
+	"RAGScholar/service/structure"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	DefaultBaseURL = "http://export.arxiv.org/oai2"
+
+	FormatArXiv    = "arXiv"
+	FormatArXivRaw = "arXivRaw"
+
+	dateLayout = "2006-01-02"
+)
+
+// Params selects the records of one ListRecords harvest. Set is an OAI-PMH
+// setSpec such as "cs" or "physics:hep-th"; an empty Set harvests everything.
+// MetadataPrefix defaults to arXivRaw, the format that carries version
+// numbers; arXiv records have none, so their updates are ordered by date.
+type Params struct {
+	From           time.Time
+	Until          time.Time
+	Set            string
+	MetadataPrefix string
+}
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	limiter    *rate.Limiter
+	maxRetries int
+}
+
+func NewClient(baseURL string, httpClient *http.Client, limiter *rate.Limiter) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 2 * time.Minute}
+	}
+	if limiter == nil {
+		limiter = rate.NewLimiter(rate.Every(3*time.Second), 1)
+	}
+	return &Client{baseURL: baseURL, httpClient: httpClient, limiter: limiter, maxRetries: 5}
+}
+
+// ListRecords harvests every record matching params, following resumption
+// tokens, and calls fn with the entries of each response page. Deleted
+// records are skipped.
+func (c *Client) ListRecords(ctx context.Context, params Params, fn func([]structure.SimplifiedEntry) error) error {
+	if params.MetadataPrefix == "" {
+		params.MetadataPrefix = FormatArXivRaw
+	}
+	if params.MetadataPrefix != FormatArXiv && params.MetadataPrefix != FormatArXivRaw {
+		return fmt.Errorf("unsupported metadata format %q", params.MetadataPrefix)
+	}
+
+	query := url.Values{}
+	query.Set("verb", "ListRecords")
+	query.Set("metadataPrefix", params.MetadataPrefix)
+	if !params.From.IsZero() {
+		query.Set("from", params.From.UTC().Format(dateLayout))
+	}
+	if !params.Until.IsZero() {
+		query.Set("until", params.Until.UTC().Format(dateLayout))
+	}
+	if params.Set != "" {
+		query.Set("set", params.Set)
+	}
+
+	for {
+		response, err := c.fetch(ctx, query)
+		if err != nil {
+			return err
+		}
+
+		if response.Error != nil {
+			// An empty date range is reported as an error by OAI-PMH
+			if response.Error.Code == "noRecordsMatch" {
+				return nil
+			}
+			return fmt.Errorf("OAI-PMH error %s: %s", response.Error.Code, strings.TrimSpace(response.Error.Message))
+		}
+
+		entries := make([]structure.SimplifiedEntry, 0, len(response.ListRecords.Records))
+		for _, record := range response.ListRecords.Records {
+			if record.Header.Status == "deleted" {
+				continue
+			}
+			entry, ok := record.toEntry()
+			if !ok {
+				log.Printf("Skipping OAI-PMH record %s without metadata", record.Header.Identifier)
+				continue
+			}
+			entries = append(entries, entry)
+		}
+
+		if len(entries) > 0 {
+			if err := fn(entries); err != nil {
+				return err
+			}
+		}
+
+		token := strings.TrimSpace(response.ListRecords.ResumptionToken.Value)
+		if token == "" {
+			return nil
+		}
+
+		// Follow-up requests carry only the verb and the resumption token
+		query = url.Values{}
+		query.Set("verb", "ListRecords")
+		query.Set("resumptionToken", token)
+	}
+}
+
+// fetch performs one OAI-PMH request, honouring 503 Retry-After flow control
+func (c *Client) fetch(ctx context.Context, query url.Values) (*oaiResponse, error) {
+	requestURL := c.baseURL + "?" + query.Encode()
+
+	for attempt := 0; ; attempt++ {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("OAI-PMH request failed: %w", err)
+		}
+
+		if res.StatusCode == http.StatusServiceUnavailable && attempt < c.maxRetries {
+			res.Body.Close()
+			wait := retryAfter(res.Header.Get("Retry-After"))
+			log.Printf("OAI-PMH server asked to retry after %s", wait)
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(wait):
+			}
+			continue
+		}
+
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read OAI-PMH response: %w", err)
+		}
+
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("OAI-PMH request returned status %d", res.StatusCode)
+		}
+
+		var response oaiResponse
+		if err := xml.Unmarshal(body, &response); err != nil {
+			return nil, fmt.Errorf("failed to parse OAI-PMH response: %w", err)
+		}
+
+		return &response, nil
+	}
+}
+
+func retryAfter(header string) time.Duration {
+	if seconds, err := strconv.Atoi(strings.TrimSpace(header)); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait
+		}
+	}
+	return 10 * time.Second
+}
@@ -0,0 +1,191 @@
+package oaipmh
+
+import (
+	"RAGScholar/service/structure"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// fixtureServer serves the recorded responses in testdata: the arXiv format
+// in two pages joined by a resumption token, the arXivRaw format in one, and
+// noRecordsMatch for any range starting in 2030.
+type fixtureServer struct {
+	t *testing.T
+
+	mu       sync.Mutex
+	requests []url.Values
+}
+
+func (f *fixtureServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	f.mu.Lock()
+	f.requests = append(f.requests, query)
+	f.mu.Unlock()
+
+	var fixture string
+	switch {
+	case query.Get("resumptionToken") == "6960524|1001":
+		fixture = "arxiv_page2.xml"
+	case query.Get("from") == "2030-01-01":
+		fixture = "norecords.xml"
+	case query.Get("metadataPrefix") == FormatArXiv:
+		fixture = "arxiv_page1.xml"
+	case query.Get("metadataPrefix") == FormatArXivRaw:
+		fixture = "arxivraw.xml"
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+		return
+	}
+
+	data, err := os.ReadFile("testdata/" + fixture)
+	if err != nil {
+		f.t.Errorf("reading fixture: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml")
+	w.Write(data)
+}
+
+func newTestClient(t *testing.T) (*Client, *fixtureServer) {
+	t.Helper()
+	fixtures := &fixtureServer{t: t}
+	server := httptest.NewServer(fixtures)
+	t.Cleanup(server.Close)
+	return NewClient(server.URL, server.Client(), rate.NewLimiter(rate.Inf, 1)), fixtures
+}
+
+func listAll(t *testing.T, client *Client, params Params) [][]structure.SimplifiedEntry {
+	t.Helper()
+	var pages [][]structure.SimplifiedEntry
+	err := client.ListRecords(context.Background(), params, func(entries []structure.SimplifiedEntry) error {
+		pages = append(pages, entries)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ListRecords: %v", err)
+	}
+	return pages
+}
+
+var testRange = Params{
+	From:  time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+	Until: time.Date(2025, 4, 2, 0, 0, 0, 0, time.UTC),
+	Set:   "cs",
+}
+
+func TestListRecordsFollowsResumptionTokens(t *testing.T) {
+	client, fixtures := newTestClient(t)
+	params := testRange
+	params.MetadataPrefix = FormatArXiv
+
+	pages := listAll(t, client, params)
+
+	// The deleted record on the first page is skipped
+	if len(pages) != 2 || len(pages[0]) != 1 || len(pages[1]) != 1 {
+		t.Fatalf("got pages %v, want one entry on each of two pages", pages)
+	}
+
+	first := fixtures.requests[0]
+	if first.Get("verb") != "ListRecords" || first.Get("metadataPrefix") != FormatArXiv ||
+		first.Get("from") != "2025-04-01" || first.Get("until") != "2025-04-02" || first.Get("set") != "cs" {
+		t.Errorf("first request = %v", first)
+	}
+	second := fixtures.requests[1]
+	if len(second) != 2 || second.Get("verb") != "ListRecords" || second.Get("resumptionToken") != "6960524|1001" {
+		t.Errorf("follow-up request = %v, want only the verb and resumption token", second)
+	}
+}
+
+func TestListRecordsArXivFormat(t *testing.T) {
+	client, _ := newTestClient(t)
+	params := testRange
+	params.MetadataPrefix = FormatArXiv
+
+	pages := listAll(t, client, params)
+	entry := pages[0][0]
+
+	if entry.ID != "http://arxiv.org/abs/0704.0002" {
+		t.Errorf("ID = %q", entry.ID)
+	}
+	if entry.Title != "Sparsity-certifying Graph Decompositions" {
+		t.Errorf("Title = %q", entry.Title)
+	}
+	if entry.Published != "2007-03-30T00:00:00Z" || entry.Updated != "2008-12-13T00:00:00Z" {
+		t.Errorf("Published, Updated = %q, %q", entry.Published, entry.Updated)
+	}
+	if len(entry.Authors) != 2 || entry.Authors[0].Name != "Ileana Streinu" || entry.Authors[1].Name != "Louis Theran" {
+		t.Errorf("Authors = %v", entry.Authors)
+	}
+	if entry.PrimaryCategory != "math.CO" || len(entry.Categories) != 2 {
+		t.Errorf("PrimaryCategory, Categories = %q, %v", entry.PrimaryCategory, entry.Categories)
+	}
+	if entry.Links[1].Href != "http://arxiv.org/pdf/0704.0002" || entry.Links[1].Type != "application/pdf" {
+		t.Errorf("PDF link = %+v", entry.Links[1])
+	}
+
+	// Without an updated date the creation date stands in
+	old := pages[1][0]
+	if old.ID != "http://arxiv.org/abs/cs/9901001" || old.Updated != old.Published {
+		t.Errorf("old-style entry ID, Updated = %q, %q", old.ID, old.Updated)
+	}
+	if len(old.Authors) != 1 || old.Authors[0].Name != "Jane Doe Jr" || old.DOI != "10.1000/test.1" {
+		t.Errorf("old-style entry Authors, DOI = %v, %q", old.Authors, old.DOI)
+	}
+}
+
+func TestListRecordsDefaultsToArXivRaw(t *testing.T) {
+	client, fixtures := newTestClient(t)
+
+	pages := listAll(t, client, testRange)
+
+	if got := fixtures.requests[0].Get("metadataPrefix"); got != FormatArXivRaw {
+		t.Errorf("metadataPrefix = %q, want %q", got, FormatArXivRaw)
+	}
+	if len(pages) != 1 || len(pages[0]) != 1 {
+		t.Fatalf("got pages %v, want one entry", pages)
+	}
+
+	// arXivRaw carries versions, so the ID names the latest one
+	entry := pages[0][0]
+	if entry.ID != "http://arxiv.org/abs/0704.0002v2" {
+		t.Errorf("ID = %q", entry.ID)
+	}
+	if entry.Published != "2007-03-31T02:26:18Z" || entry.Updated != "2008-12-13T17:26:00Z" {
+		t.Errorf("Published, Updated = %q, %q", entry.Published, entry.Updated)
+	}
+	if len(entry.Authors) != 2 || entry.Authors[0].Name != "Ileana Streinu" || entry.Authors[1].Name != "Louis Theran" {
+		t.Errorf("Authors = %v", entry.Authors)
+	}
+}
+
+func TestListRecordsWithNoRecordsMatch(t *testing.T) {
+	client, _ := newTestClient(t)
+	params := Params{
+		From:  time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+		Until: time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC),
+	}
+
+	if pages := listAll(t, client, params); len(pages) != 0 {
+		t.Errorf("got pages %v, want none", pages)
+	}
+}
+
+func TestListRecordsRejectsUnknownFormat(t *testing.T) {
+	client, _ := newTestClient(t)
+	params := testRange
+	params.MetadataPrefix = "oai_dc"
+
+	err := client.ListRecords(context.Background(), params, func([]structure.SimplifiedEntry) error { return nil })
+	if err == nil {
+		t.Error("ListRecords accepted an unsupported metadata format")
+	}
+}
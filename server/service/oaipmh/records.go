@@ -0,0 +1,164 @@
+package oaipmh
+
+import (
+	"RAGScholar/service/structure"
+	"strings"
+	"time"
+)
+
+type oaiResponse struct {
+	Error       *oaiError `xml:"error"`
+	ListRecords struct {
+		Records         []record `xml:"record"`
+		ResumptionToken struct {
+			Value string `xml:",chardata"`
+		} `xml:"resumptionToken"`
+	} `xml:"ListRecords"`
+}
+
+type oaiError struct {
+	Code    string `xml:"code,attr"`
+	Message string `xml:",chardata"`
+}
+
+type record struct {
+	Header struct {
+		Identifier string `xml:"identifier"`
+		Datestamp  string `xml:"datestamp"`
+		Status     string `xml:"status,attr"`
+	} `xml:"header"`
+	Metadata struct {
+		ArXiv    *arXivMetadata    `xml:"http://arxiv.org/OAI/arXiv/ arXiv"`
+		ArXivRaw *arXivRawMetadata `xml:"http://arxiv.org/OAI/arXivRaw/ arXivRaw"`
+	} `xml:"metadata"`
+}
+
+type arXivMetadata struct {
+	ID      string `xml:"id"`
+	Created string `xml:"created"`
+	Updated string `xml:"updated"`
+	Authors []struct {
+		Keyname   string `xml:"keyname"`
+		Forenames string `xml:"forenames"`
+		Suffix    string `xml:"suffix"`
+	} `xml:"authors>author"`
+	Title      string `xml:"title"`
+	Categories string `xml:"categories"`
+	Comments   string `xml:"comments"`
+	JournalRef string `xml:"journal-ref"`
+	DOI        string `xml:"doi"`
+	Abstract   string `xml:"abstract"`
+}
+
+type arXivRawMetadata struct {
+	ID       string `xml:"id"`
+	Versions []struct {
+		Version string `xml:"version,attr"`
+		Date    string `xml:"date"`
+	} `xml:"version"`
+	Title      string `xml:"title"`
+	Authors    string `xml:"authors"`
+	Categories string `xml:"categories"`
+	Comments   string `xml:"comments"`
+	JournalRef string `xml:"journal-ref"`
+	DOI        string `xml:"doi"`
+	Abstract   string `xml:"abstract"`
+}
+
+func (r record) toEntry() (structure.SimplifiedEntry, bool) {
+	switch {
+	case r.Metadata.ArXiv != nil:
+		return r.Metadata.ArXiv.toEntry(), true
+	case r.Metadata.ArXivRaw != nil:
+		return r.Metadata.ArXivRaw.toEntry(), true
+	default:
+		return structure.SimplifiedEntry{}, false
+	}
+}
+
+func (m *arXivMetadata) toEntry() structure.SimplifiedEntry {
+	entry := newEntry(m.ID, "", m.Title, m.Abstract, m.Categories, m.Comments, m.JournalRef, m.DOI)
+	entry.Published = dateToRFC3339(m.Created)
+	entry.Updated = dateToRFC3339(m.Updated)
+	if entry.Updated == "" {
+		entry.Updated = entry.Published
+	}
+
+	for _, author := range m.Authors {
+		name := strings.TrimSpace(author.Forenames + " " + author.Keyname + " " + author.Suffix)
+		if name != "" {
+			entry.Authors = append(entry.Authors, structure.Author{Name: name})
+		}
+	}
+
+	return entry
+}
+
+func (m *arXivRawMetadata) toEntry() structure.SimplifiedEntry {
+	version := ""
+	if len(m.Versions) > 0 {
+		version = m.Versions[len(m.Versions)-1].Version
+	}
+
+	entry := newEntry(m.ID, version, m.Title, m.Abstract, m.Categories, m.Comments, m.JournalRef, m.DOI)
+	if len(m.Versions) > 0 {
+		entry.Published = rfc1123ToRFC3339(m.Versions[0].Date)
+		entry.Updated = rfc1123ToRFC3339(m.Versions[len(m.Versions)-1].Date)
+	}
+
+	for _, name := range strings.Split(collapse(m.Authors), ",") {
+		for _, part := range strings.Split(name, " and ") {
+			if part = strings.TrimSpace(part); part != "" {
+				entry.Authors = append(entry.Authors, structure.Author{Name: part})
+			}
+		}
+	}
+
+	return entry
+}
+
+// newEntry fills the fields both metadata formats share, building the same
+// abs and PDF links the Atom API returns
+func newEntry(id, version, title, abstract, categories, comments, journalRef, doi string) structure.SimplifiedEntry {
+	id = strings.TrimSpace(id)
+	entry := structure.SimplifiedEntry{
+		ID:         "http://arxiv.org/abs/" + id + version,
+		Title:      collapse(title),
+		Summary:    strings.TrimSpace(abstract),
+		Comment:    collapse(comments),
+		JournalRef: collapse(journalRef),
+		DOI:        strings.TrimSpace(doi),
+		Links: []structure.Link{
+			{Href: "http://arxiv.org/abs/" + id + version, Rel: "alternate", Type: "text/html"},
+			{Href: "http://arxiv.org/pdf/" + id + version, Rel: "related", Type: "application/pdf"},
+		},
+	}
+
+	// arXiv lists the primary category first
+	entry.SetCategories("", strings.Fields(categories))
+
+	return entry
+}
+
+func collapse(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func dateToRFC3339(date string) string {
+	t, err := time.Parse(dateLayout, strings.TrimSpace(date))
+	if err != nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// arXivRaw version dates look like "Mon, 2 Apr 2007 19:18:42 GMT"
+const versionDateLayout = "Mon, 2 Jan 2006 15:04:05 MST"
+
+func rfc1123ToRFC3339(date string) string {
+	t, err := time.Parse(versionDateLayout, strings.TrimSpace(date))
+	if err != nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,157 @@
+package paper
+
+import (
+	"RAGScholar/arxiv"
+	"RAGScholar/domain"
+	"RAGScholar/payload"
+	"RAGScholar/service/structure"
+	"RAGScholar/vectorstore"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Feed modes for the home page
+const (
+	FeedRandom   = "random"
+	FeedFresh    = "fresh"
+	FeedCategory = "category"
+)
+
+var ErrInvalidFeed = errors.New("invalid feed")
+
+// FreshPoolSize is how many of the newest papers the fresh feed samples from
+const FreshPoolSize = 200
+
+// FeedOptions selects what the home feed shows. Seed makes a page
+// reproducible for as long as the collection doesn't change; without one
+// every request gets a different sample.
+type FeedOptions struct {
+	Mode       string
+	Categories []string
+	Seed       *uint64
+	Limit      uint64
+}
+
+// FetchFeed samples papers for the home page. Random mode samples the whole
+// collection, category mode samples within Categories, and fresh mode
+// samples among the FreshPoolSize most recently published papers. Random
+// and fresh are narrowed by Categories too when they are given.
+// vectorSize is the dimension of the collection's vectors.
+func FetchFeed(ctx context.Context, store vectorstore.VectorStore, collectionName string, vectorSize int, opts FeedOptions) ([]structure.SimplifiedEntry, error) {
+	var filter *qdrant.Filter
+	if len(opts.Categories) > 0 {
+		filter = &qdrant.Filter{Must: []*qdrant.Condition{categoryCondition(opts.Categories)}}
+	}
+
+	switch opts.Mode {
+	case FeedRandom, "":
+		return sample(ctx, store, collectionName, filter, vectorSize, opts.Seed, opts.Limit)
+	case FeedCategory:
+		if filter == nil {
+			return nil, fmt.Errorf("%w: the %s feed needs at least one category", ErrInvalidFeed, FeedCategory)
+		}
+		return sample(ctx, store, collectionName, filter, vectorSize, opts.Seed, opts.Limit)
+	case FeedFresh:
+		return fresh(ctx, store, collectionName, filter, opts.Seed, opts.Limit)
+	}
+	return nil, fmt.Errorf("%w: unknown mode %q", ErrInvalidFeed, opts.Mode)
+}
+
+// sample draws papers at random. Qdrant's random sample query is uniform but
+// can't be seeded, so a seeded sample instead takes the papers nearest to a
+// random direction drawn from the seed.
+func sample(ctx context.Context, store vectorstore.VectorStore, collectionName string, filter *qdrant.Filter,
+	vectorSize int, seed *uint64, limit uint64) ([]structure.SimplifiedEntry, error) {
+
+	query := qdrant.NewQuerySample(qdrant.Sample_Random)
+	if seed != nil {
+		rng := rand.New(rand.NewPCG(*seed, 0))
+		vector := make([]float32, vectorSize)
+		for i := range vector {
+			vector[i] = float32(rng.NormFloat64())
+		}
+		query = qdrant.NewQueryDense(vector)
+	}
+
+	points, err := store.Query(ctx, &qdrant.QueryPoints{
+		CollectionName: collectionName,
+		Query:          query,
+		Filter:         filter,
+		Limit:          &limit,
+		WithPayload:    qdrant.NewWithPayload(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sample papers: %w", err)
+	}
+
+	papers := make([]structure.SimplifiedEntry, 0, len(points))
+	for _, point := range points {
+		if paper, ok := decode(point.Payload); ok {
+			papers = append(papers, paper)
+		}
+	}
+	return papers, nil
+}
+
+// fresh shuffles the newest papers and returns the first limit of them
+func fresh(ctx context.Context, store vectorstore.VectorStore, collectionName string, filter *qdrant.Filter,
+	seed *uint64, limit uint64) ([]structure.SimplifiedEntry, error) {
+
+	poolSize := uint64(FreshPoolSize)
+	points, err := store.Query(ctx, &qdrant.QueryPoints{
+		CollectionName: collectionName,
+		Query: qdrant.NewQueryOrderBy(&qdrant.OrderBy{
+			Key:       payload.FieldPublishedAt,
+			Direction: qdrant.Direction_Desc.Enum(),
+		}),
+		Filter:      filter,
+		Limit:       &poolSize,
+		WithPayload: qdrant.NewWithPayload(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch recent papers: %w", err)
+	}
+
+	papers := make([]structure.SimplifiedEntry, 0, len(points))
+	for _, point := range points {
+		if paper, ok := decode(point.Payload); ok {
+			papers = append(papers, paper)
+		}
+	}
+
+	shuffle := rand.Shuffle
+	if seed != nil {
+		shuffle = rand.New(rand.NewPCG(*seed, 0)).Shuffle
+	}
+	shuffle(len(papers), func(i, j int) {
+		papers[i], papers[j] = papers[j], papers[i]
+	})
+
+	if uint64(len(papers)) > limit {
+		papers = papers[:limit]
+	}
+	return papers, nil
+}
+
+// categoryCondition matches papers in any of categories
+func categoryCondition(categories []string) *qdrant.Condition {
+	normalized := make([]string, len(categories))
+	for i, category := range categories {
+		normalized[i] = arxiv.NormalizeCategory(category)
+	}
+	return qdrant.NewMatchKeywords(payload.FieldCategories, normalized...)
+}
+
+func decode(fields map[string]*qdrant.Value) (structure.SimplifiedEntry, bool) {
+	paper, err := domain.DecodePayload(fields)
+	if err != nil {
+		log.Printf("Skipping paper with unreadable payload: %v", err)
+		return paper, false
+	}
+	return paper, true
+}
@@ -0,0 +1,171 @@
+package paper
+
+import (
+	"RAGScholar/arxiv"
+	"RAGScholar/domain"
+	"RAGScholar/embedding"
+	"RAGScholar/service/structure"
+	"RAGScholar/vectorstore"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+const feedDimension = 16
+
+// storeFeed stores count papers, published a day apart from 2024-01-01,
+// alternating between cs.LG and hep-th
+func storeFeed(t *testing.T, store vectorstore.VectorStore, count int) {
+	t.Helper()
+	ctx := context.Background()
+	embedder := embedding.NewLocal(feedDimension)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < count; i++ {
+		entry := domain.Entry{
+			ID:        fmt.Sprintf("http://arxiv.org/abs/2401.%05dv1", i+1),
+			Title:     fmt.Sprintf("Paper %d", i+1),
+			Published: start.AddDate(0, 0, i).Format(time.RFC3339),
+		}
+		category := []string{"cs.LG", "hep-th"}[i%2]
+		entry.SetCategories(category, []string{category})
+		fields, err := domain.EncodePayload(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vector, err := embedder.Embed(ctx, entry.Title)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = store.Upsert(ctx, &qdrant.UpsertPoints{
+			CollectionName: "papers",
+			Points: []*qdrant.PointStruct{{
+				Id:      qdrant.NewIDUUID(arxiv.PointID(entry.ID)),
+				Vectors: qdrant.NewVectorsDense(vector),
+				Payload: fields,
+			}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func feedIDs(papers []structure.SimplifiedEntry) []string {
+	result := make([]string, len(papers))
+	for i, paper := range papers {
+		result[i] = paper.ID
+	}
+	return result
+}
+
+// recordingStore keeps the last query it served
+type recordingStore struct {
+	vectorstore.VectorStore
+	query *qdrant.Query
+}
+
+func (s *recordingStore) Query(ctx context.Context, request *qdrant.QueryPoints) ([]*qdrant.ScoredPoint, error) {
+	s.query = request.GetQuery()
+	return s.VectorStore.Query(ctx, request)
+}
+
+func TestFetchFeedSeeded(t *testing.T) {
+	store := vectorstore.NewMemory()
+	storeFeed(t, store, 30)
+
+	for _, mode := range []string{FeedRandom, FeedFresh} {
+		fetch := func(seed uint64) []string {
+			t.Helper()
+			papers, err := FetchFeed(context.Background(), store, "papers", feedDimension, FeedOptions{Mode: mode, Seed: &seed, Limit: 5})
+			if err != nil {
+				t.Fatalf("%s feed: %v", mode, err)
+			}
+			if len(papers) != 5 {
+				t.Fatalf("%s feed returned %d papers, want 5", mode, len(papers))
+			}
+			return feedIDs(papers)
+		}
+
+		first := fetch(42)
+		if again := fetch(42); !slices.Equal(first, again) {
+			t.Errorf("%s feed with seed 42 returned %v, then %v", mode, first, again)
+		}
+		if other := fetch(43); slices.Equal(first, other) {
+			t.Errorf("%s feed returned %v for seeds 42 and 43", mode, first)
+		}
+	}
+}
+
+func TestFetchFeedUnseededSamplesUniformly(t *testing.T) {
+	store := &recordingStore{VectorStore: vectorstore.NewMemory()}
+	storeFeed(t, store, 30)
+
+	papers, err := FetchFeed(context.Background(), store, "papers", feedDimension, FeedOptions{Mode: FeedRandom, Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(papers) != 5 {
+		t.Errorf("random feed returned %d papers, want 5", len(papers))
+	}
+	if _, ok := store.query.GetVariant().(*qdrant.Query_Sample); !ok {
+		t.Errorf("unseeded feed sent query %v, want Qdrant's random sample", store.query)
+	}
+
+	seed := uint64(42)
+	if _, err := FetchFeed(context.Background(), store, "papers", feedDimension, FeedOptions{Mode: FeedRandom, Seed: &seed, Limit: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if store.query.GetNearest() == nil {
+		t.Errorf("seeded feed sent query %v, want a nearest query", store.query)
+	}
+}
+
+func TestFetchFeedFreshSamplesNewestPapers(t *testing.T) {
+	store := vectorstore.NewMemory()
+	storeFeed(t, store, FreshPoolSize+10)
+
+	// The 10 oldest papers fall outside the pool
+	oldest := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, 10)
+	for seed := uint64(0); seed < 10; seed++ {
+		papers, err := FetchFeed(context.Background(), store, "papers", feedDimension, FeedOptions{Mode: FeedFresh, Seed: &seed, Limit: 50})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, paper := range papers {
+			if published, _ := time.Parse(time.RFC3339, paper.Published); published.Before(oldest) {
+				t.Fatalf("fresh feed returned %s, published %s", paper.ID, paper.Published)
+			}
+		}
+	}
+}
+
+func TestFetchFeedCategories(t *testing.T) {
+	store := vectorstore.NewMemory()
+	storeFeed(t, store, 30)
+
+	for _, mode := range []string{FeedRandom, FeedFresh, FeedCategory} {
+		papers, err := FetchFeed(context.Background(), store, "papers", feedDimension, FeedOptions{Mode: mode, Categories: []string{"HEP-TH"}, Limit: 20})
+		if err != nil {
+			t.Fatalf("%s feed: %v", mode, err)
+		}
+		if len(papers) != 15 {
+			t.Errorf("%s feed returned %d papers, want the 15 in hep-th", mode, len(papers))
+		}
+		for _, paper := range papers {
+			if !slices.Equal(paper.Categories, []string{"hep-th"}) {
+				t.Errorf("%s feed returned %s in %v", mode, paper.ID, paper.Categories)
+			}
+		}
+	}
+
+	for _, opts := range []FeedOptions{{Mode: FeedCategory, Limit: 5}, {Mode: "popular", Limit: 5}} {
+		if _, err := FetchFeed(context.Background(), store, "papers", feedDimension, opts); !errors.Is(err, ErrInvalidFeed) {
+			t.Errorf("%s feed error = %v, want ErrInvalidFeed", opts.Mode, err)
+		}
+	}
+}
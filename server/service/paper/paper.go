@@ -1,180 +1,81 @@
 package paper
 
 import (
+	"RAGScholar/arxiv"
+	"RAGScholar/domain"
+	"RAGScholar/payload"
 	"RAGScholar/service/structure"
+	"RAGScholar/vectorstore"
 	"context"
+	"errors"
 	"fmt"
-	"time"
 
 	"github.com/qdrant/go-client/qdrant"
-	"golang.org/x/exp/rand"
 )
 
-func FetchRandomPapers(ctx context.Context, client *qdrant.Client, collectionName string, limit uint64) ([]structure.SimplifiedEntry, error) {
-
-	points, err := client.Query(ctx, &qdrant.QueryPoints{
+var ErrPaperNotFound = errors.New("paper not found")
+
+// maxNumberMatches bounds how many papers sharing an old-style number are
+// considered when looking a paper up
+const maxNumberMatches = 16
+
+// FetchPaperByID looks a paper up by exact match on its arXiv ID, with or
+// without version suffix or abs URL prefix, or on its full stored ID. The
+// client links to papers by the last segment of their ID, so old-style IDs
+// such as "hep-th/9901001" are also found by their number alone; since
+// numbers repeat across archives, an exact match wins over those, then a
+// match on the requested version.
+func FetchPaperByID(ctx context.Context, store vectorstore.VectorStore, collectionName, paperID string) (*structure.SimplifiedEntry, error) {
+	arxivID := arxiv.NormalizeID(paperID)
+	limit := uint64(maxNumberMatches)
+	points, err := store.Query(ctx, &qdrant.QueryPoints{
 		CollectionName: collectionName,
-		Query:          nil, // No query for arbitrary points
-		Limit:          &limit,
-		WithPayload:    &qdrant.WithPayloadSelector{SelectorOptions: &qdrant.WithPayloadSelector_Enable{Enable: true}},
+		Filter: &qdrant.Filter{
+			Should: []*qdrant.Condition{
+				qdrant.NewMatchKeyword(payload.FieldArxivID, arxivID),
+				qdrant.NewMatchKeyword(payload.FieldID, paperID),
+				qdrant.NewMatchKeyword(payload.FieldArxivNumber, arxivID),
+			},
+		},
+		Limit:       &limit,
+		WithPayload: &qdrant.WithPayloadSelector{SelectorOptions: &qdrant.WithPayloadSelector_Enable{Enable: true}},
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch papers: %w", err)
-	}
-
-	var papers []structure.SimplifiedEntry
-	for _, point := range points {
-		payload := point.Payload
-		paper := structure.SimplifiedEntry{
-			ID:              getStringFromPayload(payload, "id"),
-			Updated:         getStringFromPayload(payload, "updated"),
-			Published:       getStringFromPayload(payload, "published"),
-			Title:           getStringFromPayload(payload, "title"),
-			Summary:         getStringFromPayload(payload, "summary"),
-			Authors:         getAuthorsFromPayload(payload, "authors"),
-			Comment:         getStringFromPayload(payload, "comment"),
-			Links:           getLinksFromPayload(payload, "links"),
-			PrimaryCategory: getStringFromPayload(payload, "primaryCategory"),
-			Categories:      getStringListFromPayload(payload, "categories"),
-			DOI:             getStringFromPayload(payload, "doi"),
-			JournalRef:      getStringFromPayload(payload, "journalRef"),
-		}
-		papers = append(papers, paper)
-	}
-
-	// Shuffle papers for randomness
-	seed := uint64(time.Now().UnixNano())
-	rand.Seed(seed)
-	rand.Shuffle(len(papers), func(i, j int) {
-		papers[i], papers[j] = papers[j], papers[i]
-	})
-
-	// Limit to the specified number of papers
-	if len(papers) > int(limit) {
-		papers = papers[:limit]
-	}
-
-	return papers, nil
-}
-
-func getStringFromPayload(payload map[string]*qdrant.Value, key string) string {
-	if value, ok := payload[key]; ok && value.GetStringValue() != "" {
-		return value.GetStringValue()
-	}
-	return ""
-}
-
-func getAuthorsFromPayload(payload map[string]*qdrant.Value, key string) []structure.Author {
-	var authors []structure.Author
-	if value, ok := payload[key]; ok && value.GetListValue() != nil {
-		for _, v := range value.GetListValue().Values {
-			if structVal := v.GetStructValue(); structVal != nil {
-				if nameVal, exists := structVal.Fields["name"]; exists && nameVal.GetStringValue() != "" {
-					authors = append(authors, structure.Author{Name: nameVal.GetStringValue()})
-				}
-			}
-		}
+		return nil, fmt.Errorf("failed to query Qdrant: %w", err)
 	}
-	return authors
-}
 
-// Helper function to extract list of Links from payload
-func getLinksFromPayload(payload map[string]*qdrant.Value, key string) []structure.Link {
-	var links []structure.Link
-	if value, ok := payload[key]; ok && value.GetListValue() != nil {
-		for _, v := range value.GetListValue().Values {
-			if structVal := v.GetStructValue(); structVal != nil {
-				link := structure.Link{
-					Href: getStructString(structVal, "href"),
-					Rel:  getStructString(structVal, "rel"),
-					Type: getStructString(structVal, "type"),
-				}
-				links = append(links, link)
-			}
+	version := int64(arxiv.Version(paperID))
+	best := -1
+	for i, point := range points {
+		if rank := matchRank(point.Payload, paperID, arxivID, version); best < 0 || rank > matchRank(points[best].Payload, paperID, arxivID, version) {
+			best = i
 		}
 	}
-	return links
-}
-
-func getStringListFromPayload(payload map[string]*qdrant.Value, key string) []string {
-	var strings []string
-	if value, ok := payload[key]; ok && value.GetListValue() != nil {
-		for _, v := range value.GetListValue().Values {
-			if str := v.GetStringValue(); str != "" {
-				strings = append(strings, str)
-			}
-		}
+	if best > 0 {
+		points[0] = points[best]
 	}
-	return strings
-}
 
-func getStructString(structVal *qdrant.Struct, key string) string {
-	if val, exists := structVal.Fields[key]; exists && val.GetStringValue() != "" {
-		return val.GetStringValue()
+	if len(points) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrPaperNotFound, paperID)
 	}
-	return ""
-}
 
-type Paper struct {
-	ID              string             `json:"id"`
-	Updated         string             `json:"updated"`
-	Published       string             `json:"published"`
-	Title           string             `json:"title"`
-	Summary         string             `json:"summary"`
-	Authors         []structure.Author `json:"authors"`
-	Comment         string             `json:"comment"`
-	Links           []structure.Link   `json:"links"`
-	PrimaryCategory string             `json:"primaryCategory"`
-	Categories      []string           `json:"categories"`
-	DOI             string             `json:"doi"`
-	JournalRef      string             `json:"journalRef"`
-}
-
-func FetchPaperByID(ctx context.Context, client *qdrant.Client, collectionName, paperID string) (*Paper, error) {
-	points, err := client.Query(ctx, &qdrant.QueryPoints{
-		CollectionName: collectionName,
-		Filter: &qdrant.Filter{
-			Must: []*qdrant.Condition{
-				{
-					ConditionOneOf: &qdrant.Condition_Field{
-						Field: &qdrant.FieldCondition{
-							Key: "id",
-							Match: &qdrant.Match{
-								MatchValue: &qdrant.Match_Text{
-									Text: paperID,
-								},
-							},
-						},
-					},
-				},
-			},
-		},
-		WithPayload: &qdrant.WithPayloadSelector{SelectorOptions: &qdrant.WithPayloadSelector_Enable{Enable: true}},
-	})
+	paper, err := domain.DecodePayload(points[0].Payload)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query Qdrant: %v", err)
+		return nil, fmt.Errorf("failed to decode paper %s: %w", paperID, err)
 	}
 
-	if len(points) == 0 {
-		return nil, fmt.Errorf("paper with ID %s not found", paperID)
-	}
+	return &paper, nil
+}
 
-	payload := points[0].Payload
-	paper := &Paper{
-		ID:              getStringFromPayload(payload, "id"),
-		Updated:         getStringFromPayload(payload, "updated"),
-		Published:       getStringFromPayload(payload, "published"),
-		Title:           getStringFromPayload(payload, "title"),
-		Summary:         getStringFromPayload(payload, "summary"),
-		Authors:         getAuthorsFromPayload(payload, "authors"),
-		Comment:         getStringFromPayload(payload, "comment"),
-		Links:           getLinksFromPayload(payload, "links"),
-		PrimaryCategory: getStringFromPayload(payload, "primaryCategory"),
-		Categories:      getStringListFromPayload(payload, "categories"),
-		DOI:             getStringFromPayload(payload, "doi"),
-		JournalRef:      getStringFromPayload(payload, "journalRef"),
+// matchRank orders the papers a lookup matched: exact ID matches first, then
+// number matches of the requested version
+func matchRank(fields map[string]*qdrant.Value, paperID string, arxivID string, version int64) int {
+	switch {
+	case fields[payload.FieldArxivID].GetStringValue() == arxivID, fields[payload.FieldID].GetStringValue() == paperID:
+		return 2
+	case version > 0 && fields[payload.FieldVersion].GetIntegerValue() == version:
+		return 1
+	default:
+		return 0
 	}
-
-	return paper, nil
 }
-
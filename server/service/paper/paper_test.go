@@ -0,0 +1,91 @@
+package paper
+
+import (
+	"RAGScholar/arxiv"
+	"RAGScholar/domain"
+	"RAGScholar/vectorstore"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+func storePapers(t *testing.T, store vectorstore.VectorStore, ids ...string) {
+	t.Helper()
+	for _, id := range ids {
+		fields, err := domain.EncodePayload(domain.Entry{ID: id, Title: id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = store.Upsert(context.Background(), &qdrant.UpsertPoints{
+			CollectionName: "papers",
+			Points: []*qdrant.PointStruct{{
+				Id:      qdrant.NewIDUUID(arxiv.PointID(id)),
+				Vectors: qdrant.NewVectorsDense([]float32{1, 0}),
+				Payload: fields,
+			}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFetchPaperByID(t *testing.T) {
+	store := vectorstore.NewMemory()
+	storePapers(t, store,
+		"http://arxiv.org/abs/hep-th/9901001v1",
+		"http://arxiv.org/abs/math/9901001v2",
+		"http://arxiv.org/abs/2401.01234v3",
+	)
+
+	tests := []struct {
+		paperID string
+		want    string
+	}{
+		{"2401.01234", "http://arxiv.org/abs/2401.01234v3"},
+		{"2401.01234v1", "http://arxiv.org/abs/2401.01234v3"},
+		{"http://arxiv.org/abs/2401.01234v3", "http://arxiv.org/abs/2401.01234v3"},
+		{"hep-th/9901001", "http://arxiv.org/abs/hep-th/9901001v1"},
+		{"math/9901001v2", "http://arxiv.org/abs/math/9901001v2"},
+		// The client links to old-style papers by their last path segment
+		{"9901001v1", "http://arxiv.org/abs/hep-th/9901001v1"},
+		{"9901001v2", "http://arxiv.org/abs/math/9901001v2"},
+	}
+	for _, test := range tests {
+		paper, err := FetchPaperByID(context.Background(), store, "papers", test.paperID)
+		if err != nil {
+			t.Errorf("FetchPaperByID(%q): %v", test.paperID, err)
+			continue
+		}
+		if paper.ID != test.want {
+			t.Errorf("FetchPaperByID(%q) = %s, want %s", test.paperID, paper.ID, test.want)
+		}
+	}
+
+	if _, err := FetchPaperByID(context.Background(), store, "papers", "9901002"); !errors.Is(err, ErrPaperNotFound) {
+		t.Errorf("FetchPaperByID(missing) error = %v, want ErrPaperNotFound", err)
+	}
+}
+
+// failingStore fails every query, like an unreachable Qdrant
+type failingStore struct {
+	vectorstore.VectorStore
+	err error
+}
+
+func (s failingStore) Query(context.Context, *qdrant.QueryPoints) ([]*qdrant.ScoredPoint, error) {
+	return nil, s.err
+}
+
+func TestFetchPaperByIDWrapsStoreErrors(t *testing.T) {
+	unavailable := errors.New("connection refused")
+	_, err := FetchPaperByID(context.Background(), failingStore{err: unavailable}, "papers", "2401.01234")
+	if !errors.Is(err, unavailable) {
+		t.Errorf("error = %v, want it to wrap the store's", err)
+	}
+	if errors.Is(err, ErrPaperNotFound) {
+		t.Errorf("a failed query reported %v as not found", err)
+	}
+}
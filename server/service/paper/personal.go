@@ -0,0 +1,205 @@
+package paper
+
+import (
+	"RAGScholar/arxiv"
+	"RAGScholar/payload"
+	"RAGScholar/service/structure"
+	"RAGScholar/vectorstore"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Personalized feed tuning
+const (
+	// MaxFeedExamples caps how many of the most recently saved and dismissed
+	// papers are used as recommend examples
+	MaxFeedExamples = 20
+	// RecencyHalfLife is the age at which a paper's recency boost halves
+	RecencyHalfLife = 90 * 24 * time.Hour
+
+	// Candidates fetched per returned paper, to leave room for re-ranking
+	feedPoolFactor = 5
+	// Weight of the recency boost against similarity, both in [0, 1]
+	recencyWeight = 0.3
+	// Penalty per paper already picked from the same primary category
+	diversityPenalty = 0.15
+)
+
+var ErrNoHistory = errors.New("no saved papers to personalize from")
+
+// PersonalFeedOptions holds a user's feedback, most recent first. Saved
+// papers are positive examples and dismissed papers negative ones.
+type PersonalFeedOptions struct {
+	Saved     []string
+	Dismissed []string
+	Limit     uint64
+}
+
+// FetchPersonalFeed recommends papers like the user's saved ones and unlike
+// the dismissed ones. Qdrant scores candidates against each example with the
+// best-score strategy, so users with several interests get results for each
+// rather than for the average of them. The candidates are then re-ranked
+// with a boost for recently published papers and a penalty for piling up in
+// one category. Returns ErrNoHistory when none of the saved papers are
+// stored.
+func FetchPersonalFeed(ctx context.Context, store vectorstore.VectorStore, collectionName string, opts PersonalFeedOptions) ([]structure.SimplifiedEntry, error) {
+	savedSet := make(map[string]bool)
+	for _, id := range opts.Saved {
+		savedSet[arxiv.NormalizeID(id)] = true
+	}
+	var dismissed []string
+	for _, id := range opts.Dismissed {
+		if !savedSet[arxiv.NormalizeID(id)] {
+			dismissed = append(dismissed, id)
+		}
+	}
+
+	positive, err := storedExamples(ctx, store, collectionName, firstN(opts.Saved, MaxFeedExamples))
+	if err != nil {
+		return nil, err
+	}
+	if len(positive) == 0 {
+		return nil, ErrNoHistory
+	}
+	negative, err := storedExamples(ctx, store, collectionName, firstN(dismissed, MaxFeedExamples))
+	if err != nil {
+		return nil, err
+	}
+
+	// Nothing the user has already saved or dismissed, in any version
+	seen := append(append([]string{}, opts.Saved...), dismissed...)
+	ids := make([]*qdrant.PointId, len(seen))
+	arxivIDs := make([]string, len(seen))
+	for i, id := range seen {
+		ids[i] = qdrant.NewIDUUID(arxiv.PointID(id))
+		arxivIDs[i] = arxiv.NormalizeID(id)
+	}
+
+	poolSize := opts.Limit * feedPoolFactor
+	points, err := store.Query(ctx, &qdrant.QueryPoints{
+		CollectionName: collectionName,
+		Query: qdrant.NewQueryRecommend(&qdrant.RecommendInput{
+			Positive: positive,
+			Negative: negative,
+			Strategy: qdrant.RecommendStrategy_BestScore.Enum(),
+		}),
+		Filter: &qdrant.Filter{
+			MustNot: []*qdrant.Condition{
+				qdrant.NewHasID(ids...),
+				qdrant.NewMatchKeywords(payload.FieldArxivID, arxivIDs...),
+			},
+		},
+		Limit:       &poolSize,
+		WithPayload: qdrant.NewWithPayload(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recommendations: %w", err)
+	}
+
+	candidates := make([]structure.SimplifiedEntry, 0, len(points))
+	for _, point := range points {
+		if paper, ok := decode(point.Payload); ok {
+			paper.SemanticScore = point.Score
+			candidates = append(candidates, paper)
+		}
+	}
+
+	return rerank(candidates, time.Now(), int(opts.Limit)), nil
+}
+
+// storedExamples turns paper IDs into recommend examples, skipping papers
+// that are no longer stored since Qdrant rejects missing example points
+func storedExamples(ctx context.Context, store vectorstore.VectorStore, collectionName string, paperIDs []string) ([]*qdrant.VectorInput, error) {
+	if len(paperIDs) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]*qdrant.PointId, len(paperIDs))
+	for i, id := range paperIDs {
+		ids[i] = qdrant.NewIDUUID(arxiv.PointID(id))
+	}
+	points, err := store.Get(ctx, &qdrant.GetPoints{
+		CollectionName: collectionName,
+		Ids:            ids,
+		WithPayload:    qdrant.NewWithPayload(false),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up papers: %w", err)
+	}
+
+	examples := make([]*qdrant.VectorInput, len(points))
+	for i, point := range points {
+		examples[i] = qdrant.NewVectorInputID(point.Id)
+	}
+	return examples, nil
+}
+
+// rerank picks limit papers greedily by similarity, scaled to [0, 1] over
+// the candidates, plus the recency boost, minus the diversity penalty for
+// each earlier pick in the same primary category
+func rerank(candidates []structure.SimplifiedEntry, now time.Time, limit int) []structure.SimplifiedEntry {
+	if len(candidates) == 0 {
+		return candidates
+	}
+
+	low, high := candidates[0].SemanticScore, candidates[0].SemanticScore
+	for _, paper := range candidates {
+		low = min(low, paper.SemanticScore)
+		high = max(high, paper.SemanticScore)
+	}
+
+	base := make([]float64, len(candidates))
+	for i, paper := range candidates {
+		similarity := 1.0
+		if high > low {
+			similarity = float64(paper.SemanticScore-low) / float64(high-low)
+		}
+		base[i] = similarity + recencyWeight*recency(paper.Published, now)
+	}
+
+	picked := make([]structure.SimplifiedEntry, 0, min(limit, len(candidates)))
+	used := make([]bool, len(candidates))
+	perCategory := make(map[string]int)
+	for len(picked) < limit && len(picked) < len(candidates) {
+		best, bestScore := -1, math.Inf(-1)
+		for i, paper := range candidates {
+			if used[i] {
+				continue
+			}
+			score := base[i] - diversityPenalty*float64(perCategory[paper.PrimaryCategory])
+			if score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+
+		used[best] = true
+		perCategory[candidates[best].PrimaryCategory]++
+		paper := candidates[best]
+		paper.Score = float32(bestScore)
+		picked = append(picked, paper)
+	}
+	return picked
+}
+
+// recency is 1 for a paper published now, halving every RecencyHalfLife, and
+// 0 when the date is unknown
+func recency(published string, now time.Time) float64 {
+	t, err := time.Parse(time.RFC3339, published)
+	if err != nil {
+		return 0
+	}
+	age := max(now.Sub(t), 0)
+	return math.Pow(0.5, float64(age)/float64(RecencyHalfLife))
+}
+
+func firstN(ids []string, n int) []string {
+	if len(ids) > n {
+		return ids[:n]
+	}
+	return ids
+}
@@ -0,0 +1,126 @@
+package paper
+
+import (
+	"RAGScholar/service/structure"
+	"RAGScholar/vectorstore"
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+var rerankNow = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func candidate(id, category string, score float32, age time.Duration) structure.SimplifiedEntry {
+	return structure.SimplifiedEntry{
+		ID:              id,
+		PrimaryCategory: category,
+		Published:       rerankNow.Add(-age).Format(time.RFC3339),
+		SemanticScore:   score,
+	}
+}
+
+func TestRecency(t *testing.T) {
+	tests := []struct {
+		published string
+		want      float64
+	}{
+		{rerankNow.Format(time.RFC3339), 1},
+		{rerankNow.Add(-RecencyHalfLife).Format(time.RFC3339), 0.5},
+		{rerankNow.Add(-2 * RecencyHalfLife).Format(time.RFC3339), 0.25},
+		// Clock skew doesn't boost a paper beyond a new one
+		{rerankNow.Add(time.Hour).Format(time.RFC3339), 1},
+		{"", 0},
+		{"2024-06-01", 0},
+	}
+	for _, test := range tests {
+		if got := recency(test.published, rerankNow); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("recency(%q) = %v, want %v", test.published, got, test.want)
+		}
+	}
+}
+
+func TestRerankPrefersNewerPapers(t *testing.T) {
+	candidates := []structure.SimplifiedEntry{
+		candidate("older", "cs.LG", 0.8, RecencyHalfLife),
+		candidate("newer", "hep-th", 0.8, 0),
+	}
+	picked := rerank(candidates, rerankNow, 2)
+	if got := feedIDs(picked); !slices.Equal(got, []string{"newer", "older"}) {
+		t.Fatalf("rerank = %v, want the newer paper first", got)
+	}
+	// Equal similarity counts fully, plus 0.3 of the recency boost
+	for i, want := range []float32{1.3, 1.15} {
+		if math.Abs(float64(picked[i].Score-want)) > 1e-6 {
+			t.Errorf("%s scored %v, want %v", picked[i].ID, picked[i].Score, want)
+		}
+	}
+}
+
+func TestRerankPushesDownNearDuplicates(t *testing.T) {
+	// All published now, so only similarity and category count
+	candidates := []structure.SimplifiedEntry{
+		candidate("best", "cs.LG", 1.0, 0),
+		candidate("near-duplicate", "cs.LG", 0.98, 0),
+		candidate("other topic", "hep-th", 0.9, 0),
+		candidate("unrelated", "cs.CV", 0, 0),
+	}
+	picked := rerank(candidates, rerankNow, 4)
+	want := []string{"best", "other topic", "near-duplicate", "unrelated"}
+	if got := feedIDs(picked); !slices.Equal(got, want) {
+		t.Errorf("rerank = %v, want %v", got, want)
+	}
+	// The second cs.LG paper pays one 0.15 penalty
+	if got := picked[2].Score; math.Abs(float64(got)-(0.98+0.3-0.15)) > 1e-6 {
+		t.Errorf("near-duplicate scored %v, want %v", got, 0.98+0.3-0.15)
+	}
+
+	// Without the shared category it keeps its place
+	candidates[1].PrimaryCategory = "stat.ML"
+	want = []string{"best", "near-duplicate", "other topic", "unrelated"}
+	if got := feedIDs(rerank(candidates, rerankNow, 4)); !slices.Equal(got, want) {
+		t.Errorf("rerank in distinct categories = %v, want %v", got, want)
+	}
+
+	if got := rerank(candidates, rerankNow, 2); len(got) != 2 {
+		t.Errorf("rerank with limit 2 returned %d papers", len(got))
+	}
+	if got := rerank(nil, rerankNow, 2); len(got) != 0 {
+		t.Errorf("rerank without candidates returned %v", feedIDs(got))
+	}
+}
+
+func TestFetchPersonalFeed(t *testing.T) {
+	ctx := context.Background()
+	store := vectorstore.NewMemory()
+	storeFeed(t, store, 30)
+
+	papers, err := FetchPersonalFeed(ctx, store, "papers", PersonalFeedOptions{
+		Saved:     []string{"2401.00001", "2401.00002v1"},
+		Dismissed: []string{"2401.00003", "2401.00002"},
+		Limit:     5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(papers) != 5 {
+		t.Errorf("personal feed returned %d papers, want 5", len(papers))
+	}
+	for _, paper := range papers {
+		switch paper.ID {
+		case "http://arxiv.org/abs/2401.00001v1", "http://arxiv.org/abs/2401.00002v1", "http://arxiv.org/abs/2401.00003v1":
+			t.Errorf("personal feed returned %s, which the user saved or dismissed", paper.ID)
+		}
+	}
+
+	// Users without saved papers, or whose saved papers are gone, fall back
+	// to the random feed
+	for _, saved := range [][]string{nil, {"2401.99999"}} {
+		_, err := FetchPersonalFeed(ctx, store, "papers", PersonalFeedOptions{Saved: saved, Dismissed: []string{"2401.00003"}, Limit: 5})
+		if !errors.Is(err, ErrNoHistory) {
+			t.Errorf("personal feed from saved papers %v: %v, want ErrNoHistory", saved, err)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package paper
+
+import (
+	"RAGScholar/arxiv"
+	"RAGScholar/payload"
+	"RAGScholar/service/structure"
+	"RAGScholar/vectorstore"
+	"context"
+	"fmt"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// SimilarOptions tunes a "more like this" query. Positive and Negative are
+// extra arXiv IDs whose papers the results should be like or unlike.
+type SimilarOptions struct {
+	Positive   []string
+	Negative   []string
+	Categories []string
+	Limit      uint64
+}
+
+// FetchSimilar ranks the papers closest to the stored vector of paperID
+// using Qdrant's recommend query, so nothing is embedded per request. The
+// paper itself, every version of it and the example papers are left out.
+func FetchSimilar(ctx context.Context, store vectorstore.VectorStore, collectionName, paperID string, opts SimilarOptions) ([]structure.SimplifiedEntry, error) {
+	examples := append([]string{paperID}, opts.Positive...)
+	examples = append(examples, opts.Negative...)
+
+	// Every version of a paper is stored under the same point, so the point
+	// IDs follow from the arXiv IDs
+	ids := make([]*qdrant.PointId, len(examples))
+	arxivIDs := make([]string, len(examples))
+	for i, id := range examples {
+		ids[i] = qdrant.NewIDUUID(arxiv.PointID(id))
+		arxivIDs[i] = arxiv.NormalizeID(id)
+	}
+
+	if err := checkExist(ctx, store, collectionName, examples, ids); err != nil {
+		return nil, err
+	}
+
+	recommend := &qdrant.RecommendInput{}
+	for i, id := range ids {
+		if i <= len(opts.Positive) {
+			recommend.Positive = append(recommend.Positive, qdrant.NewVectorInputID(id))
+		} else {
+			recommend.Negative = append(recommend.Negative, qdrant.NewVectorInputID(id))
+		}
+	}
+
+	filter := &qdrant.Filter{
+		MustNot: []*qdrant.Condition{
+			qdrant.NewHasID(ids...),
+			qdrant.NewMatchKeywords(payload.FieldArxivID, arxivIDs...),
+		},
+	}
+	if len(opts.Categories) > 0 {
+		filter.Must = append(filter.Must, categoryCondition(opts.Categories))
+	}
+
+	points, err := store.Query(ctx, &qdrant.QueryPoints{
+		CollectionName: collectionName,
+		Query:          qdrant.NewQueryRecommend(recommend),
+		Filter:         filter,
+		Limit:          &opts.Limit,
+		WithPayload:    qdrant.NewWithPayload(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query similar papers: %w", err)
+	}
+
+	papers := make([]structure.SimplifiedEntry, 0, len(points))
+	for _, point := range points {
+		if paper, ok := decode(point.Payload); ok {
+			paper.Score = point.Score
+			papers = append(papers, paper)
+		}
+	}
+	return papers, nil
+}
+
+// checkExist fails with ErrPaperNotFound naming the first paper that isn't
+// stored, since Qdrant rejects recommend queries on missing points
+func checkExist(ctx context.Context, store vectorstore.VectorStore, collectionName string, paperIDs []string, ids []*qdrant.PointId) error {
+	points, err := store.Get(ctx, &qdrant.GetPoints{
+		CollectionName: collectionName,
+		Ids:            ids,
+		WithPayload:    qdrant.NewWithPayload(false),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to look up papers: %w", err)
+	}
+
+	found := make(map[string]bool, len(points))
+	for _, point := range points {
+		found[point.Id.GetUuid()] = true
+	}
+	for i, id := range ids {
+		if !found[id.GetUuid()] {
+			return fmt.Errorf("%w: %s", ErrPaperNotFound, paperIDs[i])
+		}
+	}
+	return nil
+}
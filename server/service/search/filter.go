@@ -0,0 +1,151 @@
+package search
+
+import (
+	"RAGScholar/arxiv"
+	"RAGScholar/payload"
+	"RAGScholar/service/models"
+	"RAGScholar/vectorstore"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Filters restricts a search to papers matching every field that is set
+type Filters struct {
+	PrimaryCategory string
+	// Categories matches papers listed under any of the given categories
+	Categories []string
+	// Archive and Group match any category under an archive (cs, cond-mat)
+	// or a top-level group (physics)
+	Archive string
+	Group   string
+	// Author matches author names containing every word of it
+	Author string
+	// PublishedFrom and PublishedUntil bound the publication date, inclusive
+	PublishedFrom  time.Time
+	PublishedUntil time.Time
+	// HasDOI keeps only papers with (true) or without (false) a DOI
+	HasDOI *bool
+}
+
+// QdrantFilter translates the filters into Qdrant conditions, returning nil
+// when no filter is set
+func (f Filters) QdrantFilter() *qdrant.Filter {
+	var must, mustNot []*qdrant.Condition
+
+	if f.PrimaryCategory != "" {
+		must = append(must, qdrant.NewMatchKeyword(payload.FieldPrimaryCategory, arxiv.NormalizeCategory(f.PrimaryCategory)))
+	}
+
+	if categories := arxiv.NormalizeCategories(f.Categories); len(categories) > 0 {
+		must = append(must, qdrant.NewMatchKeywords(payload.FieldCategories, categories...))
+	}
+
+	if f.Archive != "" {
+		must = append(must, qdrant.NewMatchKeyword(payload.FieldArchives, f.Archive))
+	}
+
+	if f.Group != "" {
+		must = append(must, qdrant.NewMatchKeyword(payload.FieldGroups, f.Group))
+	}
+
+	if author := strings.TrimSpace(f.Author); author != "" {
+		must = append(must, qdrant.NewMatchText(payload.FieldAuthorNameText, author))
+	}
+
+	if !f.PublishedFrom.IsZero() || !f.PublishedUntil.IsZero() {
+		dateRange := &qdrant.DatetimeRange{}
+		if !f.PublishedFrom.IsZero() {
+			dateRange.Gte = timestamppb.New(f.PublishedFrom)
+		}
+		if !f.PublishedUntil.IsZero() {
+			dateRange.Lte = timestamppb.New(f.PublishedUntil)
+		}
+		must = append(must, qdrant.NewDatetimeRange(payload.FieldPublished, dateRange))
+	}
+
+	if f.HasDOI != nil {
+		// Papers without a DOI store it as an empty string or not at all
+		noDOI := []*qdrant.Condition{qdrant.NewIsEmpty(payload.FieldDOI), qdrant.NewMatchKeyword(payload.FieldDOI, "")}
+		if *f.HasDOI {
+			mustNot = append(mustNot, noDOI...)
+		} else {
+			must = append(must, qdrant.NewFilterAsCondition(&qdrant.Filter{Should: noDOI}))
+		}
+	}
+
+	if len(must) == 0 && len(mustNot) == 0 {
+		return nil
+	}
+
+	return &qdrant.Filter{Must: must, MustNot: mustNot}
+}
+
+const facetLimit = 50
+
+// FacetCounts counts the papers matching filter per category, archive and
+// publication year. Categories and archives are ordered by count and carry
+// their taxonomy names; years are ordered newest first.
+func FacetCounts(ctx context.Context, store vectorstore.VectorStore, collectionName string, filter *qdrant.Filter) (models.Facets, error) {
+	var facets models.Facets
+
+	categories, err := facet(ctx, store, collectionName, payload.FieldCategories, filter)
+	if err != nil {
+		return facets, err
+	}
+	for i := range categories {
+		categories[i].Label = arxiv.CategoryName(categories[i].Value)
+	}
+	facets.Categories = categories
+
+	archives, err := facet(ctx, store, collectionName, payload.FieldArchives, filter)
+	if err != nil {
+		return facets, err
+	}
+	for i := range archives {
+		category, _ := arxiv.LookupCategory(archives[i].Value)
+		archives[i].Label = category.ArchiveName
+	}
+	facets.Archives = archives
+
+	years, err := facet(ctx, store, collectionName, payload.FieldPublishedYear, filter)
+	if err != nil {
+		return facets, err
+	}
+	sort.Slice(years, func(i, j int) bool {
+		return years[i].Value > years[j].Value
+	})
+	facets.Years = years
+
+	return facets, nil
+}
+
+func facet(ctx context.Context, store vectorstore.VectorStore, collectionName string, key string, filter *qdrant.Filter) ([]models.FacetCount, error) {
+	limit := uint64(facetLimit)
+	hits, err := store.Facet(ctx, &qdrant.FacetCounts{
+		CollectionName: collectionName,
+		Key:            key,
+		Filter:         filter,
+		Limit:          &limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count %s facet: %w", key, err)
+	}
+
+	counts := make([]models.FacetCount, 0, len(hits))
+	for _, hit := range hits {
+		value := hit.GetValue().GetStringValue()
+		if _, ok := hit.GetValue().GetVariant().(*qdrant.FacetValue_IntegerValue); ok {
+			value = strconv.FormatInt(hit.GetValue().GetIntegerValue(), 10)
+		}
+		counts = append(counts, models.FacetCount{Value: value, Count: hit.GetCount()})
+	}
+
+	return counts, nil
+}
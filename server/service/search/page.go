@@ -0,0 +1,139 @@
+package search
+
+import (
+	"RAGScholar/embedding"
+	"RAGScholar/service/lexical"
+	"RAGScholar/service/structure"
+	"RAGScholar/vectorstore"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// MaxResultOffset bounds how deep cursor pagination goes into a ranked
+// search, since every page re-runs the fusion over all earlier results
+const MaxResultOffset = 500
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursor is the position of the next page. Ranked searches page by offset
+// into the fused ranking; browsing without a query pages by point ID.
+type cursor struct {
+	Offset uint64 `json:"o,omitempty"`
+	After  string `json:"a,omitempty"`
+}
+
+func (c cursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(token string) (cursor, error) {
+	var c cursor
+	if token == "" {
+		return c, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, ErrInvalidCursor
+	}
+	// Forged cursors must not reach Qdrant, which rejects them with an error
+	// that looks like its own failure
+	if c.Offset > MaxResultOffset {
+		return c, ErrInvalidCursor
+	}
+	if c.After != "" {
+		if _, err := uuid.Parse(c.After); err != nil {
+			return c, ErrInvalidCursor
+		}
+	}
+
+	return c, nil
+}
+
+// Page is one page of search results; NextCursor is empty on the last page
+type Page struct {
+	Papers     []structure.SimplifiedEntry
+	NextCursor string
+}
+
+// Search returns the page of papers matching filters that starts at the
+// cursor. With query text, papers are ranked by hybrid search; without it,
+// every matching paper is listed in storage order.
+func Search(ctx context.Context, store vectorstore.VectorStore, embedder embedding.Embedder, index *lexical.Index,
+	collectionName string, chunkCollectionName string, filters Filters, queryText string, cursorToken string, limit uint64) (*Page, error) {
+
+	position, err := decodeCursor(cursorToken)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := filters.QdrantFilter()
+
+	if strings.TrimSpace(queryText) == "" {
+		return browse(ctx, store, collectionName, filter, position, limit)
+	}
+
+	// One extra result tells whether there is a next page
+	papers, err := HybridSearch(ctx, store, embedder, index, collectionName, chunkCollectionName, filter, queryText, position.Offset+limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &Page{}
+	if uint64(len(papers)) > position.Offset {
+		papers = papers[position.Offset:]
+		if uint64(len(papers)) > limit {
+			papers = papers[:limit]
+			if next := position.Offset + limit; next <= MaxResultOffset {
+				page.NextCursor = cursor{Offset: next}.encode()
+			}
+		}
+		page.Papers = papers
+	}
+
+	return page, nil
+}
+
+func browse(ctx context.Context, store vectorstore.VectorStore, collectionName string, filter *qdrant.Filter,
+	position cursor, limit uint64) (*Page, error) {
+
+	var offset *qdrant.PointId
+	if position.After != "" {
+		offset = qdrant.NewIDUUID(position.After)
+	}
+
+	pageSize := uint32(limit)
+	points, next, err := store.Scroll(ctx, &qdrant.ScrollPoints{
+		CollectionName: collectionName,
+		Filter:         filter,
+		Offset:         offset,
+		Limit:          &pageSize,
+		WithPayload:    qdrant.NewWithPayload(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list papers: %w", err)
+	}
+
+	page := &Page{}
+	for _, point := range points {
+		if paper, ok := entryFromPayload(point.Payload); ok {
+			page.Papers = append(page.Papers, paper)
+		}
+	}
+	if next != nil {
+		page.NextCursor = cursor{After: next.GetUuid()}.encode()
+	}
+
+	return page, nil
+}
@@ -0,0 +1,38 @@
+package search
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestDecodeCursor(t *testing.T) {
+	valid := []cursor{
+		{},
+		{Offset: 20},
+		{Offset: MaxResultOffset},
+		{After: "0b6a4cf5-2c4b-5f1a-9d2e-6a0c3b1f7e11"},
+	}
+	for _, want := range valid {
+		got, err := decodeCursor(want.encode())
+		if err != nil || got != want {
+			t.Errorf("decodeCursor(%+v) = %+v, %v", want, got, err)
+		}
+	}
+
+	invalid := map[string]string{
+		"not base64":       "%%%",
+		"not JSON":         base64.RawURLEncoding.EncodeToString([]byte("nope")),
+		"offset too large": cursor{Offset: MaxResultOffset + 1}.encode(),
+		"after not a UUID": cursor{After: "'; drop table papers"}.encode(),
+	}
+	for name, token := range invalid {
+		if _, err := decodeCursor(token); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: err = %v, want ErrInvalidCursor", name, err)
+		}
+	}
+
+	if got, err := decodeCursor(""); err != nil || got != (cursor{}) {
+		t.Errorf("decodeCursor(\"\") = %+v, %v, want the first page", got, err)
+	}
+}
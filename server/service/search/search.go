@@ -1,90 +1,128 @@
 package search
 
 import (
+	"RAGScholar/arxiv"
+	"RAGScholar/domain"
+	"RAGScholar/embedding"
+	"RAGScholar/payload"
+	"RAGScholar/service/lexical"
 	"RAGScholar/service/structure"
+	"RAGScholar/vectorstore"
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"sort"
 	"strings"
 
-	"github.com/google/generative-ai-go/genai"
 	"github.com/qdrant/go-client/qdrant"
-	"golang.org/x/time/rate"
 )
 
-func GenerateEmbedding(client *genai.Client, model *genai.EmbeddingModel, text string, limiter *rate.Limiter) ([]float32, error) {
-	ctx := context.Background()
+// ErrEmptyQuery is returned when there is no query text to embed
+var ErrEmptyQuery = errors.New("query text is empty")
 
-	if err := limiter.Wait(ctx); err != nil {
-		return nil, err
+// SimilaritySearch embeds the query and returns the closest papers, matching
+// both paper summaries and full-text chunks. A paper matched through several
+// chunks is returned once, with its best score. A nil filter matches every
+// paper.
+func SimilaritySearch(ctx context.Context, store vectorstore.VectorStore, embedder embedding.Embedder,
+	collectionName string, chunkCollectionName string, filter *qdrant.Filter, queryText string, limit uint64) ([]structure.SimplifiedEntry, error) {
+
+	if strings.TrimSpace(queryText) == "" {
+		return nil, ErrEmptyQuery
 	}
 
-	resp, err := model.EmbedContent(ctx, genai.Text(text))
+	vector, err := embedder.Embed(ctx, queryText)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to embed query: %w", err)
 	}
 
-	if len(resp.Embedding.Values) == 0 {
-		return nil, fmt.Errorf("received empty embedding from Gemini")
+	points, err := store.Query(ctx, &qdrant.QueryPoints{
+		CollectionName: collectionName,
+		Query:          qdrant.NewQueryDense(vector),
+		Filter:         filter,
+		Limit:          &limit,
+		WithPayload:    &qdrant.WithPayloadSelector{SelectorOptions: &qdrant.WithPayloadSelector_Enable{Enable: true}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search papers: %w", err)
 	}
 
-	embedding := make([]float32, len(resp.Embedding.Values))
-	for i, v := range resp.Embedding.Values {
-		embedding[i] = float32(v)
+	best := make(map[string]structure.SimplifiedEntry)
+	for _, point := range points {
+		paper, ok := entryFromPayload(point.Payload)
+		if !ok {
+			continue
+		}
+		paper.Score = point.Score
+		best[paper.ID] = paper
 	}
 
-	log.Printf("Generated embedding with size: %d", len(embedding))
-
-	return embedding, nil
-}
-
-func SimilaritySearch(ctx context.Context, client *qdrant.Client, collectionName string, 
-	queryText string, limit uint64) ([]structure.SimplifiedEntry, error) {
-	
-	points, err := client.Query(ctx, &qdrant.QueryPoints{
-		CollectionName: collectionName,
-		Query:          nil, 
+	// Chunks are grouped by their parent point so Qdrant can look the paper up
+	// directly; papers without full text simply have no chunk groups
+	groupSize := uint64(1)
+	groups, err := store.QueryGroups(ctx, &qdrant.QueryPointGroups{
+		CollectionName: chunkCollectionName,
+		Query:          qdrant.NewQueryDense(vector),
+		GroupBy:        payload.FieldChunkPaperPointID,
+		GroupSize:      &groupSize,
 		Limit:          &limit,
-		WithPayload:    &qdrant.WithPayloadSelector{SelectorOptions: &qdrant.WithPayloadSelector_Enable{Enable: true}},
+		WithPayload:    qdrant.NewWithPayload(false),
+		WithLookup: &qdrant.WithLookup{
+			Collection:  collectionName,
+			WithPayload: qdrant.NewWithPayload(true),
+		},
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch papers: %w", err)
+		log.Printf("Full-text chunk search failed, using summaries only: %v", err)
 	}
 
-	// Convert points to papers
-	var papers []structure.SimplifiedEntry
-	for _, point := range points {
-		payload := point.Payload
-		paper := structure.SimplifiedEntry{
-			ID:              getStringFromPayload(payload, "id"),
-			Updated:         getStringFromPayload(payload, "updated"),
-			Published:       getStringFromPayload(payload, "published"),
-			Title:           getStringFromPayload(payload, "title"),
-			Summary:         getStringFromPayload(payload, "summary"),
-			Authors:         getAuthorsFromPayload(payload, "authors"),
-			Comment:         getStringFromPayload(payload, "comment"),
-			Links:           getLinksFromPayload(payload, "links"),
-			PrimaryCategory: getStringFromPayload(payload, "primaryCategory"),
-			Categories:      getStringListFromPayload(payload, "categories"),
-			DOI:             getStringFromPayload(payload, "doi"),
-			JournalRef:      getStringFromPayload(payload, "journalRef"),
+	// Chunks don't carry the paper metadata, so the filter is applied to the
+	// papers they were grouped into
+	var allowed map[string]bool
+	if filter != nil && len(groups) > 0 {
+		ids := make([]*qdrant.PointId, 0, len(groups))
+		for _, group := range groups {
+			if group.Lookup != nil {
+				ids = append(ids, group.Lookup.Id)
+			}
+		}
+		matching, err := fetchPoints(ctx, store, collectionName, filter, ids, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to filter full-text matches: %w", err)
+		}
+		allowed = make(map[string]bool, len(matching))
+		for _, point := range matching {
+			allowed[point.Id.GetUuid()] = true
+		}
+	}
+
+	for _, group := range groups {
+		if group.Lookup == nil || len(group.Hits) == 0 {
+			continue
+		}
+		if allowed != nil && !allowed[group.Lookup.Id.GetUuid()] {
+			continue
+		}
+		paper, ok := entryFromPayload(group.Lookup.Payload)
+		if !ok {
+			continue
 		}
-		
-		// Calculate a simple relevance score based on text matching
-		// This is a fallback since we're not using vector search
-		relevanceScore := calculateRelevanceScore(paper, queryText)
-		paper.Score = relevanceScore
-		
+		paper.Score = group.Hits[0].Score
+		if existing, ok := best[paper.ID]; !ok || paper.Score > existing.Score {
+			best[paper.ID] = paper
+		}
+	}
+
+	papers := make([]structure.SimplifiedEntry, 0, len(best))
+	for _, paper := range best {
 		papers = append(papers, paper)
 	}
 
-	// Sort papers by relevance score (descending)
 	sort.Slice(papers, func(i, j int) bool {
 		return papers[i].Score > papers[j].Score
 	})
 
-	// Limit to the requested number of papers
 	if len(papers) > int(limit) {
 		papers = papers[:limit]
 	}
@@ -92,87 +130,139 @@ func SimilaritySearch(ctx context.Context, client *qdrant.Client, collectionName
 	return papers, nil
 }
 
-func calculateRelevanceScore(paper structure.SimplifiedEntry, queryText string) float32 {
-	queryLower := strings.ToLower(queryText)
-	titleLower := strings.ToLower(paper.Title)
-	summaryLower := strings.ToLower(paper.Summary)
-	
-	var score float32 = 0
-	
-	if strings.Contains(titleLower, queryLower) {
-		score += 10.0 // High weight for title matches
-	}
-	
-	if strings.Contains(summaryLower, queryLower) {
-		score += 5.0 // Medium weight for summary matches
-	}
-	
-	// Check categories for relevance
-	for _, category := range paper.Categories {
-		if strings.Contains(strings.ToLower(category), queryLower) {
-			score += 3.0 // Lower weight for category matches
-			break
-		}
+// rrfK dampens the weight of top ranks in reciprocal rank fusion; 60 is the
+// value from the original RRF paper
+const rrfK = 60
+
+// HybridSearch runs vector search and BM25 search side by side and fuses the
+// two rankings with reciprocal rank fusion. Score holds the fused score, and
+// SemanticScore and LexicalScore hold the cosine and BM25 scores of each
+// result (zero when a paper was only found by the other method). A nil filter
+// matches every paper.
+func HybridSearch(ctx context.Context, store vectorstore.VectorStore, embedder embedding.Embedder, index *lexical.Index,
+	collectionName string, chunkCollectionName string, filter *qdrant.Filter, queryText string, limit uint64) ([]structure.SimplifiedEntry, error) {
+
+	candidates := max(limit*4, 20)
+
+	semantic, err := SimilaritySearch(ctx, store, embedder, collectionName, chunkCollectionName, filter, queryText, candidates)
+	if err != nil {
+		return nil, err
 	}
-	
-	return score
-}
 
-// Helper functions to extract data from Qdrant payload
+	type fusedResult struct {
+		paper *structure.SimplifiedEntry
+		score float64
+	}
+	fused := make(map[string]*fusedResult)
+	var order []string
+
+	for rank, paper := range semantic {
+		pointID := arxiv.PointID(paper.ID)
+		paper.SemanticScore = paper.Score
+		fused[pointID] = &fusedResult{paper: &paper, score: 1 / float64(rrfK+rank+1)}
+		order = append(order, pointID)
+	}
 
-func getStringFromPayload(payload map[string]*qdrant.Value, key string) string {
-	if value, ok := payload[key]; ok && value.GetStringValue() != "" {
-		return value.GetStringValue()
+	// The BM25 index knows nothing about the filter, so look further down its
+	// ranking to leave enough hits once non-matching papers are dropped
+	lexicalLimit := int(candidates)
+	if filter != nil {
+		lexicalLimit *= 5
 	}
-	return ""
-}
+	lexicalHits := index.Search(queryText, lexicalLimit)
 
-func getAuthorsFromPayload(payload map[string]*qdrant.Value, key string) []structure.Author {
-	var authors []structure.Author
-	if value, ok := payload[key]; ok && value.GetListValue() != nil {
-		for _, v := range value.GetListValue().Values {
-			if structVal := v.GetStructValue(); structVal != nil {
-				if nameVal, exists := structVal.Fields["name"]; exists && nameVal.GetStringValue() != "" {
-					authors = append(authors, structure.Author{Name: nameVal.GetStringValue()})
-				}
-			}
+	// Papers found only by BM25 still need their payload, and have to be
+	// checked against the filter
+	var missing []*qdrant.PointId
+	for _, hit := range lexicalHits {
+		if _, ok := fused[hit.PointID]; !ok {
+			missing = append(missing, qdrant.NewIDUUID(hit.PointID))
 		}
 	}
-	return authors
-}
 
-func getLinksFromPayload(payload map[string]*qdrant.Value, key string) []structure.Link {
-	var links []structure.Link
-	if value, ok := payload[key]; ok && value.GetListValue() != nil {
-		for _, v := range value.GetListValue().Values {
-			if structVal := v.GetStructValue(); structVal != nil {
-				link := structure.Link{
-					Href: getStructString(structVal, "href"),
-					Rel:  getStructString(structVal, "rel"),
-					Type: getStructString(structVal, "type"),
-				}
-				links = append(links, link)
+	found := make(map[string]structure.SimplifiedEntry, len(missing))
+	if len(missing) > 0 {
+		points, err := fetchPoints(ctx, store, collectionName, filter, missing, true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch lexical matches: %w", err)
+		}
+		for _, point := range points {
+			if paper, ok := entryFromPayload(point.Payload); ok {
+				found[point.Id.GetUuid()] = paper
 			}
 		}
 	}
-	return links
-}
 
-func getStringListFromPayload(payload map[string]*qdrant.Value, key string) []string {
-	var strings []string
-	if value, ok := payload[key]; ok && value.GetListValue() != nil {
-		for _, v := range value.GetListValue().Values {
-			if str := v.GetStringValue(); str != "" {
-				strings = append(strings, str)
+	rank := 0
+	for _, hit := range lexicalHits {
+		result, ok := fused[hit.PointID]
+		if !ok {
+			paper, ok := found[hit.PointID]
+			if !ok {
+				// Filtered out, or indexed before it was deleted from Qdrant
+				continue
 			}
+			result = &fusedResult{paper: &paper}
+			fused[hit.PointID] = result
+			order = append(order, hit.PointID)
+		}
+		result.score += 1 / float64(rrfK+rank+1)
+		result.paper.LexicalScore = hit.Score
+		rank++
+		if rank == int(candidates) {
+			break
 		}
 	}
-	return strings
+
+	papers := make([]structure.SimplifiedEntry, 0, len(fused))
+	for _, pointID := range order {
+		result := fused[pointID]
+		result.paper.Score = float32(result.score)
+		papers = append(papers, *result.paper)
+	}
+
+	sort.SliceStable(papers, func(i, j int) bool {
+		return papers[i].Score > papers[j].Score
+	})
+
+	if len(papers) > int(limit) {
+		papers = papers[:limit]
+	}
+
+	return papers, nil
+}
+
+// fetchPoints retrieves the given points, keeping only those matching filter
+func fetchPoints(ctx context.Context, store vectorstore.VectorStore, collectionName string, filter *qdrant.Filter,
+	ids []*qdrant.PointId, withPayload bool) ([]*qdrant.RetrievedPoint, error) {
+
+	if filter == nil {
+		return store.Get(ctx, &qdrant.GetPoints{
+			CollectionName: collectionName,
+			Ids:            ids,
+			WithPayload:    qdrant.NewWithPayload(withPayload),
+		})
+	}
+
+	limit := uint32(len(ids))
+	points, _, err := store.Scroll(ctx, &qdrant.ScrollPoints{
+		CollectionName: collectionName,
+		Filter: &qdrant.Filter{
+			Must: []*qdrant.Condition{qdrant.NewHasID(ids...), qdrant.NewFilterAsCondition(filter)},
+		},
+		Limit:       &limit,
+		WithPayload: qdrant.NewWithPayload(withPayload),
+	})
+	return points, err
 }
 
-func getStructString(structVal *qdrant.Struct, key string) string {
-	if val, exists := structVal.Fields[key]; exists && val.GetStringValue() != "" {
-		return val.GetStringValue()
+// entryFromPayload decodes a paper, logging and reporting false for payloads
+// that don't decode so one bad point doesn't fail the whole search
+func entryFromPayload(fields map[string]*qdrant.Value) (structure.SimplifiedEntry, bool) {
+	entry, err := domain.DecodePayload(fields)
+	if err != nil {
+		log.Printf("Skipping paper with unreadable payload: %v", err)
+		return entry, false
 	}
-	return ""
+	return entry, true
 }
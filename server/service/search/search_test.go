@@ -0,0 +1,183 @@
+package search
+
+import (
+	"RAGScholar/consumer/structure"
+	"RAGScholar/consumer/worker"
+	"RAGScholar/domain"
+	"RAGScholar/embedding"
+	"RAGScholar/service/lexical"
+	"RAGScholar/service/paper"
+	"RAGScholar/vectorstore"
+	"context"
+	"reflect"
+	"testing"
+)
+
+var storedPapers = []structure.SimplifiedEntry{
+	{
+		ID:         "http://arxiv.org/abs/2401.00001v1",
+		Published:  "2024-01-01T00:00:00Z",
+		Updated:    "2024-01-01T00:00:00Z",
+		Title:      "Molecular Graphs",
+		Summary:    "Graph neural networks for molecule property prediction.",
+		Authors:    []domain.Author{{Name: "Ada Lovelace"}},
+		Links:      []domain.Link{{Href: "http://arxiv.org/pdf/2401.00001v1", Rel: "related", Type: "application/pdf"}},
+		Categories: []string{"cs.LG"},
+	},
+	{
+		ID:         "http://arxiv.org/abs/2401.00002v2",
+		Published:  "2024-02-01T00:00:00+01:00",
+		Updated:    "2024-03-01T00:00:00Z",
+		Title:      "Low-Resource Translation",
+		Summary:    "Transformers for machine translation of low resource languages.",
+		Authors:    []domain.Author{{Name: "Alan Turing"}, {Name: "Grace Hopper"}},
+		Categories: []string{"cs.CL", "cs.LG"},
+		DOI:        "10.1000/example",
+	},
+	{
+		ID:         "http://arxiv.org/abs/hep-th/9901001v1",
+		Published:  "1999-01-04T00:00:00Z",
+		Updated:    "1999-01-04T00:00:00Z",
+		Title:      "Dualities",
+		Summary:    "String theory dualities in ten dimensions.",
+		Authors:    []domain.Author{{Name: "Edward Witten"}},
+		Categories: []string{"hep-th"},
+	},
+}
+
+// storeThroughConsumer stores storedPapers the way the consumer does, and
+// returns them as they should read back
+func storeThroughConsumer(t *testing.T, store vectorstore.VectorStore, embedder embedding.Embedder) map[string]structure.SimplifiedEntry {
+	t.Helper()
+	if _, err := worker.StoreEntries(context.Background(), store, "papers", storedPapers, embedder); err != nil {
+		t.Fatalf("StoreEntries: %v", err)
+	}
+
+	want := make(map[string]structure.SimplifiedEntry, len(storedPapers))
+	for _, entry := range storedPapers {
+		entry.Normalize()
+		want[entry.ID] = entry
+	}
+	return want
+}
+
+func withoutScores(entry structure.SimplifiedEntry) structure.SimplifiedEntry {
+	entry.Score, entry.SemanticScore, entry.LexicalScore = 0, 0, 0
+	return entry
+}
+
+func TestStoredPapersReadBack(t *testing.T) {
+	ctx := context.Background()
+	store := vectorstore.NewMemory()
+	embedder := embedding.NewLocal(64)
+	want := storeThroughConsumer(t, store, embedder)
+
+	translation := "http://arxiv.org/abs/2401.00002v2"
+	dualities := "http://arxiv.org/abs/hep-th/9901001v1"
+
+	t.Run("similarity search", func(t *testing.T) {
+		papers, err := SimilaritySearch(ctx, store, embedder, "papers", "paper_chunks", nil, storedPapers[1].Summary, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(papers) != 3 {
+			t.Fatalf("found %d papers, want 3", len(papers))
+		}
+		if got := withoutScores(papers[0]); !reflect.DeepEqual(got, want[translation]) {
+			t.Errorf("top result = %+v\nwant %+v", got, want[translation])
+		}
+	})
+
+	t.Run("hybrid search", func(t *testing.T) {
+		lexicalStore := lexical.NewStore()
+		if err := lexicalStore.Refresh(ctx, store, "papers", "paper_chunks"); err != nil {
+			t.Fatal(err)
+		}
+		page, err := Search(ctx, store, embedder, lexicalStore.Index(), "papers", "paper_chunks", Filters{}, "translation", "", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Papers) == 0 || page.Papers[0].ID != translation {
+			t.Fatalf("results = %v, want %s first", page.Papers, translation)
+		}
+		if page.Papers[0].LexicalScore <= 0 {
+			t.Errorf("top result has lexical score %v, want a BM25 match", page.Papers[0].LexicalScore)
+		}
+	})
+
+	t.Run("filtered browse", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			filters Filters
+			want    []string
+		}{
+			{"group", Filters{Group: "physics"}, []string{dualities}},
+			{"category", Filters{Categories: []string{"cs.LG"}}, []string{"http://arxiv.org/abs/2401.00001v1", translation}},
+			{"author", Filters{Author: "hopper"}, []string{translation}},
+			{"DOI", Filters{HasDOI: new(bool)}, []string{"http://arxiv.org/abs/2401.00001v1", dualities}},
+		}
+		for _, test := range tests {
+			page, err := Search(ctx, store, embedder, nil, "papers", "paper_chunks", test.filters, "", "", 10)
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			got := make(map[string]bool)
+			for _, entry := range page.Papers {
+				got[entry.ID] = true
+				if !reflect.DeepEqual(entry, want[entry.ID]) {
+					t.Errorf("%s: browsed %+v\nwant %+v", test.name, entry, want[entry.ID])
+				}
+			}
+			if len(got) != len(test.want) {
+				t.Errorf("%s: found %v, want %v", test.name, got, test.want)
+			}
+			for _, id := range test.want {
+				if !got[id] {
+					t.Errorf("%s: %s is missing from %v", test.name, id, got)
+				}
+			}
+		}
+	})
+
+	t.Run("browse pages", func(t *testing.T) {
+		seen := make(map[string]bool)
+		cursor := ""
+		for pages := 0; ; pages++ {
+			if pages == len(storedPapers) {
+				t.Fatal("paging doesn't end")
+			}
+			page, err := Search(ctx, store, embedder, nil, "papers", "paper_chunks", Filters{}, "", cursor, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, entry := range page.Papers {
+				if seen[entry.ID] {
+					t.Errorf("%s listed twice", entry.ID)
+				}
+				seen[entry.ID] = true
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			cursor = page.NextCursor
+		}
+		if len(seen) != len(storedPapers) {
+			t.Errorf("paged through %d papers, want %d", len(seen), len(storedPapers))
+		}
+	})
+
+	t.Run("fetch by ID", func(t *testing.T) {
+		for id, requested := range map[string]string{
+			translation: "2401.00002",
+			dualities:   "9901001v1",
+		} {
+			got, err := paper.FetchPaperByID(ctx, store, "papers", requested)
+			if err != nil {
+				t.Fatalf("FetchPaperByID(%q): %v", requested, err)
+			}
+			if !reflect.DeepEqual(*got, want[id]) {
+				t.Errorf("FetchPaperByID(%q) = %+v\nwant %+v", requested, *got, want[id])
+			}
+		}
+	})
+}
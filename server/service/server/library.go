@@ -0,0 +1,351 @@
+package server
+
+import (
+	"RAGScholar/service/library"
+	"RAGScholar/service/models"
+	"RAGScholar/service/paper"
+	"RAGScholar/service/structure"
+	"RAGScholar/vectorstore"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerLibraryRoutes adds the personal library endpoints under
+// /users/:userId and the personalized feed. There is no authentication:
+// user IDs only keep libraries apart, so don't expose these routes beyond a
+// trusted network.
+func registerLibraryRoutes(router *gin.Engine, store *library.Store, vectorStore vectorstore.VectorStore, collectionName string, vectorSize int) {
+	router.POST("/users", func(ctx *gin.Context) {
+		var request models.CreateUserRequest
+		if err := ctx.ShouldBindJSON(&request); err != nil || strings.TrimSpace(request.Name) == "" {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+			return
+		}
+
+		user, err := store.CreateUser(ctx.Request.Context(), strings.TrimSpace(request.Name))
+		if err != nil {
+			libraryError(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusCreated, gin.H{"user": user})
+	})
+
+	// Personalized feed for userId; users without saved papers get the
+	// random feed, with personalized false
+	router.GET("/feed", func(ctx *gin.Context) {
+		userID, err := strconv.ParseInt(ctx.Query("userId"), 10, 64)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		limit := uint64(10)
+		if value := ctx.Query("limit"); value != "" {
+			limit, err = strconv.ParseUint(value, 10, 64)
+			if err != nil || limit < 1 || limit > 50 {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 50"})
+				return
+			}
+		}
+
+		papers, err := personalFeed(ctx.Request.Context(), store, vectorStore, collectionName, userID, limit)
+		personalized := err == nil
+		if errors.Is(err, paper.ErrNoHistory) {
+			papers, err = paper.FetchFeed(ctx.Request.Context(), vectorStore, collectionName, vectorSize,
+				paper.FeedOptions{Mode: paper.FeedRandom, Limit: limit})
+		}
+		if err != nil {
+			libraryError(ctx, err)
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"papers": papers, "personalized": personalized})
+	})
+
+	users := router.Group("/users/:userId")
+	users.Use(func(ctx *gin.Context) {
+		userID, err := strconv.ParseInt(ctx.Param("userId"), 10, 64)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		user, err := store.GetUser(ctx.Request.Context(), userID)
+		if err != nil {
+			libraryError(ctx, err)
+			ctx.Abort()
+			return
+		}
+		ctx.Set("user", user)
+	})
+
+	users.GET("", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{"user": currentUser(ctx)})
+	})
+
+	users.GET("/papers", func(ctx *gin.Context) {
+		papers, err := store.SavedPapers(ctx.Request.Context(), currentUser(ctx).ID)
+		if err != nil {
+			libraryError(ctx, err)
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"papers": papers})
+	})
+
+	// Saving again updates the note
+	users.PUT("/papers/:paperId", func(ctx *gin.Context) {
+		var request models.SavePaperRequest
+		if err := ctx.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+			return
+		}
+
+		found, ok := lookupPaper(ctx, vectorStore, collectionName, ctx.Param("paperId"))
+		if !ok {
+			return
+		}
+
+		saved, err := store.SavePaper(ctx.Request.Context(), currentUser(ctx).ID, found.ID, found.Title, request.Note)
+		if err != nil {
+			libraryError(ctx, err)
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"paper": saved})
+	})
+
+	users.DELETE("/papers/:paperId", func(ctx *gin.Context) {
+		if err := store.RemovePaper(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("paperId")); err != nil {
+			libraryError(ctx, err)
+			return
+		}
+		ctx.Status(http.StatusNoContent)
+	})
+
+	// Not interested: the paper is left out of the feed and used as a
+	// negative example
+	users.PUT("/not-interested/:paperId", func(ctx *gin.Context) {
+		if err := store.DismissPaper(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("paperId")); err != nil {
+			libraryError(ctx, err)
+			return
+		}
+		ctx.Status(http.StatusNoContent)
+	})
+
+	users.DELETE("/not-interested/:paperId", func(ctx *gin.Context) {
+		if err := store.RestorePaper(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("paperId")); err != nil {
+			libraryError(ctx, err)
+			return
+		}
+		ctx.Status(http.StatusNoContent)
+	})
+
+	users.GET("/collections", func(ctx *gin.Context) {
+		collections, err := store.Collections(ctx.Request.Context(), currentUser(ctx).ID)
+		if err != nil {
+			libraryError(ctx, err)
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"collections": collections})
+	})
+
+	users.POST("/collections", func(ctx *gin.Context) {
+		var request models.CreateCollectionRequest
+		if err := ctx.ShouldBindJSON(&request); err != nil || strings.TrimSpace(request.Name) == "" {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+			return
+		}
+
+		collection, err := store.CreateCollection(ctx.Request.Context(), currentUser(ctx).ID, strings.TrimSpace(request.Name), request.Description)
+		if err != nil {
+			libraryError(ctx, err)
+			return
+		}
+		ctx.JSON(http.StatusCreated, gin.H{"collection": collection})
+	})
+
+	users.GET("/collections/:collectionId", func(ctx *gin.Context) {
+		collectionID, ok := idParam(ctx, "collectionId")
+		if !ok {
+			return
+		}
+		collection, err := store.GetCollection(ctx.Request.Context(), currentUser(ctx).ID, collectionID)
+		if err != nil {
+			libraryError(ctx, err)
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"collection": collection})
+	})
+
+	users.DELETE("/collections/:collectionId", func(ctx *gin.Context) {
+		collectionID, ok := idParam(ctx, "collectionId")
+		if !ok {
+			return
+		}
+		if err := store.DeleteCollection(ctx.Request.Context(), currentUser(ctx).ID, collectionID); err != nil {
+			libraryError(ctx, err)
+			return
+		}
+		ctx.Status(http.StatusNoContent)
+	})
+
+	// Adding a paper to a collection also saves it to the library
+	users.PUT("/collections/:collectionId/papers/:paperId", func(ctx *gin.Context) {
+		collectionID, ok := idParam(ctx, "collectionId")
+		if !ok {
+			return
+		}
+		found, ok := lookupPaper(ctx, vectorStore, collectionName, ctx.Param("paperId"))
+		if !ok {
+			return
+		}
+		if err := store.AddToCollection(ctx.Request.Context(), currentUser(ctx).ID, collectionID, found.ID, found.Title); err != nil {
+			libraryError(ctx, err)
+			return
+		}
+		ctx.Status(http.StatusNoContent)
+	})
+
+	users.DELETE("/collections/:collectionId/papers/:paperId", func(ctx *gin.Context) {
+		collectionID, ok := idParam(ctx, "collectionId")
+		if !ok {
+			return
+		}
+		if err := store.RemoveFromCollection(ctx.Request.Context(), currentUser(ctx).ID, collectionID, ctx.Param("paperId")); err != nil {
+			libraryError(ctx, err)
+			return
+		}
+		ctx.Status(http.StatusNoContent)
+	})
+
+	// paperId narrows the list to one paper
+	users.GET("/highlights", func(ctx *gin.Context) {
+		highlights, err := store.Highlights(ctx.Request.Context(), currentUser(ctx).ID, ctx.Query("paperId"))
+		if err != nil {
+			libraryError(ctx, err)
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"highlights": highlights})
+	})
+
+	users.POST("/highlights", func(ctx *gin.Context) {
+		var request models.HighlightRequest
+		if err := ctx.ShouldBindJSON(&request); err != nil || request.PaperID == "" || strings.TrimSpace(request.Text) == "" {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+			return
+		}
+
+		found, ok := lookupPaper(ctx, vectorStore, collectionName, request.PaperID)
+		if !ok {
+			return
+		}
+
+		highlight, err := store.AddHighlight(ctx.Request.Context(), currentUser(ctx).ID, found.ID, request.Text, request.Note)
+		if err != nil {
+			libraryError(ctx, err)
+			return
+		}
+		ctx.JSON(http.StatusCreated, gin.H{"highlight": highlight})
+	})
+
+	users.DELETE("/highlights/:highlightId", func(ctx *gin.Context) {
+		highlightID, ok := idParam(ctx, "highlightId")
+		if !ok {
+			return
+		}
+		if err := store.DeleteHighlight(ctx.Request.Context(), currentUser(ctx).ID, highlightID); err != nil {
+			libraryError(ctx, err)
+			return
+		}
+		ctx.Status(http.StatusNoContent)
+	})
+
+	// Stores an explanation the client got from /analyze for this highlight
+	users.POST("/highlights/:highlightId/explanations", func(ctx *gin.Context) {
+		highlightID, ok := idParam(ctx, "highlightId")
+		if !ok {
+			return
+		}
+		var request models.ExplanationRequest
+		if err := ctx.ShouldBindJSON(&request); err != nil || strings.TrimSpace(request.Content) == "" {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+			return
+		}
+
+		explanation, err := store.AddExplanation(ctx.Request.Context(), currentUser(ctx).ID, highlightID, request.Model, request.Content)
+		if err != nil {
+			libraryError(ctx, err)
+			return
+		}
+		ctx.JSON(http.StatusCreated, gin.H{"explanation": explanation})
+	})
+}
+
+// personalFeed recommends papers from the user's saved and dismissed
+// papers, or fails with paper.ErrNoHistory while there are no saved ones
+func personalFeed(ctx context.Context, store *library.Store, vectorStore vectorstore.VectorStore, collectionName string,
+	userID int64, limit uint64) ([]structure.SimplifiedEntry, error) {
+
+	if _, err := store.GetUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	saved, err := store.SavedPapers(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	dismissed, err := store.DismissedPapers(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := paper.PersonalFeedOptions{Dismissed: dismissed, Limit: limit}
+	for _, savedPaper := range saved {
+		opts.Saved = append(opts.Saved, savedPaper.PaperID)
+	}
+	return paper.FetchPersonalFeed(ctx, vectorStore, collectionName, opts)
+}
+
+func currentUser(ctx *gin.Context) library.User {
+	return ctx.MustGet("user").(library.User)
+}
+
+func idParam(ctx *gin.Context, name string) (int64, bool) {
+	id, err := strconv.ParseInt(ctx.Param(name), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name})
+		return 0, false
+	}
+	return id, true
+}
+
+// lookupPaper checks the paper is in Qdrant, so the library only links
+// papers that /paper/:id can show
+func lookupPaper(ctx *gin.Context, vectorStore vectorstore.VectorStore, collectionName, paperID string) (*structure.SimplifiedEntry, bool) {
+	found, err := paper.FetchPaperByID(ctx.Request.Context(), vectorStore, collectionName, paperID)
+	if errors.Is(err, paper.ErrPaperNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Failed to fetch paper with ID %s: %v", paperID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch paper"})
+		return nil, false
+	}
+	return found, true
+}
+
+func libraryError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, library.ErrNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, library.ErrConflict):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Library request failed: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Library request failed"})
+	}
+}
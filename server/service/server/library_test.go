@@ -0,0 +1,154 @@
+package server
+
+import (
+	"RAGScholar/arxiv"
+	"RAGScholar/domain"
+	"RAGScholar/service/library"
+	"RAGScholar/vectorstore"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qdrant/go-client/qdrant"
+)
+
+func libraryRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	store, err := library.Open(filepath.Join(t.TempDir(), "library.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	vectorStore := vectorstore.NewMemory()
+	for _, id := range []string{"http://arxiv.org/abs/2401.01234v2", "http://arxiv.org/abs/2401.05678v1"} {
+		fields, err := domain.EncodePayload(domain.Entry{ID: id, Title: "Paper " + id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = vectorStore.Upsert(context.Background(), &qdrant.UpsertPoints{
+			CollectionName: "papers",
+			Points: []*qdrant.PointStruct{{
+				Id:      qdrant.NewIDUUID(arxiv.PointID(id)),
+				Vectors: qdrant.NewVectorsDense([]float32{1, 0}),
+				Payload: fields,
+			}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	registerLibraryRoutes(router, store, vectorStore, "papers", 2)
+	return router
+}
+
+func TestLibraryRoutes(t *testing.T) {
+	router := libraryRouter(t)
+
+	// Each step builds on the ones before it
+	steps := []struct {
+		method, path, body string
+		want               int
+	}{
+		{"POST", "/users", `{"name": "ada"}`, http.StatusCreated},
+		{"POST", "/users", `{"name": "ada"}`, http.StatusConflict},
+		{"POST", "/users", `{"name": " "}`, http.StatusBadRequest},
+		{"GET", "/users/1", "", http.StatusOK},
+		{"GET", "/users/2", "", http.StatusNotFound},
+		{"GET", "/users/ada", "", http.StatusBadRequest},
+
+		// Without saved papers the feed falls back to the random one
+		{"GET", "/feed?userId=1", "", http.StatusOK},
+		{"GET", "/feed?userId=2", "", http.StatusNotFound},
+		{"GET", "/feed?userId=1&limit=51", "", http.StatusBadRequest},
+
+		{"PUT", "/users/1/papers/2401.01234v1", `{"note": "read later"}`, http.StatusOK},
+		{"PUT", "/users/1/papers/2401.01234", "", http.StatusOK},
+		{"PUT", "/users/1/papers/2401.99999", "", http.StatusNotFound},
+		{"PUT", "/users/1/papers/2401.01234", "{", http.StatusBadRequest},
+		{"GET", "/users/1/papers", "", http.StatusOK},
+		{"GET", "/feed?userId=1", "", http.StatusOK},
+		{"DELETE", "/users/1/papers/2401.01234", "", http.StatusNoContent},
+		{"DELETE", "/users/1/papers/2401.01234", "", http.StatusNotFound},
+
+		{"PUT", "/users/1/not-interested/2401.05678", "", http.StatusNoContent},
+		{"DELETE", "/users/1/not-interested/2401.05678", "", http.StatusNoContent},
+		{"DELETE", "/users/1/not-interested/2401.05678", "", http.StatusNotFound},
+
+		{"POST", "/users/1/collections", `{"name": "Reading"}`, http.StatusCreated},
+		{"POST", "/users/1/collections", `{"name": "Reading"}`, http.StatusConflict},
+		{"POST", "/users/1/collections", `{}`, http.StatusBadRequest},
+		{"GET", "/users/1/collections", "", http.StatusOK},
+		{"PUT", "/users/1/collections/1/papers/2401.05678", "", http.StatusNoContent},
+		{"PUT", "/users/1/collections/2/papers/2401.05678", "", http.StatusNotFound},
+		{"PUT", "/users/1/collections/1/papers/2401.99999", "", http.StatusNotFound},
+		{"GET", "/users/1/collections/1", "", http.StatusOK},
+		{"GET", "/users/1/collections/2", "", http.StatusNotFound},
+		{"GET", "/users/1/collections/first", "", http.StatusBadRequest},
+		{"DELETE", "/users/1/collections/1/papers/2401.05678", "", http.StatusNoContent},
+		{"DELETE", "/users/1/collections/1/papers/2401.05678", "", http.StatusNotFound},
+		{"DELETE", "/users/1/collections/1", "", http.StatusNoContent},
+		{"DELETE", "/users/1/collections/1", "", http.StatusNotFound},
+
+		{"POST", "/users/1/highlights", `{"paperId": "2401.05678", "text": "attention"}`, http.StatusCreated},
+		{"POST", "/users/1/highlights", `{"paperId": "2401.99999", "text": "attention"}`, http.StatusNotFound},
+		{"POST", "/users/1/highlights", `{"paperId": "2401.05678"}`, http.StatusBadRequest},
+		{"GET", "/users/1/highlights?paperId=2401.05678", "", http.StatusOK},
+		{"POST", "/users/1/highlights/1/explanations", `{"model": "gemini", "content": "..."}`, http.StatusCreated},
+		{"POST", "/users/1/highlights/2/explanations", `{"model": "gemini", "content": "..."}`, http.StatusNotFound},
+		{"POST", "/users/1/highlights/1/explanations", `{"model": "gemini"}`, http.StatusBadRequest},
+		{"DELETE", "/users/1/highlights/1", "", http.StatusNoContent},
+		{"DELETE", "/users/1/highlights/1", "", http.StatusNotFound},
+	}
+	for _, step := range steps {
+		request := httptest.NewRequest(step.method, step.path, strings.NewReader(step.body))
+		if step.body != "" {
+			request.Header.Set("Content-Type", "application/json")
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		if recorder.Code != step.want {
+			t.Errorf("%s %s %s: status %d, want %d (%s)", step.method, step.path, step.body, recorder.Code, step.want, recorder.Body)
+		}
+	}
+}
+
+func TestFeedFallsBackWithoutSavedPapers(t *testing.T) {
+	router := libraryRouter(t)
+	serve := func(method, path, body string) map[string]any {
+		t.Helper()
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		var response map[string]any
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); recorder.Code >= 300 || err != nil {
+			t.Fatalf("%s %s: status %d, %s", method, path, recorder.Code, recorder.Body)
+		}
+		return response
+	}
+
+	serve("POST", "/users", `{"name": "ada"}`)
+	if response := serve("GET", "/feed?userId=1", ""); response["personalized"] != false || len(response["papers"].([]any)) != 2 {
+		t.Errorf("feed without saved papers = %v, want both papers, not personalized", response)
+	}
+
+	serve("PUT", "/users/1/papers/2401.01234", "")
+	response := serve("GET", "/feed?userId=1", "")
+	if response["personalized"] != true {
+		t.Errorf("feed after saving a paper = %v, want it personalized", response)
+	}
+	for _, found := range response["papers"].([]any) {
+		if found.(map[string]any)["id"] == "http://arxiv.org/abs/2401.01234v2" {
+			t.Errorf("personalized feed returned the saved paper")
+		}
+	}
+}
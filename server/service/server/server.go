@@ -0,0 +1,609 @@
+// Package server runs the HTTP API. The service binary and `ragscholar dev`
+// both start it with Run.
+package server
+
+import (
+	"RAGScholar/broker"
+	"RAGScholar/config"
+	"RAGScholar/embedding"
+	"RAGScholar/gemini"
+	"RAGScholar/service/answer"
+	"RAGScholar/service/explanation"
+	"RAGScholar/service/harvester"
+	"RAGScholar/service/lexical"
+	"RAGScholar/service/library"
+	"RAGScholar/service/llm"
+	"RAGScholar/service/models"
+	"RAGScholar/service/oaipmh"
+	"RAGScholar/service/paper"
+	"RAGScholar/service/search"
+	"RAGScholar/service/structure"
+	"RAGScholar/service/worker"
+	"RAGScholar/vectorstore"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Run serves the HTTP API until ctx is done. A nil broker or vector store is
+// built from cfg; `ragscholar dev` passes in-process ones it shares with the
+// consumer.
+func Run(ctx context.Context, cfg config.Config, messageBroker broker.Broker, vectorStore vectorstore.VectorStore) error {
+	if messageBroker == nil {
+		if cfg.Broker.Backend == broker.BackendMemory {
+			log.Print("Using the in-process broker; published papers are only consumed by ragscholar dev")
+		}
+		opened, err := broker.Open(cfg.Broker.Backend, cfg.RabbitMQ.URL, cfg.Broker.Path)
+		if err != nil {
+			return err
+		}
+		defer opened.Close()
+		messageBroker = opened
+	}
+
+	if err := messageBroker.Declare(cfg.RabbitMQ.Queue, broker.QueueOptions{}); err != nil {
+		return err
+	}
+
+	log.Print("Connected With Producer Queue!")
+
+	// Every Gemini call of the service, embeddings and explanations alike,
+	// is paced per model and retried through this client. The key is only
+	// required when embeddings or answers come from Gemini; without one,
+	// /analyze returns related papers but no explanation.
+	var geminiClient *gemini.Client
+	if cfg.Gemini.APIKey == "" {
+		if cfg.Embedding.Provider == embedding.ProviderGemini || cfg.Gemini.LLMProvider == "gemini" {
+			return errors.New("GEMINI_API_KEY environment variable not set")
+		}
+		log.Print("No Gemini API key set; explanations are disabled")
+	} else {
+		var err error
+		geminiClient, err = gemini.NewClient(ctx, cfg.GeminiOptions())
+		if err != nil {
+			return fmt.Errorf("failed to create Gemini client: %w", err)
+		}
+		defer geminiClient.Close()
+
+		log.Print("Connected With Gemini Client!")
+	}
+
+	if vectorStore == nil {
+		if cfg.VectorStore.Backend == vectorstore.BackendMemory {
+			log.Print("Using the in-memory vector store; it starts empty and is not shared with the consumer")
+			vectorStore = vectorstore.NewMemory()
+		} else {
+			qDrantclient, err := qdrant.NewClient(&qdrant.Config{
+				Host:   cfg.Qdrant.Host,
+				Port:   cfg.Qdrant.Port,
+				APIKey: cfg.Qdrant.APIKey,
+				UseTLS: cfg.Qdrant.UseTLS,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create Qdrant client: %w", err)
+			}
+			defer qDrantclient.Close()
+
+			log.Print("Connected With Qdrant Client!")
+			vectorStore = vectorstore.NewQdrant(qDrantclient)
+		}
+	}
+
+	collectionName := cfg.Qdrant.Collection
+	chunkCollectionName := cfg.Qdrant.ChunkCollection
+
+	embedder, err := embedding.New(cfg.EmbeddingConfig(), geminiClient)
+	if err != nil {
+		return fmt.Errorf("failed to initialize embedder: %w", err)
+	}
+
+	log.Printf("Using embedding model %s (%d dimensions)", embedder.Model(), embedder.Dimension())
+
+	// Repeated queries and selections reuse their vectors; GET /metrics
+	// reports the hit rate
+	embeddingCache, err := embedding.NewCache(embedder, cfg.Embedding.CacheSize, cfg.Embedding.CachePath)
+	if err != nil {
+		return err
+	}
+	defer embeddingCache.Close()
+	embedder = embeddingCache
+
+	var answerModel llm.LLM = llm.Fake{}
+	if cfg.Gemini.LLMProvider == "fake" {
+		log.Print("Using fake LLM for answers")
+	} else {
+		answerModel = llm.NewGemini(geminiClient, cfg.Gemini.AnswerModel)
+	}
+
+	harvestConfig := harvester.DefaultConfig(structure.Topics)
+	harvestConfig.StatePath = cfg.Harvest.StatePath
+	harvestConfig.RequestInterval = cfg.Harvest.RequestInterval
+
+	arxivHarvester, err := harvester.New(harvestConfig, nil, func(data []byte) error {
+		return worker.PublishToQueue(messageBroker, cfg.RabbitMQ.Queue, data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to initialize harvester: %w", err)
+	}
+
+	// Harvest jobs outlive the request that starts them
+	appCtx, cancelApp := context.WithCancel(ctx)
+	defer cancelApp()
+
+	// The BM25 index is refreshed from Qdrant periodically to pick up papers
+	// stored by the consumer
+	lexicalStore := lexical.NewStore()
+	go lexicalStore.RefreshEvery(appCtx, vectorStore, collectionName, chunkCollectionName, cfg.Search.LexicalRefreshInterval)
+
+	libraryStore, err := library.Open(cfg.Library.Path)
+	if err != nil {
+		return fmt.Errorf("failed to open library database: %w", err)
+	}
+	defer libraryStore.Close()
+
+	if cfg.Harvest.Schedule > 0 {
+		log.Printf("Harvesting arXiv every %s", cfg.Harvest.Schedule)
+		go arxivHarvester.RunEvery(appCtx, cfg.Harvest.Schedule)
+	}
+
+	router := gin.Default()
+
+	router.Use(cors.New(cors.Config{
+		AllowOrigins:     []string{"*"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
+		AllowCredentials: true,
+	}))
+
+	// Start a background harvest of every topic; poll GET /harvest for progress
+	router.POST("/harvest", func(ctx *gin.Context) {
+		status, err := arxivHarvester.Start(appCtx)
+		if errors.Is(err, harvester.ErrAlreadyRunning) {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Harvest already running", "status": status})
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to start harvest: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start harvest"})
+			return
+		}
+
+		ctx.JSON(http.StatusAccepted, gin.H{"status": status})
+	})
+
+	// Start an OAI-PMH harvest of records added or changed in a date range
+	router.POST("/harvest/incremental", func(ctx *gin.Context) {
+		var request models.IncrementalHarvestRequest
+		if err := ctx.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+			return
+		}
+
+		params := oaipmh.Params{Set: request.Set, MetadataPrefix: request.MetadataPrefix}
+		for _, date := range []struct {
+			value  string
+			target *time.Time
+		}{{request.From, &params.From}, {request.Until, &params.Until}} {
+			if date.value == "" {
+				continue
+			}
+			parsed, err := time.Parse(time.DateOnly, date.value)
+			if err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": "Dates must be formatted as YYYY-MM-DD"})
+				return
+			}
+			*date.target = parsed
+		}
+
+		status, err := arxivHarvester.StartIncremental(appCtx, params)
+		if errors.Is(err, harvester.ErrAlreadyRunning) {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Harvest already running", "status": status})
+			return
+		}
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusAccepted, gin.H{"status": status})
+	})
+
+	router.GET("/harvest", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{"status": arxivHarvester.Status()})
+	})
+
+	router.GET("/check", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{"message": "Welcome to RAGScholar API!"})
+	})
+
+	router.GET("/metrics", func(ctx *gin.Context) {
+		metrics := gin.H{"embeddingCache": embeddingCache.Stats()}
+		if geminiClient != nil {
+			metrics["gemini"] = geminiClient.Stats()
+		}
+		ctx.JSON(http.StatusOK, metrics)
+	})
+
+	// Home feed: mode is random (default), fresh or category, and category
+	// may be repeated. Every page is seeded, with a fresh seed when none is
+	// given, and passing back the returned seed reproduces it. With userId,
+	// users who saved papers get their personalized feed.
+	router.GET("/", func(ctx *gin.Context) {
+		opts := paper.FeedOptions{
+			Mode:       ctx.DefaultQuery("mode", paper.FeedRandom),
+			Categories: ctx.QueryArray("category"),
+			Limit:      10,
+		}
+
+		if value := ctx.Query("limit"); value != "" {
+			limit, err := strconv.ParseUint(value, 10, 64)
+			if err != nil || limit < 1 || limit > 50 {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 50"})
+				return
+			}
+			opts.Limit = limit
+		}
+		if value := ctx.Query("seed"); value != "" {
+			seed, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": "seed must be a non-negative integer"})
+				return
+			}
+			opts.Seed = &seed
+		}
+
+		// Users with saved papers get their personalized feed instead
+		if value := ctx.Query("userId"); value != "" {
+			userID, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+				return
+			}
+			papers, err := personalFeed(ctx.Request.Context(), libraryStore, vectorStore, collectionName, userID, opts.Limit)
+			if err == nil {
+				ctx.JSON(http.StatusOK, gin.H{"papers": papers, "mode": "personalized"})
+				return
+			}
+			if !errors.Is(err, paper.ErrNoHistory) {
+				libraryError(ctx, err)
+				return
+			}
+		}
+
+		papers, err := paper.FetchFeed(ctx.Request.Context(), vectorStore, collectionName, embedder.Dimension(), opts)
+		if errors.Is(err, paper.ErrInvalidFeed) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to fetch feed: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch papers"})
+			return
+		}
+
+		response := gin.H{"papers": papers, "mode": opts.Mode}
+		if opts.Seed != nil {
+			response["seed"] = *opts.Seed
+		}
+		ctx.JSON(http.StatusOK, response)
+	})
+
+	// New route for fetching a paper by ID
+	router.GET("/paper/:id", func(ctx *gin.Context) {
+		paperID := ctx.Param("id")
+
+		// Query Qdrant for the paper by ID
+		found, err := paper.FetchPaperByID(ctx.Request.Context(), vectorStore, collectionName, paperID)
+		if errors.Is(err, paper.ErrPaperNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Paper not found"})
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to fetch paper with ID %s: %v", paperID, err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch paper"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"paper": found})
+	})
+
+	// More like this: positive, negative and category may be repeated
+	router.GET("/paper/:id/similar", func(ctx *gin.Context) {
+		paperID := ctx.Param("id")
+		opts := paper.SimilarOptions{
+			Positive:   ctx.QueryArray("positive"),
+			Negative:   ctx.QueryArray("negative"),
+			Categories: ctx.QueryArray("category"),
+			Limit:      10,
+		}
+
+		if value := ctx.Query("limit"); value != "" {
+			limit, err := strconv.ParseUint(value, 10, 64)
+			if err != nil || limit < 1 || limit > 50 {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 50"})
+				return
+			}
+			opts.Limit = limit
+		}
+
+		papers, err := paper.FetchSimilar(context.Background(), vectorStore, collectionName, paperID, opts)
+		if errors.Is(err, paper.ErrPaperNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to fetch papers similar to %s: %v", paperID, err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch similar papers"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"papers": papers})
+	})
+
+	// Filtered search: q is optional, dates are YYYY-MM-DD and category may
+	// be repeated. Pass nextCursor back as cursor to get the following page.
+	router.GET("/search", func(ctx *gin.Context) {
+		var filters search.Filters
+		filters.PrimaryCategory = ctx.Query("primaryCategory")
+		filters.Categories = ctx.QueryArray("category")
+		filters.Archive = ctx.Query("archive")
+		filters.Group = ctx.Query("group")
+		filters.Author = ctx.Query("author")
+
+		var err error
+		if from := ctx.Query("from"); from != "" {
+			if filters.PublishedFrom, err = time.Parse(time.DateOnly, from); err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": "from must be a YYYY-MM-DD date"})
+				return
+			}
+		}
+		if until := ctx.Query("until"); until != "" {
+			if filters.PublishedUntil, err = time.Parse(time.DateOnly, until); err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": "until must be a YYYY-MM-DD date"})
+				return
+			}
+			// Include the whole last day
+			filters.PublishedUntil = filters.PublishedUntil.Add(24*time.Hour - time.Nanosecond)
+		}
+		if hasDOI := ctx.Query("hasDoi"); hasDOI != "" {
+			value, err := strconv.ParseBool(hasDOI)
+			if err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": "hasDoi must be true or false"})
+				return
+			}
+			filters.HasDOI = &value
+		}
+
+		limit := uint64(10)
+		if rawLimit := ctx.Query("limit"); rawLimit != "" {
+			limit, err = strconv.ParseUint(rawLimit, 10, 64)
+			if err != nil || limit == 0 || limit > 50 {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 50"})
+				return
+			}
+		}
+
+		reqCtx := ctx.Request.Context()
+		page, err := search.Search(reqCtx, vectorStore, embedder, lexicalStore.Index(), collectionName, chunkCollectionName,
+			filters, ctx.Query("q"), ctx.Query("cursor"), limit)
+		if errors.Is(err, search.ErrInvalidCursor) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to search papers: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search papers"})
+			return
+		}
+
+		facets, err := search.FacetCounts(reqCtx, vectorStore, collectionName, filters.QdrantFilter())
+		if err != nil {
+			log.Printf("Failed to count facets: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search papers"})
+			return
+		}
+
+		results := make([]models.PaperResult, 0, len(page.Papers))
+		for _, paper := range page.Papers {
+			results = append(results, models.PaperResult{
+				Paper:         paper,
+				Score:         paper.Score,
+				SemanticScore: paper.SemanticScore,
+				LexicalScore:  paper.LexicalScore,
+			})
+		}
+
+		ctx.JSON(http.StatusOK, models.SearchResponse{
+			Results:    results,
+			NextCursor: page.NextCursor,
+			Facets:     facets,
+		})
+	})
+
+	// New route for analyzing selected text and finding related papers
+	router.POST("/analyze", func(ctx *gin.Context) {
+		var request models.TextAnalysisRequest
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+			return
+		}
+
+		// Use search query if provided, otherwise use selected text
+		searchQuery := request.SearchQuery
+		if searchQuery == "" {
+			searchQuery = request.SelectedText
+		}
+
+		// Perform similarity search to find related papers
+		limit := uint64(5) // Get top 5 papers as requested
+
+		relatedPapers, err := search.HybridSearch(context.Background(), vectorStore, embedder, lexicalStore.Index(), collectionName, chunkCollectionName, nil, searchQuery, limit)
+		if errors.Is(err, search.ErrEmptyQuery) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "selectedText or searchQuery is required"})
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to perform similarity search: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find related papers"})
+			return
+		}
+
+		// Generate explanation for the selected text; without a Gemini
+		// client only the related papers are returned
+		var textExplanation string
+		if geminiClient != nil && request.CustomPrompt != "" {
+			// Use custom prompt if provided
+			textExplanation, err = explanation.CustomExplainText(context.Background(), geminiClient, cfg.Gemini.CustomModel, request.SelectedText, request.PaperContext, request.CustomPrompt)
+		} else if geminiClient != nil {
+			// Use default prompt
+			if request.PaperContext != "" {
+				textExplanation, err = explanation.ExplainText(context.Background(), geminiClient, cfg.Gemini.ExplainModel, request.SelectedText, request.PaperContext)
+
+			} else {
+				textExplanation = ""
+			}
+
+		}
+
+		if err != nil {
+			log.Printf("Failed to generate explanation: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate explanation"})
+			return
+		}
+
+		// Prepare response
+		var paperResults []models.PaperResult
+		for _, paper := range relatedPapers {
+			paperResults = append(paperResults, models.PaperResult{
+				Paper:         paper,
+				Score:         paper.Score,
+				SemanticScore: paper.SemanticScore,
+				LexicalScore:  paper.LexicalScore,
+			})
+		}
+
+		response := models.TextAnalysisResponse{
+			RelatedPapers: paperResults,
+			Explanation:   textExplanation,
+		}
+
+		ctx.JSON(http.StatusOK, response)
+	})
+
+	// Streaming variant of /analyze: related papers are sent first as a
+	// "related" event, then the explanation as "token" events ending in "done"
+	router.POST("/analyze/stream", func(ctx *gin.Context) {
+		var request models.TextAnalysisRequest
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+			return
+		}
+
+		searchQuery := request.SearchQuery
+		if searchQuery == "" {
+			searchQuery = request.SelectedText
+		}
+
+		// The request context is cancelled when the client disconnects,
+		// which also stops the Gemini stream
+		reqCtx := ctx.Request.Context()
+
+		relatedPapers, err := search.HybridSearch(reqCtx, vectorStore, embedder, lexicalStore.Index(), collectionName, chunkCollectionName, nil, searchQuery, uint64(5))
+		if errors.Is(err, search.ErrEmptyQuery) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "selectedText or searchQuery is required"})
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to perform similarity search: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find related papers"})
+			return
+		}
+
+		paperResults := []models.PaperResult{}
+		for _, paper := range relatedPapers {
+			paperResults = append(paperResults, models.PaperResult{
+				Paper:         paper,
+				Score:         paper.Score,
+				SemanticScore: paper.SemanticScore,
+				LexicalScore:  paper.LexicalScore,
+			})
+		}
+
+		ctx.Header("Content-Type", "text/event-stream")
+		ctx.Header("Cache-Control", "no-cache")
+		ctx.Header("Connection", "keep-alive")
+		ctx.Header("X-Accel-Buffering", "no")
+
+		ctx.SSEvent("related", gin.H{"relatedPapers": paperResults})
+		ctx.Writer.Flush()
+
+		if geminiClient != nil && (request.CustomPrompt != "" || request.PaperContext != "") {
+			err = explanation.StreamExplainText(reqCtx, geminiClient, cfg.Gemini.ExplainModel, cfg.Gemini.CustomModel, request.SelectedText, request.PaperContext, request.CustomPrompt, func(text string) error {
+				ctx.SSEvent("token", gin.H{"text": text})
+				ctx.Writer.Flush()
+				return reqCtx.Err()
+			})
+			if err != nil {
+				if reqCtx.Err() != nil {
+					log.Print("Client disconnected during explanation stream")
+					return
+				}
+				log.Printf("Failed to stream explanation: %v", err)
+				ctx.SSEvent("error", gin.H{"error": "Failed to generate explanation"})
+				ctx.Writer.Flush()
+				return
+			}
+		}
+
+		ctx.SSEvent("done", gin.H{})
+		ctx.Writer.Flush()
+	})
+
+	// Route for answering questions from retrieved papers with citations
+	router.POST("/ask", func(ctx *gin.Context) {
+		var request models.AskRequest
+		if err := ctx.ShouldBindJSON(&request); err != nil || request.Question == "" {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+			return
+		}
+		// topK is optional; zero means answer.DefaultTopK
+		if request.TopK < 0 || request.TopK > answer.MaxTopK {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("topK must be between 1 and %d", answer.MaxTopK)})
+			return
+		}
+
+		response, err := answer.Ask(ctx.Request.Context(), vectorStore, embedder, answerModel, collectionName, chunkCollectionName, request.Question, request.TopK)
+		if err != nil {
+			log.Printf("Failed to answer question: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to answer question"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, response)
+	})
+
+	registerLibraryRoutes(router, libraryStore, vectorStore, collectionName, embedder.Dimension())
+
+	server := &http.Server{Addr: cfg.Server.Addr, Handler: router}
+	go func() {
+		<-appCtx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("Listening on %s", cfg.Server.Addr)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -1,33 +1,31 @@
 package structure
 
-import "encoding/xml"
+import (
+	"RAGScholar/domain"
+	"encoding/xml"
+)
 
-type Link struct {
-	Href string `xml:"href,attr"`
-	Rel  string `xml:"rel,attr"`
-	Type string `xml:"type,attr"`
-}
+type Link = domain.Link
 
-type Author struct {
-	Name string `xml:"name"`
-}
+type Author = domain.Author
 
 type Category struct {
 	Term string `xml:"term,attr"`
 }
 
 type Entry struct {
-	ID         string     `xml:"id"`
-	Updated    string     `xml:"updated"`
-	Published  string     `xml:"published"`
-	Title      string     `xml:"title"`
-	Summary    string     `xml:"summary"`
-	Authors    []Author   `xml:"author"`
-	Comment    string     `xml:"http://arxiv.org/schemas/atom comment"`
-	Links      []Link     `xml:"link"`
-	Categories []Category `xml:"category"`
-	DOI        string     `xml:"http://arxiv.org/schemas/atom doi"`
-	JournalRef string     `xml:"http://arxiv.org/schemas/atom journal_ref"`
+	ID              string     `xml:"id"`
+	Updated         string     `xml:"updated"`
+	Published       string     `xml:"published"`
+	Title           string     `xml:"title"`
+	Summary         string     `xml:"summary"`
+	Authors         []Author   `xml:"author"`
+	Comment         string     `xml:"http://arxiv.org/schemas/atom comment"`
+	Links           []Link     `xml:"link"`
+	PrimaryCategory Category   `xml:"http://arxiv.org/schemas/atom primary_category"`
+	Categories      []Category `xml:"category"`
+	DOI             string     `xml:"http://arxiv.org/schemas/atom doi"`
+	JournalRef      string     `xml:"http://arxiv.org/schemas/atom journal_ref"`
 }
 
 type Feed struct {
@@ -41,21 +39,9 @@ type Feed struct {
 	Entries      []Entry  `xml:"entry"`
 }
 
-type SimplifiedEntry struct {
-	ID              string   `json:"id"`
-	Updated         string   `json:"updated"`
-	Published       string   `json:"published"`
-	Title           string   `json:"title"`
-	Summary         string   `json:"summary"`
-	Authors         []Author `json:"authors"`
-	Comment         string   `json:"comment"`
-	Links           []Link   `json:"links"`
-	PrimaryCategory string   `json:"primaryCategory"`
-	Categories      []string `json:"categories"`
-	DOI             string   `json:"doi"`
-	JournalRef      string   `json:"journalRef"`
-	Score           float32  `json:"score,omitempty"`
-}
+// SimplifiedEntry is the entry published to the queue and returned by the
+// API, shared with the consumer
+type SimplifiedEntry = domain.Entry
 
 var Topics = []string{
 	// Computer Science
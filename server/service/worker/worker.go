@@ -1,6 +1,7 @@
 package worker
 
 import (
+	"RAGScholar/broker"
 	structure "RAGScholar/service/structure"
 	"context"
 	"encoding/json"
@@ -8,62 +9,58 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"math/rand"
 	"net/http"
 	"net/url"
 	"strconv"
-	"strings"
-	"time"
-
-	"github.com/google/generative-ai-go/genai"
-	amqp "github.com/rabbitmq/amqp091-go"
-	"golang.org/x/time/rate"
 )
 
-// PublishToQueue publishes JSON data to the specified RabbitMQ queue
-func PublishToQueue(channel *amqp.Channel, queueName string, jsonData []byte) error {
-	return channel.Publish(
-		"",        // Exchange
-		queueName, // Routing key (queue name)
-		false,     // Mandatory
-		false,     // Immediate
-		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        jsonData,
-		},
-	)
+// PublishToQueue publishes JSON data to the specified queue
+func PublishToQueue(b broker.Broker, queueName string, jsonData []byte) error {
+	return b.Publish(context.Background(), queueName, broker.Message{
+		ContentType: "application/json",
+		Body:        jsonData,
+	})
 }
 
-// GetData fetches and processes arXiv data
-func GetData(topics []string) ([]structure.SimplifiedEntry, []byte, error) {
-	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+const arxivAPIURL = "http://export.arxiv.org/api/query"
 
-	topic := topics[rng.Intn(len(topics))]
-	start := rng.Intn(200) + 1
-	log.Printf("Selected Topic and Start: %s\n , %v", topic, start)
+// FetchEntries fetches one page of arXiv search results for query starting at
+// offset start. It returns the entries, their JSON encoding for the queue and
+// the total number of results arXiv reports for the query.
+func FetchEntries(ctx context.Context, client *http.Client, query string, start int, maxResults int) ([]structure.SimplifiedEntry, []byte, int, error) {
+	queryURL := arxivAPIURL + "?search_query=" + url.QueryEscape(query) +
+		"&start=" + strconv.Itoa(start) + "&max_results=" + strconv.Itoa(maxResults) +
+		"&sortBy=submittedDate&sortOrder=ascending"
 
-	queryURL := "http://export.arxiv.org/api/query?search_query=" + url.QueryEscape(topic) + "&max_results=10&start=" + url.QueryEscape(strconv.Itoa(start))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, queryURL, nil)
+	if err != nil {
+		return nil, nil, 0, err
+	}
 
-	res, err := http.Get(queryURL)
+	res, err := client.Do(req)
 	if err != nil {
 		log.Printf("HTTP Request Error: %v\n", err)
-		return nil, nil, err
+		return nil, nil, 0, err
 	}
 	defer res.Body.Close()
 
+	if res.StatusCode != http.StatusOK {
+		return nil, nil, 0, &StatusError{StatusCode: res.StatusCode}
+	}
+
 	body, err := io.ReadAll(res.Body)
 	if err != nil {
 		log.Printf("Read Body Error: %v\n", err)
-		return nil, nil, err
+		return nil, nil, 0, err
 	}
 
 	var feed structure.Feed
 	if err := xml.Unmarshal(body, &feed); err != nil {
 		log.Printf("XML Parsing Error: %v\n", err)
-		return nil, nil, err
+		return nil, nil, 0, err
 	}
 
-	var simplifiedEntries []structure.SimplifiedEntry
+	simplifiedEntries := []structure.SimplifiedEntry{}
 	for _, entry := range feed.Entries {
 		simplifiedEntry := structure.SimplifiedEntry{
 			ID:         entry.ID,
@@ -78,49 +75,28 @@ func GetData(topics []string) ([]structure.SimplifiedEntry, []byte, error) {
 			JournalRef: entry.JournalRef,
 		}
 
+		var categories []string
 		for _, cat := range entry.Categories {
-			simplifiedEntry.Categories = append(simplifiedEntry.Categories, cat.Term)
+			categories = append(categories, cat.Term)
 		}
+		simplifiedEntry.SetCategories(entry.PrimaryCategory.Term, categories)
 		simplifiedEntries = append(simplifiedEntries, simplifiedEntry)
 	}
 
 	jsonData, err := json.Marshal(simplifiedEntries)
 	if err != nil {
 		log.Printf("JSON Marshal Error: %v\n", err)
-		return nil, nil, err
+		return nil, nil, 0, err
 	}
 
-	return simplifiedEntries, jsonData, nil
+	return simplifiedEntries, jsonData, feed.TotalResults, nil
 }
 
-func GenerateEmbedding(client *genai.Client, model *genai.EmbeddingModel, text string, limiter *rate.Limiter) ([]float32, error) {
-	ctx := context.Background()
-
-	if err := limiter.Wait(ctx); err != nil {
-		return nil, err
-	}
-
-	if strings.TrimSpace(text) == "" {
-		return nil, fmt.Errorf("cannot generate embedding for empty text")
-	}
-
-	resp, err := model.EmbedContent(ctx, genai.Text(text))
-	if err != nil {
-		return nil, err
-	}
-
-	if len(resp.Embedding.Values) == 0 {
-		return nil, fmt.Errorf("received empty embedding from Gemini")
-	}
-
-	embedding := make([]float32, len(resp.Embedding.Values))
-	for i, v := range resp.Embedding.Values {
-		embedding[i] = float32(v)
-	}
-
-	log.Printf("Generated embedding with size: %d", len(embedding))
-
-	return embedding, nil
+// StatusError reports a non-200 response from the arXiv API
+type StatusError struct {
+	StatusCode int
 }
 
-
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("arXiv API returned status %d", e.StatusCode)
+}
@@ -0,0 +1,216 @@
+package vectorstore
+
+import (
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// matchFilter evaluates a Qdrant filter against a stored point. Geo and
+// nested-object conditions never match.
+func matchFilter(filter *qdrant.Filter, point *memoryPoint) bool {
+	if filter == nil {
+		return true
+	}
+
+	for _, condition := range filter.GetMust() {
+		if !matchCondition(condition, point) {
+			return false
+		}
+	}
+	for _, condition := range filter.GetMustNot() {
+		if matchCondition(condition, point) {
+			return false
+		}
+	}
+	if len(filter.GetShould()) > 0 && !slices.ContainsFunc(filter.GetShould(), func(c *qdrant.Condition) bool {
+		return matchCondition(c, point)
+	}) {
+		return false
+	}
+	if minShould := filter.GetMinShould(); minShould != nil {
+		matched := 0
+		for _, condition := range minShould.GetConditions() {
+			if matchCondition(condition, point) {
+				matched++
+			}
+		}
+		if uint64(matched) < minShould.GetMinCount() {
+			return false
+		}
+	}
+	return true
+}
+
+func matchCondition(condition *qdrant.Condition, point *memoryPoint) bool {
+	switch c := condition.GetConditionOneOf().(type) {
+	case *qdrant.Condition_Field:
+		return matchField(c.Field, point)
+	case *qdrant.Condition_Filter:
+		return matchFilter(c.Filter, point)
+	case *qdrant.Condition_HasId:
+		return slices.ContainsFunc(c.HasId.GetHasId(), func(id *qdrant.PointId) bool {
+			return compareIDs(id, point.id) == 0
+		})
+	case *qdrant.Condition_IsEmpty:
+		return len(nonNull(fieldValues(point.payload, c.IsEmpty.GetKey()))) == 0
+	case *qdrant.Condition_IsNull:
+		value, ok := point.payload[c.IsNull.GetKey()]
+		return ok && isNull(value)
+	}
+	return false
+}
+
+// matchField requires every part of the condition that is set to hold for
+// at least one of the field's values
+func matchField(field *qdrant.FieldCondition, point *memoryPoint) bool {
+	values := nonNull(fieldValues(point.payload, field.GetKey()))
+
+	if match := field.GetMatch(); match != nil && !slices.ContainsFunc(values, func(v *qdrant.Value) bool { return matchValue(match, v) }) {
+		return false
+	}
+	if r := field.GetRange(); r != nil && !slices.ContainsFunc(values, func(v *qdrant.Value) bool { return inRange(r, v) }) {
+		return false
+	}
+	if r := field.GetDatetimeRange(); r != nil && !slices.ContainsFunc(values, func(v *qdrant.Value) bool { return inDatetimeRange(r, v) }) {
+		return false
+	}
+	if count := field.GetValuesCount(); count != nil {
+		n := uint64(len(values))
+		if (count.Lt != nil && n >= *count.Lt) || (count.Gt != nil && n <= *count.Gt) ||
+			(count.Lte != nil && n > *count.Lte) || (count.Gte != nil && n < *count.Gte) {
+			return false
+		}
+	}
+	if field.GetGeoBoundingBox() != nil || field.GetGeoRadius() != nil || field.GetGeoPolygon() != nil {
+		return false
+	}
+	return true
+}
+
+func matchValue(match *qdrant.Match, value *qdrant.Value) bool {
+	switch m := match.GetMatchValue().(type) {
+	case *qdrant.Match_Keyword:
+		return isString(value) && value.GetStringValue() == m.Keyword
+	case *qdrant.Match_Keywords:
+		return isString(value) && slices.Contains(m.Keywords.GetStrings(), value.GetStringValue())
+	case *qdrant.Match_ExceptKeywords:
+		return isString(value) && !slices.Contains(m.ExceptKeywords.GetStrings(), value.GetStringValue())
+	case *qdrant.Match_Integer:
+		return isInteger(value) && value.GetIntegerValue() == m.Integer
+	case *qdrant.Match_Integers:
+		return isInteger(value) && slices.Contains(m.Integers.GetIntegers(), value.GetIntegerValue())
+	case *qdrant.Match_ExceptIntegers:
+		return isInteger(value) && !slices.Contains(m.ExceptIntegers.GetIntegers(), value.GetIntegerValue())
+	case *qdrant.Match_Boolean:
+		_, ok := value.GetKind().(*qdrant.Value_BoolValue)
+		return ok && value.GetBoolValue() == m.Boolean
+	case *qdrant.Match_Text:
+		// Like a full-text index: every word of the query must appear
+		if !isString(value) {
+			return false
+		}
+		words := tokenize(value.GetStringValue())
+		for _, word := range tokenize(m.Text) {
+			if !slices.Contains(words, word) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func inRange(r *qdrant.Range, value *qdrant.Value) bool {
+	n, ok := numeric(value)
+	return ok && (r.Lt == nil || n < *r.Lt) && (r.Gt == nil || n > *r.Gt) &&
+		(r.Lte == nil || n <= *r.Lte) && (r.Gte == nil || n >= *r.Gte)
+}
+
+func inDatetimeRange(r *qdrant.DatetimeRange, value *qdrant.Value) bool {
+	if !isString(value) {
+		return false
+	}
+	t, err := time.Parse(time.RFC3339, value.GetStringValue())
+	if err != nil {
+		return false
+	}
+	return (r.Lt == nil || t.Before(r.Lt.AsTime())) && (r.Gt == nil || t.After(r.Gt.AsTime())) &&
+		(r.Lte == nil || !t.After(r.Lte.AsTime())) && (r.Gte == nil || !t.Before(r.Gte.AsTime()))
+}
+
+// fieldValues resolves a payload key such as "categories", "a.b" or
+// "authors[].name", flattening lists along the way and at the end
+func fieldValues(payload map[string]*qdrant.Value, key string) []*qdrant.Value {
+	segments := strings.Split(key, ".")
+	first := strings.TrimSuffix(segments[0], "[]")
+	value, ok := payload[first]
+	if !ok {
+		return nil
+	}
+
+	values := flatten([]*qdrant.Value{value})
+	for _, segment := range segments[1:] {
+		segment = strings.TrimSuffix(segment, "[]")
+		var next []*qdrant.Value
+		for _, v := range values {
+			if s, ok := v.GetKind().(*qdrant.Value_StructValue); ok {
+				if field, ok := s.StructValue.GetFields()[segment]; ok {
+					next = append(next, field)
+				}
+			}
+		}
+		values = flatten(next)
+	}
+	return values
+}
+
+func flatten(values []*qdrant.Value) []*qdrant.Value {
+	var flat []*qdrant.Value
+	for _, value := range values {
+		if list, ok := value.GetKind().(*qdrant.Value_ListValue); ok {
+			flat = append(flat, flatten(list.ListValue.GetValues())...)
+		} else {
+			flat = append(flat, value)
+		}
+	}
+	return flat
+}
+
+func nonNull(values []*qdrant.Value) []*qdrant.Value {
+	return slices.DeleteFunc(values, isNull)
+}
+
+func isNull(value *qdrant.Value) bool {
+	_, null := value.GetKind().(*qdrant.Value_NullValue)
+	return null || value.GetKind() == nil
+}
+
+func isString(value *qdrant.Value) bool {
+	_, ok := value.GetKind().(*qdrant.Value_StringValue)
+	return ok
+}
+
+func isInteger(value *qdrant.Value) bool {
+	_, ok := value.GetKind().(*qdrant.Value_IntegerValue)
+	return ok
+}
+
+func numeric(value *qdrant.Value) (float64, bool) {
+	switch kind := value.GetKind().(type) {
+	case *qdrant.Value_IntegerValue:
+		return float64(kind.IntegerValue), true
+	case *qdrant.Value_DoubleValue:
+		return kind.DoubleValue, true
+	}
+	return 0, false
+}
+
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
@@ -0,0 +1,223 @@
+package vectorstore
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type fixturePaper struct {
+	id         uint64
+	title      string
+	categories []string
+	year       int64
+	published  string
+	authors    []string
+	vector     []float32
+}
+
+var fixturePapers = []fixturePaper{
+	{1, "Deep Learning for Graphs", []string{"cs.LG", "stat.ML"}, 2020, "2020-01-01T00:00:00Z", []string{"Ada Lovelace"}, []float32{1, 0, 0}},
+	{2, "Parsing with Transformers", []string{"cs.CL"}, 2021, "2021-06-01T00:00:00Z", []string{"Alan Turing"}, []float32{0, 1, 0}},
+	{3, "Learning to Rank", []string{"cs.LG"}, 2022, "2022-03-01T00:00:00Z", []string{"Ada Lovelace", "Grace Hopper"}, []float32{0.9, 0.1, 0}},
+	{4, "Moduli of Curves", []string{"math.AG"}, 2019, "", nil, []float32{0, 0, 1}},
+	{5, "Language Models for Code", []string{"cs.CL", "cs.LG"}, 2023, "2023-09-15T00:00:00Z", []string{"Alan Turing"}, []float32{0.5, 0.5, 0}},
+}
+
+// newFixtureStore stores fixturePapers in the "papers" collection
+func newFixtureStore(t *testing.T) *Memory {
+	t.Helper()
+	store := NewMemory()
+	points := make([]*qdrant.PointStruct, len(fixturePapers))
+	for i, paper := range fixturePapers {
+		authors := make([]any, len(paper.authors))
+		for j, name := range paper.authors {
+			authors[j] = map[string]any{"name": name}
+		}
+		categories := make([]any, len(paper.categories))
+		for j, category := range paper.categories {
+			categories[j] = category
+		}
+		fields := map[string]any{
+			"title":      paper.title,
+			"categories": categories,
+			"year":       paper.year,
+			"authors":    authors,
+		}
+		if paper.published != "" {
+			fields["published"] = paper.published
+		}
+		points[i] = &qdrant.PointStruct{
+			Id:      qdrant.NewIDNum(paper.id),
+			Vectors: qdrant.NewVectorsDense(paper.vector),
+			Payload: qdrant.NewValueMap(fields),
+		}
+	}
+	if err := store.Upsert(context.Background(), &qdrant.UpsertPoints{CollectionName: "papers", Points: points}); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+// scrollIDs returns the numeric IDs of every point matching filter
+func scrollIDs(t *testing.T, store *Memory, filter *qdrant.Filter) []uint64 {
+	t.Helper()
+	limit := uint32(100)
+	points, next, err := store.Scroll(context.Background(), &qdrant.ScrollPoints{
+		CollectionName: "papers",
+		Filter:         filter,
+		Limit:          &limit,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != nil {
+		t.Fatalf("unexpected next page at %v", next)
+	}
+	ids := make([]uint64, len(points))
+	for i, point := range points {
+		ids[i] = point.GetId().GetNum()
+	}
+	return ids
+}
+
+func timestamp(value string) *timestamppb.Timestamp {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		panic(err)
+	}
+	return timestamppb.New(t)
+}
+
+func TestMatchFilter(t *testing.T) {
+	store := newFixtureStore(t)
+
+	tests := []struct {
+		name   string
+		filter *qdrant.Filter
+		want   []uint64
+	}{
+		{"no filter", nil, []uint64{1, 2, 3, 4, 5}},
+		{
+			"must keyword in a list",
+			&qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewMatchKeyword("categories", "cs.LG")}},
+			[]uint64{1, 3, 5},
+		},
+		{
+			"must every condition",
+			&qdrant.Filter{Must: []*qdrant.Condition{
+				qdrant.NewMatchKeyword("categories", "cs.LG"),
+				qdrant.NewRange("year", &qdrant.Range{Gte: qdrant.PtrOf(2021.0)}),
+			}},
+			[]uint64{3, 5},
+		},
+		{
+			"should any condition",
+			&qdrant.Filter{Should: []*qdrant.Condition{
+				qdrant.NewMatchKeyword("categories", "cs.CL"),
+				qdrant.NewMatchKeyword("categories", "math.AG"),
+			}},
+			[]uint64{2, 4, 5},
+		},
+		{
+			"must not",
+			&qdrant.Filter{MustNot: []*qdrant.Condition{qdrant.NewMatchKeyword("categories", "cs.LG")}},
+			[]uint64{2, 4},
+		},
+		{
+			"must, should and must not together",
+			&qdrant.Filter{
+				Must:    []*qdrant.Condition{qdrant.NewRange("year", &qdrant.Range{Gt: qdrant.PtrOf(2019.0)})},
+				Should:  []*qdrant.Condition{qdrant.NewMatchKeyword("categories", "cs.LG"), qdrant.NewMatchKeyword("categories", "cs.CL")},
+				MustNot: []*qdrant.Condition{qdrant.NewMatchKeyword("authors[].name", "Alan Turing")},
+			},
+			[]uint64{1, 3},
+		},
+		{
+			"min should",
+			&qdrant.Filter{MinShould: &qdrant.MinShould{
+				Conditions: []*qdrant.Condition{
+					qdrant.NewMatchKeyword("categories", "cs.CL"),
+					qdrant.NewRange("year", &qdrant.Range{Gte: qdrant.PtrOf(2022.0)}),
+					qdrant.NewMatchKeyword("authors[].name", "Ada Lovelace"),
+				},
+				MinCount: 2,
+			}},
+			[]uint64{3, 5},
+		},
+		{
+			"range is exclusive with lt",
+			&qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewRange("year", &qdrant.Range{Lt: qdrant.PtrOf(2021.0)})}},
+			[]uint64{1, 4},
+		},
+		{
+			"range is inclusive with lte and gte",
+			&qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewRange("year", &qdrant.Range{Gte: qdrant.PtrOf(2020.0), Lte: qdrant.PtrOf(2021.0)})}},
+			[]uint64{1, 2},
+		},
+		{
+			"datetime range skips points without the field",
+			&qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewDatetimeRange("published", &qdrant.DatetimeRange{
+				Gte: timestamp("2021-01-01T00:00:00Z"),
+				Lt:  timestamp("2023-09-15T00:00:00Z"),
+			})}},
+			[]uint64{2, 3},
+		},
+		{
+			"datetime range is inclusive with lte",
+			&qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewDatetimeRange("published", &qdrant.DatetimeRange{
+				Lte: timestamp("2020-01-01T00:00:00Z"),
+			})}},
+			[]uint64{1},
+		},
+		{
+			"integers",
+			&qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewMatchInts("year", 2019, 2023)}},
+			[]uint64{4, 5},
+		},
+		{
+			"nested key",
+			&qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewMatchKeyword("authors[].name", "Grace Hopper")}},
+			[]uint64{3},
+		},
+		{
+			"text needs every word",
+			&qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewMatchText("title", "learning DEEP")}},
+			[]uint64{1},
+		},
+		{
+			"nested filter",
+			&qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewFilterAsCondition(&qdrant.Filter{
+				Should: []*qdrant.Condition{qdrant.NewMatchKeyword("categories", "math.AG"), qdrant.NewMatchKeyword("categories", "stat.ML")},
+			})}},
+			[]uint64{1, 4},
+		},
+		{
+			"has id",
+			&qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewHasID(qdrant.NewIDNum(2), qdrant.NewIDNum(4))}},
+			[]uint64{2, 4},
+		},
+		{
+			"is empty",
+			&qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewIsEmpty("published")}},
+			[]uint64{4},
+		},
+		{
+			"values count",
+			&qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewValuesCount("categories", &qdrant.ValuesCount{Gte: qdrant.PtrOf(uint64(2))})}},
+			[]uint64{1, 5},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := scrollIDs(t, store, test.filter); !slices.Equal(got, test.want) {
+				t.Errorf("matched %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,593 @@
+package vectorstore
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sync"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// defaultLimit is Qdrant's page size when a request sets none
+const defaultLimit = 10
+
+// Memory is a VectorStore that keeps every collection in memory and ranks
+// by brute-force cosine similarity, whatever distance Qdrant would use. It
+// supports the filters, query types and payload selectors this repo uses
+// and answers the rest with ErrUnsupported. Collections spring into
+// existence on first write, and reading one that doesn't exist yet gives no
+// points. It is safe for concurrent use.
+type Memory struct {
+	mu          sync.RWMutex
+	collections map[string]map[string]*memoryPoint
+}
+
+type memoryPoint struct {
+	id      *qdrant.PointId
+	vector  []float32
+	payload map[string]*qdrant.Value
+}
+
+func NewMemory() *Memory {
+	return &Memory{collections: make(map[string]map[string]*memoryPoint)}
+}
+
+func (m *Memory) Upsert(ctx context.Context, request *qdrant.UpsertPoints) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	collection := m.collections[request.GetCollectionName()]
+	if collection == nil {
+		collection = make(map[string]*memoryPoint)
+		m.collections[request.GetCollectionName()] = collection
+	}
+
+	for _, point := range request.GetPoints() {
+		vector := point.GetVectors().GetVector().GetData()
+		if vector == nil {
+			return fmt.Errorf("point %s: only single dense vectors are %w", idKey(point.GetId()), ErrUnsupported)
+		}
+		collection[idKey(point.GetId())] = &memoryPoint{
+			id:      point.GetId(),
+			vector:  slices.Clone(vector),
+			payload: clonePayload(point.GetPayload()),
+		}
+	}
+	return nil
+}
+
+func (m *Memory) Get(ctx context.Context, request *qdrant.GetPoints) ([]*qdrant.RetrievedPoint, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	collection := m.collections[request.GetCollectionName()]
+	var points []*qdrant.RetrievedPoint
+	for _, id := range request.GetIds() {
+		if point, ok := collection[idKey(id)]; ok {
+			points = append(points, retrieved(point, request.GetWithPayload(), request.GetWithVectors()))
+		}
+	}
+	return points, nil
+}
+
+func (m *Memory) Query(ctx context.Context, request *qdrant.QueryPoints) ([]*qdrant.ScoredPoint, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(request.GetPrefetch()) > 0 {
+		return nil, fmt.Errorf("prefetch queries are %w", ErrUnsupported)
+	}
+
+	scored, err := m.rank(request.GetCollectionName(), request.GetQuery(), request.GetFilter())
+	if err != nil {
+		return nil, err
+	}
+	if threshold := request.ScoreThreshold; threshold != nil {
+		scored = slices.DeleteFunc(scored, func(s scoredPoint) bool { return s.score < *threshold })
+	}
+	scored = page(scored, request.GetOffset(), limitOr(request.Limit))
+
+	points := make([]*qdrant.ScoredPoint, len(scored))
+	for i, s := range scored {
+		points[i] = scoredOutput(s, request.GetWithPayload(), request.GetWithVectors())
+	}
+	return points, nil
+}
+
+func (m *Memory) QueryGroups(ctx context.Context, request *qdrant.QueryPointGroups) ([]*qdrant.PointGroup, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(request.GetPrefetch()) > 0 {
+		return nil, fmt.Errorf("prefetch queries are %w", ErrUnsupported)
+	}
+
+	scored, err := m.rank(request.GetCollectionName(), request.GetQuery(), request.GetFilter())
+	if err != nil {
+		return nil, err
+	}
+
+	limit := limitOr(request.Limit)
+	groupSize := uint64(3)
+	if request.GroupSize != nil {
+		groupSize = *request.GroupSize
+	}
+
+	var groups []*qdrant.PointGroup
+	index := make(map[string]int)
+	for _, s := range scored {
+		values := fieldValues(s.point.payload, request.GetGroupBy())
+		if len(values) == 0 {
+			continue
+		}
+		key, id := groupKey(values[0])
+		if id == nil {
+			continue
+		}
+
+		i, ok := index[key]
+		if !ok {
+			if uint64(len(groups)) == limit {
+				continue
+			}
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, &qdrant.PointGroup{Id: id})
+		}
+		if uint64(len(groups[i].Hits)) < groupSize {
+			groups[i].Hits = append(groups[i].Hits, scoredOutput(s, request.GetWithPayload(), request.GetWithVectors()))
+		}
+	}
+
+	if lookup := request.GetWithLookup(); lookup != nil {
+		collection := m.collections[lookup.GetCollection()]
+		for _, group := range groups {
+			if point, ok := collection[group.GetId().GetStringValue()]; ok {
+				group.Lookup = retrieved(point, lookup.GetWithPayload(), lookup.GetWithVectors())
+			}
+		}
+	}
+	return groups, nil
+}
+
+func (m *Memory) Scroll(ctx context.Context, request *qdrant.ScrollPoints) ([]*qdrant.RetrievedPoint, *qdrant.PointId, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if request.GetOrderBy() != nil {
+		return nil, nil, fmt.Errorf("ordered scrolls are %w", ErrUnsupported)
+	}
+
+	matching := m.matching(request.GetCollectionName(), request.GetFilter())
+	if offset := request.GetOffset(); offset != nil {
+		start, _ := slices.BinarySearchFunc(matching, offset, func(point *memoryPoint, id *qdrant.PointId) int {
+			return compareIDs(point.id, id)
+		})
+		matching = matching[start:]
+	}
+
+	limit := defaultLimit
+	if request.Limit != nil {
+		limit = int(*request.Limit)
+	}
+	var next *qdrant.PointId
+	if len(matching) > limit {
+		next = matching[limit].id
+		matching = matching[:limit]
+	}
+
+	points := make([]*qdrant.RetrievedPoint, len(matching))
+	for i, point := range matching {
+		points[i] = retrieved(point, request.GetWithPayload(), request.GetWithVectors())
+	}
+	return points, next, nil
+}
+
+func (m *Memory) Delete(ctx context.Context, request *qdrant.DeletePoints) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	collection := m.collections[request.GetCollectionName()]
+	if collection == nil {
+		return nil
+	}
+
+	selector := request.GetPoints()
+	if ids := selector.GetPoints(); ids != nil {
+		for _, id := range ids.GetIds() {
+			delete(collection, idKey(id))
+		}
+		return nil
+	}
+	if filter := selector.GetFilter(); filter != nil {
+		for key, point := range collection {
+			if matchFilter(filter, point) {
+				delete(collection, key)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("delete needs point IDs or a filter")
+}
+
+func (m *Memory) Count(ctx context.Context, request *qdrant.CountPoints) (uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return uint64(len(m.matching(request.GetCollectionName(), request.GetFilter()))), nil
+}
+
+func (m *Memory) Facet(ctx context.Context, request *qdrant.FacetCounts) ([]*qdrant.FacetHit, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	type facet struct {
+		value *qdrant.FacetValue
+		count uint64
+	}
+	counts := make(map[string]*facet)
+	for _, point := range m.matching(request.GetCollectionName(), request.GetFilter()) {
+		// A point counts once per distinct value
+		seen := make(map[string]bool)
+		for _, value := range fieldValues(point.payload, request.GetKey()) {
+			key, facetValue := facetKey(value)
+			if facetValue == nil || seen[key] {
+				continue
+			}
+			seen[key] = true
+			if counts[key] == nil {
+				counts[key] = &facet{value: facetValue}
+			}
+			counts[key].count++
+		}
+	}
+
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		return cmp.Or(cmp.Compare(counts[b].count, counts[a].count), cmp.Compare(a, b))
+	})
+	if limit := limitOr(request.Limit); uint64(len(keys)) > limit {
+		keys = keys[:limit]
+	}
+
+	hits := make([]*qdrant.FacetHit, len(keys))
+	for i, key := range keys {
+		hits[i] = &qdrant.FacetHit{Value: counts[key].value, Count: counts[key].count}
+	}
+	return hits, nil
+}
+
+type scoredPoint struct {
+	point *memoryPoint
+	score float32
+}
+
+// matching returns the points that pass filter, in ID order
+func (m *Memory) matching(collectionName string, filter *qdrant.Filter) []*memoryPoint {
+	var points []*memoryPoint
+	for _, point := range m.collections[collectionName] {
+		if matchFilter(filter, point) {
+			points = append(points, point)
+		}
+	}
+	slices.SortFunc(points, func(a, b *memoryPoint) int {
+		return compareIDs(a.id, b.id)
+	})
+	return points
+}
+
+// rank orders the points matching filter as query asks
+func (m *Memory) rank(collectionName string, query *qdrant.Query, filter *qdrant.Filter) ([]scoredPoint, error) {
+	collection := m.collections[collectionName]
+	matching := m.matching(collectionName, filter)
+	scored := make([]scoredPoint, len(matching))
+	for i, point := range matching {
+		scored[i] = scoredPoint{point: point}
+	}
+
+	switch {
+	case query == nil:
+		// ID order, like Qdrant
+	case query.GetNearest() != nil:
+		vector, err := resolveVector(collection, query.GetNearest())
+		if err != nil {
+			return nil, err
+		}
+		for i := range scored {
+			scored[i].score = cosine(vector, scored[i].point.vector)
+		}
+		sortByScore(scored)
+	case query.GetRecommend() != nil:
+		return recommend(collection, scored, query.GetRecommend())
+	case query.GetOrderBy() != nil:
+		return orderBy(scored, query.GetOrderBy())
+	case query.GetVariant() != nil:
+		if _, ok := query.GetVariant().(*qdrant.Query_Sample); ok {
+			rand.Shuffle(len(scored), func(i, j int) {
+				scored[i], scored[j] = scored[j], scored[i]
+			})
+			return scored, nil
+		}
+		return nil, fmt.Errorf("query %T is %w", query.GetVariant(), ErrUnsupported)
+	}
+	return scored, nil
+}
+
+// recommend follows Qdrant's strategies: average vector searches from
+// avg(positive) + avg(positive) - avg(negative), best score ranks by the
+// closest positive example unless a negative one is closer. The examples
+// themselves are left out of the results.
+func recommend(collection map[string]*memoryPoint, scored []scoredPoint, input *qdrant.RecommendInput) ([]scoredPoint, error) {
+	positive, err := resolveVectors(collection, input.GetPositive())
+	if err != nil {
+		return nil, err
+	}
+	negative, err := resolveVectors(collection, input.GetNegative())
+	if err != nil {
+		return nil, err
+	}
+	if len(positive) == 0 {
+		return nil, fmt.Errorf("recommend needs at least one positive example")
+	}
+
+	examples := make(map[string]bool)
+	for _, example := range append(slices.Clone(input.GetPositive()), input.GetNegative()...) {
+		if id := example.GetId(); id != nil {
+			examples[idKey(id)] = true
+		}
+	}
+	scored = slices.DeleteFunc(scored, func(s scoredPoint) bool { return examples[idKey(s.point.id)] })
+
+	switch input.GetStrategy() {
+	case qdrant.RecommendStrategy_AverageVector:
+		target := average(positive)
+		if len(negative) > 0 {
+			negativeAverage := average(negative)
+			for i := range target {
+				target[i] += target[i] - negativeAverage[i]
+			}
+		}
+		for i := range scored {
+			scored[i].score = cosine(target, scored[i].point.vector)
+		}
+	case qdrant.RecommendStrategy_BestScore:
+		for i := range scored {
+			best := float32(math.Inf(-1))
+			for _, vector := range positive {
+				best = max(best, cosine(vector, scored[i].point.vector))
+			}
+			worst := float32(math.Inf(-1))
+			for _, vector := range negative {
+				worst = max(worst, cosine(vector, scored[i].point.vector))
+			}
+			if worst > best {
+				best = -worst
+			}
+			scored[i].score = best
+		}
+	default:
+		return nil, fmt.Errorf("recommend strategy %s is %w", input.GetStrategy(), ErrUnsupported)
+	}
+
+	sortByScore(scored)
+	return scored, nil
+}
+
+// orderBy sorts by a numeric payload field, leaving out points without it
+func orderBy(scored []scoredPoint, order *qdrant.OrderBy) ([]scoredPoint, error) {
+	if order.GetStartFrom() != nil {
+		return nil, fmt.Errorf("order by start from is %w", ErrUnsupported)
+	}
+
+	values := make(map[*memoryPoint]float64)
+	scored = slices.DeleteFunc(scored, func(s scoredPoint) bool {
+		for _, value := range fieldValues(s.point.payload, order.GetKey()) {
+			if number, ok := numeric(value); ok {
+				values[s.point] = number
+				return false
+			}
+		}
+		return true
+	})
+
+	descending := order.GetDirection() == qdrant.Direction_Desc
+	slices.SortStableFunc(scored, func(a, b scoredPoint) int {
+		if descending {
+			return cmp.Compare(values[b.point], values[a.point])
+		}
+		return cmp.Compare(values[a.point], values[b.point])
+	})
+	return scored, nil
+}
+
+func resolveVectors(collection map[string]*memoryPoint, inputs []*qdrant.VectorInput) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(inputs))
+	for _, input := range inputs {
+		vector, err := resolveVector(collection, input)
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, vector)
+	}
+	return vectors, nil
+}
+
+// resolveVector reads a dense vector or the vector of a stored point
+func resolveVector(collection map[string]*memoryPoint, input *qdrant.VectorInput) ([]float32, error) {
+	if dense := input.GetDense(); dense != nil {
+		return dense.GetData(), nil
+	}
+	if id := input.GetId(); id != nil {
+		point, ok := collection[idKey(id)]
+		if !ok {
+			return nil, fmt.Errorf("no point with id %s", idKey(id))
+		}
+		return point.vector, nil
+	}
+	return nil, fmt.Errorf("vector input %T is %w", input.GetVariant(), ErrUnsupported)
+}
+
+func sortByScore(scored []scoredPoint) {
+	slices.SortStableFunc(scored, func(a, b scoredPoint) int {
+		return cmp.Compare(b.score, a.score)
+	})
+}
+
+func page(scored []scoredPoint, offset uint64, limit uint64) []scoredPoint {
+	if offset >= uint64(len(scored)) {
+		return nil
+	}
+	scored = scored[offset:]
+	if uint64(len(scored)) > limit {
+		scored = scored[:limit]
+	}
+	return scored
+}
+
+func limitOr(limit *uint64) uint64 {
+	if limit == nil {
+		return defaultLimit
+	}
+	return *limit
+}
+
+func cosine(a, b []float32) float32 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return float32(dot / math.Sqrt(normA*normB))
+}
+
+func average(vectors [][]float32) []float32 {
+	sum := make([]float32, len(vectors[0]))
+	for _, vector := range vectors {
+		for i := range sum {
+			if i < len(vector) {
+				sum[i] += vector[i]
+			}
+		}
+	}
+	for i := range sum {
+		sum[i] /= float32(len(vectors))
+	}
+	return sum
+}
+
+// idKey is a point's key in a collection: the UUID, or the number for
+// numeric IDs
+func idKey(id *qdrant.PointId) string {
+	if uuid := id.GetUuid(); uuid != "" {
+		return uuid
+	}
+	return fmt.Sprint(id.GetNum())
+}
+
+// compareIDs orders numeric IDs before UUIDs, as Qdrant does
+func compareIDs(a, b *qdrant.PointId) int {
+	aUUID, bUUID := a.GetUuid(), b.GetUuid()
+	switch {
+	case aUUID == "" && bUUID == "":
+		return cmp.Compare(a.GetNum(), b.GetNum())
+	case aUUID == "":
+		return -1
+	case bUUID == "":
+		return 1
+	}
+	return cmp.Compare(aUUID, bUUID)
+}
+
+func groupKey(value *qdrant.Value) (string, *qdrant.GroupId) {
+	switch kind := value.GetKind().(type) {
+	case *qdrant.Value_StringValue:
+		return "s:" + kind.StringValue, &qdrant.GroupId{Kind: &qdrant.GroupId_StringValue{StringValue: kind.StringValue}}
+	case *qdrant.Value_IntegerValue:
+		return fmt.Sprint("i:", kind.IntegerValue), &qdrant.GroupId{Kind: &qdrant.GroupId_IntegerValue{IntegerValue: kind.IntegerValue}}
+	}
+	return "", nil
+}
+
+func facetKey(value *qdrant.Value) (string, *qdrant.FacetValue) {
+	switch kind := value.GetKind().(type) {
+	case *qdrant.Value_StringValue:
+		return "s:" + kind.StringValue, &qdrant.FacetValue{Variant: &qdrant.FacetValue_StringValue{StringValue: kind.StringValue}}
+	case *qdrant.Value_IntegerValue:
+		return fmt.Sprint("i:", kind.IntegerValue), &qdrant.FacetValue{Variant: &qdrant.FacetValue_IntegerValue{IntegerValue: kind.IntegerValue}}
+	case *qdrant.Value_BoolValue:
+		return fmt.Sprint("b:", kind.BoolValue), &qdrant.FacetValue{Variant: &qdrant.FacetValue_BoolValue{BoolValue: kind.BoolValue}}
+	}
+	return "", nil
+}
+
+func retrieved(point *memoryPoint, withPayload *qdrant.WithPayloadSelector, withVectors *qdrant.WithVectorsSelector) *qdrant.RetrievedPoint {
+	return &qdrant.RetrievedPoint{
+		Id:      point.id,
+		Payload: selectPayload(point.payload, withPayload),
+		Vectors: selectVectors(point.vector, withVectors),
+	}
+}
+
+func scoredOutput(s scoredPoint, withPayload *qdrant.WithPayloadSelector, withVectors *qdrant.WithVectorsSelector) *qdrant.ScoredPoint {
+	return &qdrant.ScoredPoint{
+		Id:      s.point.id,
+		Payload: selectPayload(s.point.payload, withPayload),
+		Score:   s.score,
+		Vectors: selectVectors(s.point.vector, withVectors),
+	}
+}
+
+// selectPayload applies a payload selector; like Qdrant, no selector means
+// no payload
+func selectPayload(payload map[string]*qdrant.Value, selector *qdrant.WithPayloadSelector) map[string]*qdrant.Value {
+	switch {
+	case selector.GetEnable():
+		return clonePayload(payload)
+	case selector.GetInclude() != nil:
+		selected := make(map[string]*qdrant.Value)
+		for _, field := range selector.GetInclude().GetFields() {
+			if value, ok := payload[field]; ok {
+				selected[field] = value
+			}
+		}
+		return selected
+	case selector.GetExclude() != nil:
+		selected := clonePayload(payload)
+		for _, field := range selector.GetExclude().GetFields() {
+			delete(selected, field)
+		}
+		return selected
+	}
+	return nil
+}
+
+func selectVectors(vector []float32, selector *qdrant.WithVectorsSelector) *qdrant.VectorsOutput {
+	if !selector.GetEnable() {
+		return nil
+	}
+	return &qdrant.VectorsOutput{
+		VectorsOptions: &qdrant.VectorsOutput_Vector{Vector: &qdrant.VectorOutput{Data: slices.Clone(vector)}},
+	}
+}
+
+func clonePayload(payload map[string]*qdrant.Value) map[string]*qdrant.Value {
+	cloned := make(map[string]*qdrant.Value, len(payload))
+	for key, value := range payload {
+		cloned[key] = value
+	}
+	return cloned
+}
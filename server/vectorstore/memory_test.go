@@ -0,0 +1,439 @@
+package vectorstore
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+func pointIDs[P interface{ GetId() *qdrant.PointId }](points []P) []uint64 {
+	ids := make([]uint64, len(points))
+	for i, point := range points {
+		ids[i] = point.GetId().GetNum()
+	}
+	return ids
+}
+
+func TestScrollPages(t *testing.T) {
+	store := newFixtureStore(t)
+	ctx := context.Background()
+	limit := uint32(2)
+
+	var pages [][]uint64
+	var offset *qdrant.PointId
+	for {
+		points, next, err := store.Scroll(ctx, &qdrant.ScrollPoints{
+			CollectionName: "papers",
+			Offset:         offset,
+			Limit:          &limit,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, pointIDs(points))
+		if next == nil {
+			break
+		}
+		offset = next
+	}
+
+	want := [][]uint64{{1, 2}, {3, 4}, {5}}
+	if !slices.EqualFunc(pages, want, slices.Equal) {
+		t.Errorf("pages = %v, want %v", pages, want)
+	}
+
+	// An offset is the first ID of the page, whether or not it matches
+	filter := &qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewMatchKeyword("categories", "cs.LG")}}
+	points, next, err := store.Scroll(ctx, &qdrant.ScrollPoints{
+		CollectionName: "papers",
+		Filter:         filter,
+		Offset:         qdrant.NewIDNum(2),
+		Limit:          &limit,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := pointIDs(points); !slices.Equal(got, []uint64{3, 5}) || next != nil {
+		t.Errorf("filtered page from 2 = %v (next %v), want [3 5] and no next page", got, next)
+	}
+}
+
+func TestScrollOrdersNumericIDsFirst(t *testing.T) {
+	store := NewMemory()
+	ctx := context.Background()
+	err := store.Upsert(ctx, &qdrant.UpsertPoints{
+		CollectionName: "mixed",
+		Points: []*qdrant.PointStruct{
+			{Id: qdrant.NewIDUUID("00000000-0000-0000-0000-000000000002"), Vectors: qdrant.NewVectorsDense([]float32{1})},
+			{Id: qdrant.NewIDNum(10), Vectors: qdrant.NewVectorsDense([]float32{1})},
+			{Id: qdrant.NewIDUUID("00000000-0000-0000-0000-000000000001"), Vectors: qdrant.NewVectorsDense([]float32{1})},
+			{Id: qdrant.NewIDNum(9), Vectors: qdrant.NewVectorsDense([]float32{1})},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	limit := uint32(3)
+	points, next, err := store.Scroll(ctx, &qdrant.ScrollPoints{CollectionName: "mixed", Limit: &limit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, point := range points {
+		got = append(got, idKey(point.GetId()))
+	}
+	want := []string{"9", "10", "00000000-0000-0000-0000-000000000001"}
+	if !slices.Equal(got, want) {
+		t.Errorf("scrolled %v, want %v", got, want)
+	}
+	if next.GetUuid() != "00000000-0000-0000-0000-000000000002" {
+		t.Errorf("next page starts at %v, want the last UUID", next)
+	}
+}
+
+func TestQueryNearest(t *testing.T) {
+	store := newFixtureStore(t)
+	limit := uint64(2)
+	threshold := float32(0.5)
+
+	points, err := store.Query(context.Background(), &qdrant.QueryPoints{
+		CollectionName: "papers",
+		Query:          qdrant.NewQuery(1, 0, 0),
+		Offset:         qdrant.PtrOf(uint64(1)),
+		Limit:          &limit,
+		ScoreThreshold: &threshold,
+		WithPayload:    qdrant.NewWithPayloadInclude("title"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 1 is the closest and skipped by the offset; 2 and 4 are orthogonal
+	// and below the threshold
+	if got := pointIDs(points); !slices.Equal(got, []uint64{3, 5}) {
+		t.Fatalf("nearest = %v, want [3 5]", got)
+	}
+	if points[0].GetScore() <= points[1].GetScore() {
+		t.Errorf("scores %v and %v are not descending", points[0].GetScore(), points[1].GetScore())
+	}
+	if len(points[0].GetPayload()) != 1 || points[0].GetPayload()["title"].GetStringValue() != "Learning to Rank" {
+		t.Errorf("payload = %v, want only the title", points[0].GetPayload())
+	}
+}
+
+// newRecommendStore stores 2D points A=1 (1, 0), B=2 (0, 1), C=3 (1, 1),
+// D=4 (1, -0.2) and E=5 (-1, 0.1)
+func newRecommendStore(t *testing.T) *Memory {
+	t.Helper()
+	store := NewMemory()
+	vectors := [][]float32{{1, 0}, {0, 1}, {1, 1}, {1, -0.2}, {-1, 0.1}}
+	points := make([]*qdrant.PointStruct, len(vectors))
+	for i, vector := range vectors {
+		points[i] = &qdrant.PointStruct{Id: qdrant.NewIDNum(uint64(i + 1)), Vectors: qdrant.NewVectorsDense(vector)}
+	}
+	if err := store.Upsert(context.Background(), &qdrant.UpsertPoints{CollectionName: "papers", Points: points}); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestRecommend(t *testing.T) {
+	store := newRecommendStore(t)
+	ids := func(nums ...uint64) []*qdrant.VectorInput {
+		inputs := make([]*qdrant.VectorInput, len(nums))
+		for i, num := range nums {
+			inputs[i] = qdrant.NewVectorInputID(qdrant.NewIDNum(num))
+		}
+		return inputs
+	}
+
+	tests := []struct {
+		name      string
+		recommend *qdrant.RecommendInput
+		want      []uint64
+	}{
+		{
+			// Searches from the average of A and B, which is C's direction
+			"average vector",
+			&qdrant.RecommendInput{Positive: ids(1, 2), Strategy: qdrant.RecommendStrategy_AverageVector.Enum()},
+			[]uint64{3, 4, 5},
+		},
+		{
+			// Searches from 2A - B = (2, -1)
+			"average vector with a negative example",
+			&qdrant.RecommendInput{Positive: ids(1), Negative: ids(2), Strategy: qdrant.RecommendStrategy_AverageVector.Enum()},
+			[]uint64{4, 3, 5},
+		},
+		{
+			// D is almost A, so its closest example beats C's
+			"best score",
+			&qdrant.RecommendInput{Positive: ids(1, 2), Strategy: qdrant.RecommendStrategy_BestScore.Enum()},
+			[]uint64{4, 3, 5},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			points, err := store.Query(context.Background(), &qdrant.QueryPoints{
+				CollectionName: "papers",
+				Query:          qdrant.NewQueryRecommend(test.recommend),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := pointIDs(points); !slices.Equal(got, test.want) {
+				t.Errorf("recommended %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRecommendBestScoreNegative(t *testing.T) {
+	store := newRecommendStore(t)
+	points, err := store.Query(context.Background(), &qdrant.QueryPoints{
+		CollectionName: "papers",
+		Query: qdrant.NewQueryRecommend(&qdrant.RecommendInput{
+			Positive: []*qdrant.VectorInput{qdrant.NewVectorInputID(qdrant.NewIDNum(1))},
+			Negative: []*qdrant.VectorInput{qdrant.NewVectorInputID(qdrant.NewIDNum(2))},
+			Strategy: qdrant.RecommendStrategy_BestScore.Enum(),
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scores := make(map[uint64]float32)
+	for _, point := range points {
+		scores[point.GetId().GetNum()] = point.GetScore()
+	}
+	if _, ok := scores[1]; ok {
+		t.Error("the positive example was recommended")
+	}
+	if _, ok := scores[2]; ok {
+		t.Error("the negative example was recommended")
+	}
+	// E is closer to the negative example B than to A, so it scores below
+	// zero; D is closer to A
+	if scores[5] >= 0 || scores[4] <= 0 {
+		t.Errorf("scores = %v, want E negative and D positive", scores)
+	}
+}
+
+func TestRecommendErrors(t *testing.T) {
+	store := newRecommendStore(t)
+	tests := []struct {
+		name      string
+		recommend *qdrant.RecommendInput
+	}{
+		{"missing example", &qdrant.RecommendInput{
+			Positive: []*qdrant.VectorInput{qdrant.NewVectorInputID(qdrant.NewIDNum(42))},
+		}},
+		{"no positive example", &qdrant.RecommendInput{
+			Negative: []*qdrant.VectorInput{qdrant.NewVectorInputID(qdrant.NewIDNum(1))},
+		}},
+	}
+	for _, test := range tests {
+		_, err := store.Query(context.Background(), &qdrant.QueryPoints{
+			CollectionName: "papers",
+			Query:          qdrant.NewQueryRecommend(test.recommend),
+		})
+		if err == nil {
+			t.Errorf("%s: recommend succeeded, want an error", test.name)
+		}
+	}
+
+	_, err := store.Query(context.Background(), &qdrant.QueryPoints{
+		CollectionName: "papers",
+		Query: qdrant.NewQueryRecommend(&qdrant.RecommendInput{
+			Positive: []*qdrant.VectorInput{qdrant.NewVectorInputID(qdrant.NewIDNum(1))},
+			Strategy: qdrant.RecommendStrategy(99).Enum(),
+		}),
+	})
+	if !errors.Is(err, ErrUnsupported) {
+		t.Errorf("unknown strategy error = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestQueryGroupsWithLookup(t *testing.T) {
+	store := NewMemory()
+	ctx := context.Background()
+
+	paperA := "00000000-0000-0000-0000-00000000000a"
+	paperB := "00000000-0000-0000-0000-00000000000b"
+	err := store.Upsert(ctx, &qdrant.UpsertPoints{
+		CollectionName: "papers",
+		Points: []*qdrant.PointStruct{
+			{Id: qdrant.NewIDUUID(paperA), Vectors: qdrant.NewVectorsDense([]float32{1, 0}), Payload: qdrant.NewValueMap(map[string]any{"title": "Paper A"})},
+			{Id: qdrant.NewIDUUID(paperB), Vectors: qdrant.NewVectorsDense([]float32{0, 1}), Payload: qdrant.NewValueMap(map[string]any{"title": "Paper B"})},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunks := []struct {
+		id     uint64
+		paper  string
+		vector []float32
+	}{
+		{1, paperA, []float32{1, 0}},
+		{2, paperA, []float32{1, 0.1}},
+		{3, paperA, []float32{1, 0.2}},
+		{4, paperB, []float32{1, 0.5}},
+		{5, paperB, []float32{0, 1}},
+		// No paper, so never grouped
+		{6, "", []float32{1, 0}},
+	}
+	points := make([]*qdrant.PointStruct, len(chunks))
+	for i, chunk := range chunks {
+		fields := map[string]any{}
+		if chunk.paper != "" {
+			fields["paperPointId"] = chunk.paper
+		}
+		points[i] = &qdrant.PointStruct{Id: qdrant.NewIDNum(chunk.id), Vectors: qdrant.NewVectorsDense(chunk.vector), Payload: qdrant.NewValueMap(fields)}
+	}
+	if err := store.Upsert(ctx, &qdrant.UpsertPoints{CollectionName: "chunks", Points: points}); err != nil {
+		t.Fatal(err)
+	}
+
+	limit, groupSize := uint64(5), uint64(2)
+	groups, err := store.QueryGroups(ctx, &qdrant.QueryPointGroups{
+		CollectionName: "chunks",
+		Query:          qdrant.NewQuery(1, 0),
+		GroupBy:        "paperPointId",
+		GroupSize:      &groupSize,
+		Limit:          &limit,
+		WithLookup: &qdrant.WithLookup{
+			Collection:  "papers",
+			WithPayload: qdrant.NewWithPayload(true),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(groups) != 2 {
+		t.Fatalf("got %d groups, want 2", len(groups))
+	}
+	wantGroups := []struct {
+		paper string
+		title string
+		hits  []uint64
+	}{
+		{paperA, "Paper A", []uint64{1, 2}},
+		{paperB, "Paper B", []uint64{4, 5}},
+	}
+	for i, want := range wantGroups {
+		group := groups[i]
+		if group.GetId().GetStringValue() != want.paper {
+			t.Errorf("group %d is %v, want %s", i, group.GetId(), want.paper)
+		}
+		if got := pointIDs(group.GetHits()); !slices.Equal(got, want.hits) {
+			t.Errorf("group %d hits = %v, want %v", i, got, want.hits)
+		}
+		if title := group.GetLookup().GetPayload()["title"].GetStringValue(); title != want.title {
+			t.Errorf("group %d lookup title = %q, want %q", i, title, want.title)
+		}
+	}
+
+	// The limit counts groups, not hits
+	limit = 1
+	groups, err = store.QueryGroups(ctx, &qdrant.QueryPointGroups{
+		CollectionName: "chunks",
+		Query:          qdrant.NewQuery(0, 1),
+		GroupBy:        "paperPointId",
+		Limit:          &limit,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || groups[0].GetId().GetStringValue() != paperB || groups[0].GetLookup() != nil {
+		t.Errorf("groups = %v, want only paper B without a lookup", groups)
+	}
+}
+
+func TestFacet(t *testing.T) {
+	store := newFixtureStore(t)
+	ctx := context.Background()
+
+	type count struct {
+		value string
+		count uint64
+	}
+	facet := func(request *qdrant.FacetCounts) []count {
+		t.Helper()
+		request.CollectionName = "papers"
+		hits, err := store.Facet(ctx, request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts := make([]count, len(hits))
+		for i, hit := range hits {
+			counts[i] = count{hit.GetValue().GetStringValue(), hit.GetCount()}
+		}
+		return counts
+	}
+
+	// Ordered by count, then value
+	got := facet(&qdrant.FacetCounts{Key: "categories", Limit: qdrant.PtrOf(uint64(3))})
+	want := []count{{"cs.LG", 3}, {"cs.CL", 2}, {"math.AG", 1}}
+	if !slices.Equal(got, want) {
+		t.Errorf("category facet = %v, want %v", got, want)
+	}
+
+	got = facet(&qdrant.FacetCounts{
+		Key:    "categories",
+		Filter: &qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewRange("year", &qdrant.Range{Gte: qdrant.PtrOf(2021.0)})}},
+	})
+	want = []count{{"cs.CL", 2}, {"cs.LG", 2}}
+	if !slices.Equal(got, want) {
+		t.Errorf("filtered category facet = %v, want %v", got, want)
+	}
+
+	// A paper counts once per author even when nested values repeat
+	got = facet(&qdrant.FacetCounts{Key: "authors[].name"})
+	want = []count{{"Ada Lovelace", 2}, {"Alan Turing", 2}, {"Grace Hopper", 1}}
+	if !slices.Equal(got, want) {
+		t.Errorf("author facet = %v, want %v", got, want)
+	}
+
+	hits, err := store.Facet(ctx, &qdrant.FacetCounts{CollectionName: "papers", Key: "year", Limit: qdrant.PtrOf(uint64(1))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 1 || hits[0].GetValue().GetIntegerValue() != 2019 || hits[0].GetCount() != 1 {
+		t.Errorf("year facet = %v, want 2019 counted once", hits)
+	}
+}
+
+func TestDeleteByFilter(t *testing.T) {
+	store := newFixtureStore(t)
+	ctx := context.Background()
+
+	err := store.Delete(ctx, &qdrant.DeletePoints{
+		CollectionName: "papers",
+		Points:         qdrant.NewPointsSelectorFilter(&qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewMatchKeyword("categories", "cs.CL")}}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := scrollIDs(t, store, nil); !slices.Equal(got, []uint64{1, 3, 4}) {
+		t.Errorf("left %v after deleting cs.CL, want [1 3 4]", got)
+	}
+
+	err = store.Delete(ctx, &qdrant.DeletePoints{
+		CollectionName: "papers",
+		Points:         qdrant.NewPointsSelector(qdrant.NewIDNum(3)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err := store.Count(ctx, &qdrant.CountPoints{CollectionName: "papers"})
+	if err != nil || count != 2 {
+		t.Errorf("count = %d, %v; want 2", count, err)
+	}
+}
@@ -0,0 +1,56 @@
+package vectorstore
+
+import (
+	"context"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Qdrant passes requests straight through to a Qdrant client
+type Qdrant struct {
+	client *qdrant.Client
+}
+
+func NewQdrant(client *qdrant.Client) *Qdrant {
+	return &Qdrant{client: client}
+}
+
+func (q *Qdrant) Upsert(ctx context.Context, request *qdrant.UpsertPoints) error {
+	_, err := q.client.Upsert(ctx, request)
+	return err
+}
+
+func (q *Qdrant) Get(ctx context.Context, request *qdrant.GetPoints) ([]*qdrant.RetrievedPoint, error) {
+	return q.client.Get(ctx, request)
+}
+
+func (q *Qdrant) Query(ctx context.Context, request *qdrant.QueryPoints) ([]*qdrant.ScoredPoint, error) {
+	return q.client.Query(ctx, request)
+}
+
+func (q *Qdrant) QueryGroups(ctx context.Context, request *qdrant.QueryPointGroups) ([]*qdrant.PointGroup, error) {
+	return q.client.QueryGroups(ctx, request)
+}
+
+func (q *Qdrant) Scroll(ctx context.Context, request *qdrant.ScrollPoints) ([]*qdrant.RetrievedPoint, *qdrant.PointId, error) {
+	// The client's Scroll helper drops the next page offset, so call the
+	// gRPC service directly
+	resp, err := q.client.GetPointsClient().Scroll(ctx, request)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp.GetResult(), resp.GetNextPageOffset(), nil
+}
+
+func (q *Qdrant) Delete(ctx context.Context, request *qdrant.DeletePoints) error {
+	_, err := q.client.Delete(ctx, request)
+	return err
+}
+
+func (q *Qdrant) Count(ctx context.Context, request *qdrant.CountPoints) (uint64, error) {
+	return q.client.Count(ctx, request)
+}
+
+func (q *Qdrant) Facet(ctx context.Context, request *qdrant.FacetCounts) ([]*qdrant.FacetHit, error) {
+	return q.client.Facet(ctx, request)
+}
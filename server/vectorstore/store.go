@@ -0,0 +1,42 @@
+// Package vectorstore puts the point operations the service and the
+// consumer need behind an interface, so they can run against Qdrant or, in
+// tests and dev mode, against an in-memory store. Requests and results are
+// the Qdrant client's own types, which are plain data, so callers build the
+// same queries and filters for either backend.
+package vectorstore
+
+import (
+	"context"
+	"errors"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Backends
+const (
+	BackendQdrant = "qdrant"
+	BackendMemory = "memory"
+)
+
+type VectorStore interface {
+	// Upsert inserts points or replaces the stored points with the same IDs
+	Upsert(ctx context.Context, request *qdrant.UpsertPoints) error
+	// Get returns the requested points that exist, skipping missing IDs
+	Get(ctx context.Context, request *qdrant.GetPoints) ([]*qdrant.RetrievedPoint, error)
+	// Query ranks points by a vector, example points, a payload field or at
+	// random, among the points matching the request's filter
+	Query(ctx context.Context, request *qdrant.QueryPoints) ([]*qdrant.ScoredPoint, error)
+	// QueryGroups is Query with results grouped by a payload field
+	QueryGroups(ctx context.Context, request *qdrant.QueryPointGroups) ([]*qdrant.PointGroup, error)
+	// Scroll lists points in ID order and returns the offset of the next
+	// page, or nil on the last page
+	Scroll(ctx context.Context, request *qdrant.ScrollPoints) ([]*qdrant.RetrievedPoint, *qdrant.PointId, error)
+	// Delete removes points by ID or by filter
+	Delete(ctx context.Context, request *qdrant.DeletePoints) error
+	Count(ctx context.Context, request *qdrant.CountPoints) (uint64, error)
+	// Facet counts the points per value of a payload field
+	Facet(ctx context.Context, request *qdrant.FacetCounts) ([]*qdrant.FacetHit, error)
+}
+
+// ErrUnsupported is returned by stores that can't serve part of a request
+var ErrUnsupported = errors.New("not supported by this vector store")